@@ -0,0 +1,93 @@
+package rcf
+
+import (
+	"io"
+	"os"
+)
+
+// backend is the storage of a File: a file on disk, a memory buffer, or an io.ReaderAt of NewReaderAt.
+// blocks are written at offsets tracked by the File, so backends have no position.
+type backend interface {
+	io.ReaderAt
+	io.WriterAt
+	Truncate(size int64) error
+	Size() (int64, error)
+	Sync() error
+	Close() error
+	// Reader returns a reader from the start of the data, independent of other readers. closer is nil if there is nothing to close.
+	Reader() (r io.ReadSeeker, closer io.Closer, err error)
+}
+
+var (
+	_ backend = new(osBackend)
+	_ backend = new(memoryFile)
+	_ backend = new(readerAtBackend)
+)
+
+// osBackend is the backend of files on disk
+type osBackend struct {
+	*os.File
+	path string
+}
+
+func (o *osBackend) Size() (int64, error) {
+	info, err := os.Stat(o.path)
+	if err != nil {
+		return 0, makeErr(err, "stat file")
+	}
+	return info.Size(), nil
+}
+
+// Reader opens the file again, so reads see blocks appended later
+func (o *osBackend) Reader() (io.ReadSeeker, io.Closer, error) {
+	file, err := os.Open(o.path)
+	if err != nil {
+		return nil, nil, makeErr(err, "open file")
+	}
+	return file, file, nil
+}
+
+// readerAtBackend is the read-only backend of NewReaderAt
+type readerAtBackend struct {
+	r    io.ReaderAt
+	size int64
+}
+
+func (r *readerAtBackend) ReadAt(p []byte, offset int64) (int, error) {
+	return r.r.ReadAt(p, offset)
+}
+
+func (r *readerAtBackend) WriteAt([]byte, int64) (int, error) {
+	return 0, makeErr(nil, "file opened read-only")
+}
+
+func (r *readerAtBackend) Truncate(int64) error {
+	return makeErr(nil, "file opened read-only")
+}
+
+func (r *readerAtBackend) Size() (int64, error) {
+	return r.size, nil
+}
+
+func (r *readerAtBackend) Sync() error {
+	return nil
+}
+
+func (r *readerAtBackend) Close() error {
+	return nil
+}
+
+func (r *readerAtBackend) Reader() (io.ReadSeeker, io.Closer, error) {
+	return io.NewSectionReader(r.r, 0, r.size), nil, nil
+}
+
+// backendWriter writes to the backend of f at the write offset, and advances the offset. the mutex must be held.
+type backendWriter struct {
+	f *File
+}
+
+func (w backendWriter) Write(p []byte) (int, error) {
+	n, err := w.f.backend.WriteAt(p, w.f.writeOffset)
+	w.f.writeOffset += int64(n)
+	return n, err
+}
@@ -0,0 +1,84 @@
+package rcf
+
+import "fmt"
+
+// Batch is the rows and the meta of a block appended by AppendBatch
+type Batch struct {
+	Rows interface{}
+	Meta interface{}
+}
+
+// AppendBatch appends a block of each batch, like Append. all blocks are encoded first, then written with one write under one lock,
+// so if any block fails to encode, none is written.
+func (f *File) AppendBatch(batches []Batch) (err error) {
+	if len(batches) == 0 {
+		return nil
+	}
+	if err := f.validate(); err != nil {
+		return err
+	}
+	if f.gobStream {
+		f.Lock()
+		defer f.Unlock()
+		defer f.resetStreams(&err)
+	}
+	blocks := make([][]byte, 0, len(batches))
+	for _, batch := range batches {
+		sets, err := f.rowsSets(batch.Rows)
+		if err != nil {
+			return err
+		}
+		meta := batch.Meta
+		block, err := f.encodeBlock(sets, func() ([]byte, error) {
+			return f.encodeMeta(meta)
+		})
+		if err != nil {
+			return err
+		}
+		blocks = append(blocks, block)
+	}
+	if !f.gobStream {
+		f.Lock()
+		defer f.Unlock()
+	}
+	_, err = f.writeBlocks(blocks)
+	return err
+}
+
+// AppendEncoded appends a block of encoded payloads, the meta payload and a payload of each column set, as written by Append to the file:
+// encoded by the codec and compressed by the compression method of the file, and column set payloads starting with their encodings if the file records them, like payloads returned by EncodeColumnSet for new files of default options.
+// the payloads are not checked, except that the first column set is decoded to count rows if the file records row counts. blocks appended by it have no column statistics.
+// it can not be used in gob stream mode, where payloads depend on earlier ones.
+func (f *File) AppendEncoded(metaBin []byte, columnSetBins [][]byte) error {
+	if f.gobStream {
+		return makeErr(nil, "appending encoded blocks in gob stream mode")
+	}
+	if len(columnSetBins) != len(f.colSets) {
+		return makeErr(nil, fmt.Sprintf("got %d column set payloads, expecting %d", len(columnSetBins), len(f.colSets)))
+	}
+	if err := f.validate(); err != nil {
+		return err
+	}
+	rows := 0
+	if f.flags&_FLAG_ROWS > 0 {
+		for n, set := range f.colSets {
+			if len(set) == 0 {
+				continue
+			}
+			s := f.colSetsFn(n)
+			if err := f.decodeSet(nil, n, columnSetBins[n], &s); err != nil {
+				return makeKindErr(KindDecode, err, "decode column set")
+			}
+			rows = setsRows([]interface{}{s})
+			break
+		}
+	}
+	block, err := f.frameBlock(metaBin, columnSetBins, nil, rows)
+	if err != nil {
+		return err
+	}
+	f.Lock()
+	defer f.Unlock()
+	_, err = f.writeBlocks([][]byte{block})
+	return err
+}
@@ -0,0 +1,153 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAppendBatch(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	for _, gobStream := range []bool{false, true} {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+		f, err := NewWithOptions(path, func(i int) (ret interface{}) {
+			switch i {
+			case 0:
+				ret = &struct{ Foo []int }{}
+			case 1:
+				ret = &struct{ Bar []string }{}
+			}
+			return
+		}, Options{
+			GobStream: gobStream,
+		})
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		defer f.Close()
+
+		if err := f.Append([]Foo{{0, "0"}}, 0); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		var batches []Batch
+		for i := 1; i < 10; i++ {
+			batches = append(batches, Batch{
+				Rows: []Foo{{i, fmt.Sprintf("%d", i)}, {i, fmt.Sprintf("%d", i)}},
+				Meta: i,
+			})
+		}
+		if err := f.AppendBatch(batches); err != nil {
+			t.Fatalf("append batch: %v", err)
+		}
+
+		// nothing is written if any batch fails
+		if err := f.AppendBatch([]Batch{
+			{Rows: []Foo{{10, "10"}}, Meta: 10},
+			{Rows: 42, Meta: 11},
+		}); err == nil {
+			t.Fatal("should fail")
+		}
+		n, err := f.Count()
+		if err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		if n != 10 {
+			t.Fatalf("got %d blocks", n)
+		}
+
+		if err := f.Append([]Foo{{10, "10"}}, 10); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		var meta int
+		var columns struct {
+			Foo []int
+			Bar []string
+		}
+		i := 0
+		err = f.IterAll(&meta, &columns, func() bool {
+			if meta != i || columns.Foo[0] != i || columns.Bar[len(columns.Bar)-1] != fmt.Sprintf("%d", i) {
+				t.Fatalf("got %d %v %v", meta, columns.Foo, columns.Bar)
+			}
+			i++
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter all: %v", err)
+		}
+		if i != 11 {
+			t.Fatalf("got %d blocks", i)
+		}
+	}
+}
+
+func TestAppendEncoded(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct{ Foo []int }{}
+		case 1:
+			ret = &struct{ Bar []string }{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	sets, err := f.rowsSets([]Foo{{1, "1"}, {2, "2"}})
+	if err != nil {
+		t.Fatalf("rows sets: %v", err)
+	}
+	metaBin, err := f.encodeMeta(42)
+	if err != nil {
+		t.Fatalf("encode meta: %v", err)
+	}
+	var bins [][]byte
+	for n, set := range sets {
+		bin, err := f.encodeSet(n, set)
+		if err != nil {
+			t.Fatalf("encode set: %v", err)
+		}
+		bins = append(bins, bin)
+	}
+
+	if err := f.AppendEncoded(metaBin, bins[:1]); err == nil {
+		t.Fatal("should fail")
+	}
+	if err := f.AppendEncoded(metaBin, bins); err != nil {
+		t.Fatalf("append encoded: %v", err)
+	}
+	if err := f.Append([]Foo{{3, "3"}}, 43); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	n, err := f.NumRows()
+	if err != nil {
+		t.Fatalf("num rows: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("got %d rows", n)
+	}
+	var meta int
+	var columns struct {
+		Foo []int
+		Bar []string
+	}
+	if err := f.ReadBlock(0, &meta, &columns); err != nil {
+		t.Fatalf("read block: %v", err)
+	}
+	if meta != 42 || len(columns.Foo) != 2 || columns.Foo[1] != 2 || columns.Bar[1] != "2" {
+		t.Fatalf("got %d %v %v", meta, columns.Foo, columns.Bar)
+	}
+}
@@ -58,13 +58,58 @@ func BenchmarkAppend(b *testing.B) {
 	}
 }
 
+// GobCodec decodes column sets into new values, while msgpack sets are decoded into the slices of IterInto
+var benchmarkIterCodecs = []string{"gob", "msgpack"}
+
 func BenchmarkIter(b *testing.B) {
+	for _, ext := range benchmarkIterCodecs {
+		b.Run(ext, func(b *testing.B) {
+			benchmarkIter(b, ext)
+		})
+	}
+}
+
+func BenchmarkIterInto(b *testing.B) {
+	for _, ext := range benchmarkIterCodecs {
+		b.Run(ext, func(b *testing.B) {
+			benchmarkIterInto(b, ext)
+		})
+	}
+}
+
+func benchmarkIter(b *testing.B, ext string) {
+	f := benchmarkIterFile(b, ext)
+	defer f.Close()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.Iter([]string{"Foo", "Bar"}, func(cols ...interface{}) bool {
+			return true
+		})
+	}
+}
+
+func benchmarkIterInto(b *testing.B, ext string) {
+	f := benchmarkIterFile(b, ext)
+	defer f.Close()
+	var foos, bars []int
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.IterInto([]string{"Foo", "Bar"}, []interface{}{&foos, &bars}, func() bool {
+			return true
+		})
+	}
+}
+
+// benchmarkIterFile returns a file of 512 blocks of 20 rows for iteration benchmarks. the extension ext selects the codec by the path.
+func benchmarkIterFile(b *testing.B, ext string) *File {
 	type Foo struct {
 		Foo int
 		Bar int
 		Baz int
 	}
-	f, err := New(filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63())), func(i int) interface{} {
+	f, err := New(filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d.%s", rand.Int63(), ext)), func(i int) interface{} {
 		if i == 0 {
 			return &struct {
 				Foo []int
@@ -77,30 +122,134 @@ func BenchmarkIter(b *testing.B) {
 	if err != nil {
 		b.Fatal(err)
 	}
+	rows := make([]Foo, 20)
+	for i := range rows {
+		rows[i] = Foo{1, 2, 3}
+	}
+	for i := 0; i < 512; i++ {
+		err = f.Append(rows, true)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	return f
+}
+
+// BenchmarkIterFullScan iterates all columns of a file of several column sets
+func BenchmarkIterFullScan(b *testing.B) {
+	type Foo struct {
+		Foo int
+		Bar string
+		Baz int
+		Qux bool
+	}
+	f, err := New(filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63())), func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+				Bar []string
+			}{}
+		case 1:
+			ret = &struct {
+				Baz []int
+			}{}
+		case 2:
+			ret = &struct {
+				Qux []bool
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
 	defer f.Close()
+	rows := make([]Foo, 20)
+	for i := range rows {
+		rows[i] = Foo{i, "bar", i * 2, i%2 == 0}
+	}
 	for i := 0; i < 512; i++ {
-		err = f.Append([]Foo{
-			{1, 2, 3},
-			{1, 2, 3},
-			{1, 2, 3},
-			{1, 2, 3},
-			{1, 2, 3},
-			{1, 2, 3},
-			{1, 2, 3},
-			{1, 2, 3},
-			{1, 2, 3},
-			{1, 2, 3},
-			{1, 2, 3},
-			{1, 2, 3},
-			{1, 2, 3},
-			{1, 2, 3},
-			{1, 2, 3},
-			{1, 2, 3},
-			{1, 2, 3},
-			{1, 2, 3},
-			{1, 2, 3},
-			{1, 2, 3},
-		}, true)
+		if err := f.Append(rows, i); err != nil {
+			b.Fatal(err)
+		}
+	}
+	cols := f.Columns()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := f.Iter(cols, func(cols ...interface{}) bool {
+			return true
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppendGobStream(b *testing.B) {
+	type Foo struct {
+		Foo int
+		Bar int
+		Baz int
+	}
+	f, err := NewWithOptions(filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63())), func(i int) interface{} {
+		if i == 0 {
+			return &struct {
+				Foo []int
+				Bar []int
+				Baz []int
+			}{}
+		}
+		return nil
+	}, Options{
+		GobStream: true,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	rows := make([]Foo, 20)
+	for i := range rows {
+		rows[i] = Foo{1, 2, 3}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err = f.Append(rows, true)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkIterGobStream(b *testing.B) {
+	type Foo struct {
+		Foo int
+		Bar int
+		Baz int
+	}
+	f, err := NewWithOptions(filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63())), func(i int) interface{} {
+		if i == 0 {
+			return &struct {
+				Foo []int
+				Bar []int
+				Baz []int
+			}{}
+		}
+		return nil
+	}, Options{
+		GobStream: true,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	rows := make([]Foo, 20)
+	for i := range rows {
+		rows[i] = Foo{1, 2, 3}
+	}
+	for i := 0; i < 512; i++ {
+		err = f.Append(rows, true)
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -112,3 +261,453 @@ func BenchmarkIter(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkAppend1M(b *testing.B) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	f, err := New(filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63())), func(i int) interface{} {
+		if i == 0 {
+			return &struct {
+				Foo []int
+				Bar []string
+			}{}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	rows := make([]Foo, 1000000)
+	for i := range rows {
+		rows[i] = Foo{i, "foo"}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err = f.Append(rows, true)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppendColumns1M(b *testing.B) {
+	f, err := New(filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63())), func(i int) interface{} {
+		if i == 0 {
+			return &struct {
+				Foo []int
+				Bar []string
+			}{}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	foos := make([]int, 1000000)
+	bars := make([]string, 1000000)
+	for i := range foos {
+		foos[i] = i
+		bars[i] = "foo"
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err = f.AppendColumns(map[string]interface{}{
+			"Foo": foos,
+			"Bar": bars,
+		}, true)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkIterAll(b *testing.B) {
+	type Foo struct {
+		Foo int
+		Bar int
+		Baz int
+	}
+	f, err := New(filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63())), func(i int) interface{} {
+		if i == 0 {
+			return &struct {
+				Foo []int
+				Bar []int
+				Baz []int
+			}{}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	rows := make([]Foo, 20)
+	for i := range rows {
+		rows[i] = Foo{1, 2, 3}
+	}
+	for i := 0; i < 512; i++ {
+		err = f.Append(rows, true)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	var meta bool
+	var columns struct {
+		Foo []int
+		Bar []int
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.IterAll(&meta, &columns, func() bool {
+			return true
+		})
+	}
+}
+
+func BenchmarkAppendWide(b *testing.B) {
+	type Foo struct {
+		A, B, C, D int
+		E, F, G, H string
+	}
+	f, err := New(filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63())), func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				A, B, C, D []int
+			}{}
+		case 1:
+			ret = &struct {
+				E, F, G, H []string
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	rows := make([]Foo, 10000)
+	for i := range rows {
+		rows[i] = Foo{i, i, i, i, "foo", "bar", "baz", "qux"}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err = f.Append(rows, true)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkSmallAppends(b *testing.B, opts Options) {
+	type Foo struct {
+		Foo int
+	}
+	f, err := NewWithOptions(filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63())), func(i int) interface{} {
+		if i == 0 {
+			return &struct {
+				Foo []int
+			}{}
+		}
+		return nil
+	}, opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	rows := []Foo{{1}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 100000; j++ {
+			err = f.Append(rows, true)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+		err = f.Flush()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSmallAppends(b *testing.B) {
+	benchmarkSmallAppends(b, Options{})
+}
+
+func BenchmarkSmallAppendsBuffered(b *testing.B) {
+	benchmarkSmallAppends(b, Options{
+		WriteBufferSize: 1 << 20,
+	})
+}
+
+func BenchmarkAppendSets(b *testing.B) {
+	type Foo struct {
+		Foo int
+		Bar string
+		Baz float64
+		Qux int64
+	}
+	f, err := New(filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d.snappy", rand.Int63())), func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct{ Foo []int }{}
+		case 1:
+			ret = &struct{ Bar []string }{}
+		case 2:
+			ret = &struct{ Baz []float64 }{}
+		case 3:
+			ret = &struct{ Qux []int64 }{}
+		}
+		return
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	rows := make([]Foo, 100000)
+	for i := range rows {
+		rows[i] = Foo{i, fmt.Sprintf("%d", i), float64(i), int64(i)}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err = f.Append(rows, true)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkReadBlock(b *testing.B, opts Options) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	f, err := NewWithOptions(filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63())), func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct{ Foo []int }{}
+		case 1:
+			ret = &struct{ Bar []string }{}
+		}
+		return
+	}, opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	rows := make([]Foo, 20)
+	for i := range rows {
+		rows[i] = Foo{i, fmt.Sprintf("%d", i)}
+	}
+	for i := 0; i < 1024; i++ {
+		if err := f.Append(rows, i); err != nil {
+			b.Fatal(err)
+		}
+	}
+	var meta int
+	var columns struct {
+		Foo []int
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := f.ReadBlock(i%1024, &meta, &columns); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadBlock(b *testing.B) {
+	benchmarkReadBlock(b, Options{})
+}
+
+func BenchmarkReadBlockMmap(b *testing.B) {
+	benchmarkReadBlock(b, Options{
+		Mmap: true,
+	})
+}
+
+func BenchmarkCompression(b *testing.B) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	rows := make([]Foo, 10000)
+	for i := range rows {
+		rows[i] = Foo{i, fmt.Sprintf("foo %d", i%100)}
+	}
+	for _, c := range []struct {
+		name        string
+		compression Compression
+	}{
+		{"snappy", CompressSnappy},
+		{"lz4", CompressLZ4},
+		{"zstd", CompressZstd},
+		{"gzip", CompressGzip},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			f, err := NewWithOptions(filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63())), func(i int) (ret interface{}) {
+				switch i {
+				case 0:
+					ret = &struct{ Foo []int }{}
+				case 1:
+					ret = &struct{ Bar []string }{}
+				}
+				return
+			}, Options{
+				Compression: c.compression,
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer f.Close()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := f.Append(rows, i); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			stats, err := f.StatsUncompressed()
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportMetric(float64(stats.UncompressedBytes)/float64(stats.PayloadBytes), "ratio")
+		})
+	}
+}
+
+func BenchmarkCompressedAppend(b *testing.B) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	rows := make([]Foo, 20)
+	for i := range rows {
+		rows[i] = Foo{i, fmt.Sprintf("foo %d", i)}
+	}
+	benchmarkCompressed(b, func(b *testing.B, f *File) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := f.Append(rows, i); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkCompressedIter(b *testing.B) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	rows := make([]Foo, 20)
+	for i := range rows {
+		rows[i] = Foo{i, fmt.Sprintf("foo %d", i)}
+	}
+	benchmarkCompressed(b, func(b *testing.B, f *File) {
+		for i := 0; i < 512; i++ {
+			if err := f.Append(rows, i); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			err := f.Iter([]string{"Foo", "Bar"}, func(cols ...interface{}) bool {
+				return true
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// benchmarkCompressed runs fn with a file of each compression method
+func benchmarkCompressed(b *testing.B, fn func(b *testing.B, f *File)) {
+	for _, c := range []struct {
+		name        string
+		compression Compression
+	}{
+		{"snappy", CompressSnappy},
+		{"lz4", CompressLZ4},
+		{"zstd", CompressZstd},
+		{"gzip", CompressGzip},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			f, err := NewWithOptions(filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63())), func(i int) (ret interface{}) {
+				switch i {
+				case 0:
+					ret = &struct{ Foo []int }{}
+				case 1:
+					ret = &struct{ Bar []string }{}
+				}
+				return
+			}, Options{
+				Compression: c.compression,
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer f.Close()
+			fn(b, f)
+		})
+	}
+}
+
+func BenchmarkAppendLooped(b *testing.B) {
+	benchmarkAppendBatch(b, false)
+}
+
+func BenchmarkAppendBatch(b *testing.B) {
+	benchmarkAppendBatch(b, true)
+}
+
+func benchmarkAppendBatch(b *testing.B, batched bool) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	f, err := New(filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63())), func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct{ Foo []int }{}
+		case 1:
+			ret = &struct{ Bar []string }{}
+		}
+		return
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	batches := make([]Batch, 1000)
+	for i := range batches {
+		batches[i] = Batch{
+			Rows: []Foo{{i, "foo"}, {i, "bar"}},
+			Meta: i,
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if batched {
+			err = f.AppendBatch(batches)
+			if err != nil {
+				b.Fatal(err)
+			}
+			continue
+		}
+		for _, batch := range batches {
+			err = f.Append(batch.Rows, batch.Meta)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
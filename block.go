@@ -0,0 +1,262 @@
+package rcf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+)
+
+// blockOffset returns the offset of the nth block, and false if there are no more than n blocks.
+// the offset index is built on first use and extended when later blocks are requested.
+func (f *File) blockOffset(n int) (int64, bool, error) {
+	f.Lock()
+	defer f.Unlock()
+	if n < len(f.blockIndex) {
+		return f.blockIndex[n], true, nil
+	}
+	if err := f.flush(); err != nil {
+		return 0, false, err
+	}
+	r, closer, err := f.openData()
+	if err != nil {
+		return 0, false, err
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+	_, err = r.Seek(f.blockIndexEnd, os.SEEK_SET)
+	if err != nil {
+		return 0, false, makeErr(err, "seek")
+	}
+	cursor, err := NewFrameCursor(r)
+	if err != nil {
+		return 0, false, err
+	}
+	cursor.flags = f.flags
+	cursor.wideSets = f.wideSets
+	for len(f.blockIndex) <= n {
+		header, err := cursor.NextHeader()
+		if err == io.EOF { // no more
+			return 0, false, nil
+		}
+		if err != nil {
+			return 0, false, err
+		}
+		f.blockIndex = append(f.blockIndex, header.Offset)
+		f.blockIndexEnd = header.Offset + header.Size()
+	}
+	return f.blockIndex[n], true, nil
+}
+
+// blockOffsets returns the offsets of all blocks, in file order
+func (f *File) blockOffsets() ([]int64, error) {
+	if _, _, err := f.blockOffset(math.MaxInt32); err != nil {
+		return nil, err
+	}
+	f.Lock()
+	defer f.Unlock()
+	return append([]int64{}, f.blockIndex...), nil
+}
+
+// IterReverse is like Iter, but delivers blocks from the last to the first.
+// offsets of all blocks are indexed first by a forward scan of the block headers.
+func (f *File) IterReverse(cols []string, cb func(columns ...interface{}) bool) error {
+	if f.gobStream {
+		return makeErr(nil, "random access is not supported in gob stream mode")
+	}
+	offsets, err := f.blockOffsets()
+	if err != nil {
+		return err
+	}
+	for i, j := 0, len(offsets)-1; i < j; i, j = i+1, j-1 {
+		offsets[i], offsets[j] = offsets[j], offsets[i]
+	}
+	return f.iterBlocks(context.Background(), cols, offsets, nil, false, func(_ *BlockHeader, _ []byte, columns []interface{}) bool {
+		return cb(columns...)
+	})
+}
+
+// ReadBlock decodes the meta and the columns of the nth block into metaTarget and columnsTarget.
+// columns are selected by the fields of columnsTarget, like IterAll. returns ErrBlockOutOfRange if there are no more than n blocks.
+func (f *File) ReadBlock(n int, metaTarget, columnsTarget interface{}) error {
+	if f.gobStream {
+		return makeErr(nil, "random access is not supported in gob stream mode")
+	}
+	if n < 0 {
+		return ErrBlockOutOfRange
+	}
+	offset, ok, err := f.blockOffset(n)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrBlockOutOfRange
+	}
+	return f.ReadBlockAt(offset, metaTarget, columnsTarget)
+}
+
+// ReadBlockAt is like ReadBlock, but reads the block at offset, as returned by AppendAt or BlockHeader.Offset
+func (f *File) ReadBlockAt(offset int64, metaTarget, columnsTarget interface{}) error {
+	if f.gobStream {
+		return makeErr(nil, "random access is not supported in gob stream mode")
+	}
+	if offset < f.dataStart {
+		return makeErr(nil, fmt.Sprintf("bad block offset %d", offset))
+	}
+	if f.mmap {
+		return f.readMappedBlock(offset, metaTarget, columnsTarget)
+	}
+	cursor, err := f.Cursor()
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	return f.readBlock(cursor, offset, metaTarget, columnsTarget)
+}
+
+// RawBlock returns the payloads of the nth block as stored, without decoding or decompressing: the meta, and one payload of each column set in the block.
+// column set payloads can be decoded by DecodeColumnSet if the file has the default options. payloads in gob stream mode depend on earlier blocks.
+// returns ErrBlockOutOfRange if there are no more than n blocks.
+func (f *File) RawBlock(n int) (meta []byte, columnSets [][]byte, err error) {
+	if n < 0 {
+		return nil, nil, ErrBlockOutOfRange
+	}
+	offset, ok, err := f.blockOffset(n)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, ErrBlockOutOfRange
+	}
+	cursor, err := f.Cursor()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cursor.Close()
+	_, err = cursor.Seek(offset, os.SEEK_SET)
+	if err != nil {
+		return nil, nil, err
+	}
+	header, err := cursor.NextHeader()
+	if err == io.EOF {
+		err = makeErr(io.ErrUnexpectedEOF, "read block header")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	all := make([]bool, len(header.SetLengths))
+	for i := range all {
+		all[i] = true
+	}
+	meta, columnSets, err = cursor.readParts(true, all)
+	if err != nil {
+		return nil, nil, err
+	}
+	// not to alias the mapped file
+	meta = append([]byte(nil), meta...)
+	for i, bs := range columnSets {
+		columnSets[i] = append([]byte(nil), bs...)
+	}
+	return meta, columnSets, nil
+}
+
+// readBlock implements ReadBlockAt with cursor
+func (f *File) readBlock(cursor *FrameCursor, offset int64, metaTarget, columnsTarget interface{}) error {
+	columnsToCollect := make(map[string]bool)
+	t := reflect.TypeOf(columnsTarget).Elem()
+	for i, l := 0, t.NumField(); i < l; i++ {
+		columnsToCollect[t.Field(i).Name] = true
+	}
+	toDecode := f.setsToDecode(columnsToCollect)
+
+	_, err := cursor.Seek(offset, os.SEEK_SET)
+	if err != nil {
+		return err
+	}
+	_, err = cursor.NextHeader()
+	if err == io.EOF {
+		err = makeErr(io.ErrUnexpectedEOF, "read block header")
+	}
+	if err != nil {
+		return err
+	}
+	metaBytes, setBytes, err := cursor.readParts(true, toDecode)
+	if err != nil {
+		return err
+	}
+
+	err = f.decodeMeta(metaBytes, metaTarget)
+	if err != nil {
+		return makeKindErr(KindDecode, err, "decode meta")
+	}
+	columnsTargetValue := reflect.ValueOf(columnsTarget).Elem()
+	for n := range f.colSets {
+		var columnSetValue reflect.Value
+		if n >= len(setBytes) { // set added to the schema after the block was appended
+			if !toDecode[n] {
+				continue
+			}
+			columnSetValue = f.emptySet(n)
+		} else {
+			if setBytes[n] == nil {
+				continue
+			}
+			columnSet := f.colSetsFn(n)
+			err = f.decodeSet(nil, n, setBytes[n], &columnSet)
+			if err != nil {
+				return makeKindErr(KindDecode, err, "decode column set")
+			}
+			columnSetValue = reflect.ValueOf(columnSet).Elem()
+		}
+		columnSetType := columnSetValue.Type()
+		for i, l := 0, columnSetType.NumField(); i < l; i++ {
+			name := columnSetType.Field(i).Name
+			if columnsToCollect[name] {
+				columnsTargetValue.FieldByName(name).Set(columnSetValue.Field(i))
+			}
+		}
+	}
+	return nil
+}
+
+// LastMeta decodes the meta of the last block into metaTarget, without reading column sets. returns ErrEmpty if the file has no block.
+func (f *File) LastMeta(metaTarget interface{}) error {
+	if f.gobStream {
+		return makeErr(nil, "random access is not supported in gob stream mode")
+	}
+	offsets, err := f.blockOffsets()
+	if err != nil {
+		return err
+	}
+	if len(offsets) == 0 {
+		return ErrEmpty
+	}
+	cursor, err := f.Cursor()
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	_, err = cursor.Seek(offsets[len(offsets)-1], os.SEEK_SET)
+	if err != nil {
+		return err
+	}
+	_, err = cursor.NextHeader()
+	if err == io.EOF {
+		err = makeErr(io.ErrUnexpectedEOF, "read block header")
+	}
+	if err != nil {
+		return err
+	}
+	metaBytes, _, err := cursor.readParts(true, nil)
+	if err != nil {
+		return err
+	}
+	err = f.decodeMeta(metaBytes, metaTarget)
+	if err != nil {
+		return makeKindErr(KindDecode, err, "decode meta")
+	}
+	return nil
+}
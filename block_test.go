@@ -0,0 +1,469 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadBlock(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+		Baz bool
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+				Baz []bool
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	var meta int
+	var columns struct {
+		Foo []int
+		Bar []string
+	}
+	if err := f.ReadBlock(0, &meta, &columns); err != ErrBlockOutOfRange {
+		t.Fatalf("expecting out of range, got %v", err)
+	}
+
+	appendBlocks := func(from, to int) {
+		for i := from; i < to; i++ {
+			err = f.Append([]Foo{{i, fmt.Sprintf("%d", i), true}}, i)
+			if err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+	}
+	appendBlocks(0, 100)
+
+	check := func(n int) {
+		columns.Foo = nil
+		columns.Bar = nil
+		err := f.ReadBlock(n, &meta, &columns)
+		if err != nil {
+			t.Fatalf("read block %d: %v", n, err)
+		}
+		if meta != n || columns.Foo[0] != n || columns.Bar[0] != fmt.Sprintf("%d", n) {
+			t.Fatalf("wrong block %d: %v %v", n, meta, columns)
+		}
+	}
+	for _, n := range rand.Perm(100) {
+		check(n)
+	}
+	if err := f.ReadBlock(100, &meta, &columns); err != ErrBlockOutOfRange {
+		t.Fatalf("expecting out of range, got %v", err)
+	}
+	if err := f.ReadBlock(-1, &meta, &columns); err != ErrBlockOutOfRange {
+		t.Fatalf("expecting out of range, got %v", err)
+	}
+
+	// index extends to later appended blocks
+	appendBlocks(100, 150)
+	check(149)
+	check(42)
+}
+
+func TestAppendAt(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	var offsets []int64
+	appendBlocks := func(from, to int) {
+		for i := from; i < to; i++ {
+			offset, err := f.AppendAt([]Foo{{i, fmt.Sprintf("%d", i)}}, i)
+			if err != nil {
+				t.Fatalf("append: %v", err)
+			}
+			offsets = append(offsets, offset)
+		}
+	}
+	appendBlocks(0, 10)
+	f.Close()
+
+	// append after reopen
+	f, err = New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	appendBlocks(10, 20)
+
+	// offsets match the framing
+	cursor, err := f.Cursor()
+	if err != nil {
+		t.Fatalf("cursor: %v", err)
+	}
+	defer cursor.Close()
+	for i, offset := range offsets {
+		header, err := cursor.NextHeader()
+		if err != nil {
+			t.Fatalf("next header: %v", err)
+		}
+		if header.Offset != offset {
+			t.Fatalf("block %d: offset %d, expecting %d", i, offset, header.Offset)
+		}
+	}
+
+	for _, i := range rand.Perm(len(offsets)) {
+		var meta int
+		var columns struct {
+			Bar []string
+		}
+		err = f.ReadBlockAt(offsets[i], &meta, &columns)
+		if err != nil {
+			t.Fatalf("read block at: %v", err)
+		}
+		if meta != i || columns.Bar[0] != fmt.Sprintf("%d", i) {
+			t.Fatalf("wrong block %d: %v %v", i, meta, columns)
+		}
+	}
+}
+
+func TestIterReverse(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	err = f.IterReverse([]string{"Foo"}, func(columns ...interface{}) bool {
+		t.Fatal("should not be called")
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter reverse: %v", err)
+	}
+
+	for i := 0; i < 50; i++ {
+		err = f.Append([]Foo{{i * 2, "a"}, {i*2 + 1, "b"}}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	n := 50
+	err = f.IterReverse([]string{"Foo", "Bar"}, func(columns ...interface{}) bool {
+		n--
+		foos := columns[0].([]int)
+		bars := columns[1].([]string)
+		if len(foos) != 2 || foos[0] != n*2 || foos[1] != n*2+1 || bars[0] != "a" || bars[1] != "b" {
+			t.Fatalf("got %v %v at %d", foos, bars, n)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter reverse: %v", err)
+	}
+	if n != 0 {
+		t.Fatalf("%d blocks not delivered", n)
+	}
+
+	// stop
+	n = 0
+	err = f.IterReverse([]string{"Foo"}, func(columns ...interface{}) bool {
+		n++
+		return n < 3
+	})
+	if err != nil {
+		t.Fatalf("iter reverse: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("got %d blocks", n)
+	}
+}
+
+func TestReadBlockMmap(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := NewWithOptions(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	}, Options{
+		Mmap: true,
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	var meta int
+	var columns struct {
+		Foo []int
+		Bar []string
+	}
+	check := func(n int) {
+		err := f.ReadBlock(n, &meta, &columns)
+		if err != nil {
+			t.Fatalf("read block %d: %v", n, err)
+		}
+		if meta != n || len(columns.Foo) != 1 || columns.Foo[0] != n || columns.Bar[0] != fmt.Sprintf("%d", n) {
+			t.Fatalf("got %d %v %v at %d", meta, columns.Foo, columns.Bar, n)
+		}
+	}
+	appendBlocks := func(from, to int) {
+		for i := from; i < to; i++ {
+			err = f.Append([]Foo{{i, fmt.Sprintf("%d", i)}}, i)
+			if err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+	}
+
+	appendBlocks(0, 10)
+	for i := 0; i < 10; i++ {
+		check(i)
+	}
+	// beyond the mapping
+	appendBlocks(10, 20)
+	for i := 19; i >= 0; i-- {
+		check(i)
+	}
+	if err := f.ReadBlock(20, &meta, &columns); err != ErrBlockOutOfRange {
+		t.Fatalf("expecting out of range, got %v", err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if f.mapped != nil {
+		t.Fatal("not unmapped")
+	}
+}
+
+func TestReadBlockMmapTruncate(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := NewWithOptions(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}, Options{
+		Mmap: true,
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	var offset int64
+	for i := 0; i < 3000; i++ {
+		offset, err = f.AppendAt([]Foo{{i}}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	var meta int
+	var columns struct {
+		Foo []int
+	}
+	if err := f.ReadBlock(2999, &meta, &columns); err != nil {
+		t.Fatalf("read block: %v", err)
+	}
+	if meta != 2999 {
+		t.Fatalf("got %d", meta)
+	}
+
+	if err := f.Truncate(1); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	if f.mapped != nil {
+		t.Fatal("not unmapped")
+	}
+	// not faulting on the bytes past the end
+	if err := f.ReadBlockAt(offset, &meta, &columns); err == nil {
+		t.Fatal("should fail")
+	}
+	if err := f.ReadBlock(0, &meta, &columns); err != nil {
+		t.Fatalf("read block: %v", err)
+	}
+	if meta != 0 || len(columns.Foo) != 1 || columns.Foo[0] != 0 {
+		t.Fatalf("got %d %v", meta, columns.Foo)
+	}
+}
+
+func TestLastMeta(t *testing.T) {
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	var meta int
+	if err := f.LastMeta(&meta); err != ErrEmpty {
+		t.Fatalf("expecting empty, got %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := f.Append([]struct{ Foo int }{{i}}, i*2); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		if err := f.LastMeta(&meta); err != nil {
+			t.Fatalf("last meta: %v", err)
+		}
+		if meta != i*2 {
+			t.Fatalf("got %d, expecting %d", meta, i*2)
+		}
+	}
+}
+
+func TestRawBlock(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	type rawFooSet struct {
+		Foo []int
+	}
+	type rawBarSet struct {
+		Bar []string
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &rawFooSet{}
+		case 1:
+			ret = &rawBarSet{}
+		}
+		return
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d.zstd", rand.Int63()))
+	f, err := New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	for i := 0; i < 3; i++ {
+		if err := f.Append([]Foo{{i, fmt.Sprintf("%d", i)}, {i * 2, "x"}}, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	meta, sets, err := f.RawBlock(1)
+	if err != nil {
+		t.Fatalf("raw block: %v", err)
+	}
+	if len(sets) != 2 {
+		t.Fatalf("got %d sets", len(sets))
+	}
+	var m int
+	if err := f.decodeMeta(meta, &m); err != nil {
+		t.Fatalf("decode meta: %v", err)
+	}
+	var fooSet rawFooSet
+	if err := DecodeColumnSet(CompressZstd, sets[0], &fooSet); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	var barSet rawBarSet
+	if err := DecodeColumnSet(CompressZstd, sets[1], &barSet); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if m != 1 || !reflect.DeepEqual(fooSet.Foo, []int{1, 2}) || !reflect.DeepEqual(barSet.Bar, []string{"1", "x"}) {
+		t.Fatalf("got %d %v %v", m, fooSet.Foo, barSet.Bar)
+	}
+
+	// the same from the mapped file
+	mapped, err := NewWithOptions(path, colSetsFn, Options{
+		Mmap: true,
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer mapped.Close()
+	meta2, sets2, err := mapped.RawBlock(1)
+	if err != nil {
+		t.Fatalf("raw block: %v", err)
+	}
+	if !reflect.DeepEqual(meta, meta2) || !reflect.DeepEqual(sets, sets2) {
+		t.Fatal("not match")
+	}
+
+	if _, _, err := f.RawBlock(3); err != ErrBlockOutOfRange {
+		t.Fatalf("got %v", err)
+	}
+}
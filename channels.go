@@ -0,0 +1,146 @@
+package rcf
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ColumnChannels streams the values of the requested columns, one channel per column, in row order.
+// channels are closed when the file is exhausted, the iteration fails, or cancel is called. every channel must be drained concurrently, or cancel called, to let the reading go on.
+// if the iteration fails, the last value of every channel is the error, an *Err. cancelling is not an error.
+func (f *File) ColumnChannels(cols []string) (map[string]<-chan interface{}, func(), error) {
+	order, unknown := f.iterOrder(cols)
+	if len(unknown) > 0 {
+		return nil, nil, makeErr(nil, fmt.Sprintf("no such column: %s", strings.Join(unknown, ", ")))
+	}
+
+	chans := make(map[string]<-chan interface{})
+	cs := make([]chan interface{}, 0, len(order))
+	for _, col := range order {
+		c := make(chan interface{}, 1024)
+		cs = append(cs, c)
+		chans[col] = c
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		defer func() {
+			for _, c := range cs {
+				close(c)
+			}
+		}()
+		values := make([]reflect.Value, len(cs))
+		err := f.IterContext(ctx, order, func(columns ...interface{}) bool {
+			for i, column := range columns {
+				values[i] = reflect.ValueOf(column)
+			}
+			// values are sent by rows, so that channels drained concurrently are not blocked by each other
+			for row, l := 0, blockRows(values); row < l; row++ {
+				for i, c := range cs {
+					select {
+					case c <- columnValue(values[i], row).Interface():
+					case <-ctx.Done():
+						return false
+					}
+				}
+			}
+			return true
+		})
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		iterErr := makeErr(err, "iterate columns")
+		for _, c := range cs {
+			select {
+			case c <- iterErr:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return chans, cancel, nil
+}
+
+// Block is a block delivered by Blocks
+type Block struct {
+	Offset  int64
+	Columns []interface{} // values of the requested columns, in the order of cols
+	Err     error         // error of the iteration, only set in the last value
+	meta    []byte
+	file    *File
+}
+
+// DecodeMeta decodes the meta of the block into target
+func (b Block) DecodeMeta(target interface{}) error {
+	if err := b.file.decodeMeta(b.meta, target); err != nil {
+		return makeKindErr(KindDecode, err, "decode meta")
+	}
+	return nil
+}
+
+// Blocks streams blocks with values of the requested columns. the channel is closed when the file is exhausted or cancel is called.
+// if the iteration fails, the last value has the error in its Err field.
+// cancel stops the reading, and drains the channel. it should be called if the channel is not drained to the end.
+func (f *File) Blocks(cols []string) (<-chan Block, func()) {
+	ch := make(chan Block, 1024)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			cancelCtx()
+			for range ch {
+			}
+		})
+	}
+
+	order, unknown := f.iterOrder(cols)
+	if len(unknown) > 0 {
+		ch <- Block{
+			Err: makeErr(nil, fmt.Sprintf("no such column: %s", strings.Join(unknown, ", "))),
+		}
+		close(ch)
+		return ch, cancel
+	}
+	// map columns to the order of cols
+	indexes := make([]int, len(cols))
+	for i, col := range cols {
+		for j, c := range order {
+			if c == col {
+				indexes[i] = j
+			}
+		}
+	}
+
+	go func() {
+		defer close(ch)
+		err := f.iterBlocks(ctx, order, nil, nil, true, func(header *BlockHeader, meta []byte, columns []interface{}) bool {
+			block := Block{
+				Offset:  header.Offset,
+				Columns: make([]interface{}, len(cols)),
+				meta:    meta,
+				file:    f,
+			}
+			for i, j := range indexes {
+				block.Columns[i] = columns[j]
+			}
+			select {
+			case ch <- block:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			ch <- Block{
+				Err: err,
+			}
+		}
+	}()
+
+	return ch, cancel
+}
@@ -0,0 +1,242 @@
+package rcf
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestColumnChannels(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	for i := 0; i < 8; i++ {
+		var rows []Foo
+		for j := 0; j < 100; j++ {
+			n := i*100 + j
+			rows = append(rows, Foo{n, fmt.Sprintf("%d", n)})
+		}
+		err = f.Append(rows, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	t.Run("stream", func(t *testing.T) {
+		chans, cancel, err := f.ColumnChannels([]string{"Bar", "Foo"})
+		if err != nil {
+			t.Fatalf("column channels: %v", err)
+		}
+		defer cancel()
+		wg := new(sync.WaitGroup)
+		wg.Add(2)
+		var foos []int
+		var bars []string
+		go func() {
+			defer wg.Done()
+			for v := range chans["Foo"] {
+				if err, ok := v.(error); ok {
+					t.Errorf("iterate: %v", err)
+					return
+				}
+				foos = append(foos, v.(int))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for v := range chans["Bar"] {
+				if err, ok := v.(error); ok {
+					t.Errorf("iterate: %v", err)
+					return
+				}
+				bars = append(bars, v.(string))
+			}
+		}()
+		wg.Wait()
+		if len(foos) != 800 || len(bars) != 800 {
+			t.Fatalf("got %d foos, %d bars", len(foos), len(bars))
+		}
+		for i, foo := range foos {
+			if foo != i || bars[i] != fmt.Sprintf("%d", i) {
+				t.Fatalf("value not match at %d: %d %s", i, foo, bars[i])
+			}
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		chans, cancel, err := f.ColumnChannels([]string{"Foo"})
+		if err != nil {
+			t.Fatalf("column channels: %v", err)
+		}
+		<-chans["Foo"]
+		cancel()
+		for v := range chans["Foo"] {
+			if _, ok := v.(error); ok {
+				t.Fatalf("got error %v", v)
+			}
+		}
+	})
+
+	t.Run("unknown column", func(t *testing.T) {
+		_, _, err := f.ColumnChannels([]string{"Foo", "Baz"})
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	})
+
+	t.Run("torn", func(t *testing.T) {
+		if err := f.Sync(); err != nil {
+			t.Fatalf("sync: %v", err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tornPath := path + ".torn"
+		bs, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(tornPath, bs[:info.Size()-3], 0644); err != nil {
+			t.Fatal(err)
+		}
+		torn, err := Open(tornPath, f.colSetsFn)
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		defer torn.Close()
+		chans, cancel, err := torn.ColumnChannels([]string{"Foo"})
+		if err != nil {
+			t.Fatalf("column channels: %v", err)
+		}
+		defer cancel()
+		var last interface{}
+		for v := range chans["Foo"] {
+			last = v
+		}
+		if err, ok := last.(error); !ok || !errors.Is(err, ErrTornBlock) {
+			t.Fatalf("got %v", last)
+		}
+	})
+}
+
+func TestBlocks(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	for i := 0; i < 8; i++ {
+		var rows []Foo
+		for j := 0; j < 10; j++ {
+			n := i*10 + j
+			rows = append(rows, Foo{n, fmt.Sprintf("%d", n)})
+		}
+		err = f.Append(rows, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	t.Run("range", func(t *testing.T) {
+		blocks, cancel := f.Blocks([]string{"Bar", "Foo"})
+		defer cancel()
+		i := 0
+		for block := range blocks {
+			if block.Err != nil {
+				t.Fatalf("block: %v", block.Err)
+			}
+			var meta int
+			if err := block.DecodeMeta(&meta); err != nil {
+				t.Fatalf("decode meta: %v", err)
+			}
+			if meta != i {
+				t.Fatalf("got meta %d, expected %d", meta, i)
+			}
+			bars := block.Columns[0].([]string)
+			foos := block.Columns[1].([]int)
+			if len(foos) != 10 || len(bars) != 10 {
+				t.Fatalf("got %d %d values", len(foos), len(bars))
+			}
+			for j, foo := range foos {
+				if foo != i*10+j || bars[j] != fmt.Sprintf("%d", foo) {
+					t.Fatalf("got %d %s", foo, bars[j])
+				}
+			}
+			i++
+		}
+		if i != 8 {
+			t.Fatalf("got %d blocks", i)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		blocks, cancel := f.Blocks([]string{"Foo"})
+		block := <-blocks
+		if block.Err != nil {
+			t.Fatalf("block: %v", block.Err)
+		}
+		cancel()
+		cancel()
+		if _, ok := <-blocks; ok {
+			t.Fatal("channel not closed")
+		}
+	})
+
+	t.Run("unknown column", func(t *testing.T) {
+		blocks, cancel := f.Blocks([]string{"Baz"})
+		defer cancel()
+		block, ok := <-blocks
+		if !ok || block.Err == nil {
+			t.Fatal("expecting error")
+		}
+		if _, ok := <-blocks; ok {
+			t.Fatal("channel not closed")
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package rcf
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// blockChecksum returns the checksum of the statistics, meta and column set payloads of a block
+func blockChecksum(stats, meta []byte, sets [][]byte) uint32 {
+	sum := crc32.Checksum(stats, castagnoli)
+	sum = crc32.Update(sum, castagnoli, meta)
+	for _, bs := range sets {
+		sum = crc32.Update(sum, castagnoli, bs)
+	}
+	return sum
+}
+
+// verify checks the statistics and the payload of the block against the checksum, if any
+func (h *BlockHeader) verify(payload []byte) error {
+	if !h.Checksummed {
+		return nil
+	}
+	if crc32.Update(crc32.Checksum(h.stats, castagnoli), castagnoli, payload) != h.Checksum {
+		return makeErr(ErrChecksum, fmt.Sprintf("block at offset %d", h.Offset))
+	}
+	return nil
+}
+
+// VerifyIntegrity reads all blocks and returns the error of the first corrupted or truncated one.
+// for files without checksums, only the framing is checked.
+func (f *File) VerifyIntegrity() error {
+	cursor, err := f.Cursor()
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	for {
+		_, err := cursor.NextHeader()
+		if err == io.EOF { // no more
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, err = cursor.ReadPayload()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func isChecksumError(err error) bool {
+	if e, ok := err.(*Err); ok {
+		err = e.Err
+	}
+	return err == ErrChecksum
+}
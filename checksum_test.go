@@ -0,0 +1,114 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	for i := 0; i < 10; i++ {
+		err = f.Append([]Foo{{i, "foo"}}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	err = f.VerifyIntegrity()
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	// flip a byte in the last column set of the fifth block
+	cursor, err := f.Cursor()
+	if err != nil {
+		t.Fatalf("cursor: %v", err)
+	}
+	var header *BlockHeader
+	for i := 0; i < 5; i++ {
+		header, err = cursor.NextHeader()
+		if err != nil {
+			t.Fatalf("next header: %v", err)
+		}
+	}
+	cursor.Close()
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos := header.Offset + header.Size() - 1
+	b := make([]byte, 1)
+	if _, err := file.ReadAt(b, pos); err != nil {
+		t.Fatal(err)
+	}
+	b[0] ^= 0xff
+	if _, err := file.WriteAt(b, pos); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	err = f.VerifyIntegrity()
+	if !isChecksumError(err) {
+		t.Fatalf("expecting checksum error, got %v", err)
+	}
+	if e := err.(*Err); e.Info != fmt.Sprintf("block at offset %d", header.Offset) {
+		t.Fatalf("bad error info: %s", e.Info)
+	}
+
+	// readers not using the corrupted column set also detect it
+	err = f.IterMetas(func(meta int) bool {
+		return true
+	})
+	if !isChecksumError(err) {
+		t.Fatalf("expecting checksum error, got %v", err)
+	}
+	err = f.Iter([]string{"Foo"}, func(columns ...interface{}) bool {
+		return true
+	})
+	if !isChecksumError(err) {
+		t.Fatalf("expecting checksum error, got %v", err)
+	}
+	var meta int
+	var columns struct {
+		Bar []string
+	}
+	err = f.IterAll(&meta, &columns, func() bool {
+		return true
+	})
+	if !isChecksumError(err) {
+		t.Fatalf("expecting checksum error, got %v", err)
+	}
+	err = f.ReadBlock(4, &meta, &columns)
+	if !isChecksumError(err) {
+		t.Fatalf("expecting checksum error, got %v", err)
+	}
+	err = f.ReadBlock(3, &meta, &columns)
+	if err != nil {
+		t.Fatalf("read block: %v", err)
+	}
+}
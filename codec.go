@@ -0,0 +1,60 @@
+package rcf
+
+import (
+	"encoding/gob"
+	"io"
+
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// Codec encodes metas and column sets. payloads written by Encode are compressed by the File.
+type Codec interface {
+	// ID identifies the codec in file headers. 0 to 127 are reserved for codecs of this package.
+	ID() uint8
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+const (
+	_CODEC_GOB = iota
+	_CODEC_MSGPACK
+)
+
+// GobCodec is the default codec
+type GobCodec struct{}
+
+var _ Codec = GobCodec{}
+
+func (GobCodec) ID() uint8 {
+	return _CODEC_GOB
+}
+
+func (GobCodec) Encode(w io.Writer, v interface{}) error {
+	return gob.NewEncoder(w).Encode(v)
+}
+
+func (GobCodec) Decode(r io.Reader, v interface{}) error {
+	return gob.NewDecoder(r).Decode(v)
+}
+
+// MsgpackCodec encodes with msgpack. it is the codec of new files whose path has a "msgpack" part, like "foo.msgpack.rcf".
+type MsgpackCodec struct{}
+
+var _ Codec = MsgpackCodec{}
+
+func (MsgpackCodec) ID() uint8 {
+	return _CODEC_MSGPACK
+}
+
+func (MsgpackCodec) Encode(w io.Writer, v interface{}) error {
+	return msgpack.NewEncoder(w).Encode(v)
+}
+
+func (MsgpackCodec) Decode(r io.Reader, v interface{}) error {
+	return msgpack.NewDecoder(r).Decode(v)
+}
+
+var builtinCodecs = map[uint8]Codec{
+	_CODEC_GOB:     GobCodec{},
+	_CODEC_MSGPACK: MsgpackCodec{},
+}
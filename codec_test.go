@@ -0,0 +1,142 @@
+package rcf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) ID() uint8 {
+	return 200
+}
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+func TestCustomCodec(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d.snappy", rand.Int63()))
+	f, err := NewWithOptions(path, colSetsFn, Options{
+		Codec: jsonCodec{},
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		err = f.Append([]Foo{{i, fmt.Sprintf("%d", i)}}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	f.Close()
+
+	// codec mismatch
+	if _, err := New(path, colSetsFn); err == nil {
+		t.Fatalf("should fail without codec")
+	}
+	if _, err := NewWithOptions(path, colSetsFn, Options{
+		Codec: MsgpackCodec{},
+	}); err == nil {
+		t.Fatalf("should fail with wrong codec")
+	}
+
+	f, err = NewWithOptions(path, colSetsFn, Options{
+		Codec: jsonCodec{},
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	n := 0
+	var meta int
+	var columns struct {
+		Foo []int
+		Bar []string
+	}
+	err = f.IterAll(&meta, &columns, func() bool {
+		if meta != n || columns.Foo[0] != n || columns.Bar[0] != fmt.Sprintf("%d", n) {
+			t.Fatalf("wrong iter value %d %v %v", n, meta, columns)
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter all: %v", err)
+	}
+	if n != 10 {
+		t.Fatalf("got %d blocks", n)
+	}
+}
+
+func TestStoredCodec(t *testing.T) {
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d.msgpack", rand.Int63()))
+	f, err := New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	err = f.Append([]struct{ Foo int }{{42}}, 42)
+	if err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	f.Close()
+
+	renamed := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	if err := os.Rename(path, renamed); err != nil {
+		t.Fatal(err)
+	}
+	f, err = New(renamed, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	if f.codec.ID() != _CODEC_MSGPACK {
+		t.Fatalf("stored codec not used")
+	}
+	err = f.IterMetas(func(meta int) bool {
+		if meta != 42 {
+			t.Fatalf("got %d", meta)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter metas: %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package rcf
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// AppendColumns appends a block from column slices, like "Foo": []int{...}, without transposing rows.
+// every column of the schema must be provided, and all slices must have the same length.
+func (f *File) AppendColumns(columns map[string]interface{}, meta interface{}) error {
+	values := make(map[string]reflect.Value, len(columns))
+	length := -1
+	for _, set := range f.colSets {
+		for _, col := range set {
+			column, ok := columns[col]
+			if !ok {
+				return makeErr(nil, fmt.Sprintf("column %s not provided", col))
+			}
+			value := reflect.ValueOf(column)
+			if value.Kind() != reflect.Slice {
+				return makeErr(nil, fmt.Sprintf("column %s is not slice", col))
+			}
+			if length == -1 {
+				length = value.Len()
+			} else if value.Len() != length {
+				return makeErr(nil, fmt.Sprintf("column %s has %d values, expecting %d", col, value.Len(), length))
+			}
+			values[col] = value
+		}
+	}
+	if len(values) != len(columns) {
+		for col := range columns {
+			if _, ok := values[col]; !ok {
+				return makeErr(nil, fmt.Sprintf("no such column: %s", col))
+			}
+		}
+	}
+	if f.sortColumn != "" {
+		order := sortedOrder(values[f.sortColumn])
+		for col, value := range values {
+			values[col] = permute(value, order)
+		}
+	}
+	_, err := f.appendColumns(values, meta)
+	return err
+}
+
+// permute returns a new slice of elements of column in order
+func permute(column reflect.Value, order []int) reflect.Value {
+	ret := reflect.MakeSlice(column.Type(), len(order), len(order))
+	for i, j := range order {
+		ret.Index(i).Set(column.Index(j))
+	}
+	return ret
+}
+
+// iterOrder returns the requested columns in the order Iter passes them to callbacks, which is the schema order, and the unknown columns
+func (f *File) iterOrder(cols []string) (order []string, unknown []string) {
+	requested := make(map[string]bool)
+	for _, col := range cols {
+		requested[col] = true
+	}
+	for _, set := range f.colSets {
+		for _, col := range set {
+			if requested[col] {
+				order = append(order, col)
+				delete(requested, col)
+			}
+		}
+	}
+	for _, col := range cols {
+		if requested[col] {
+			unknown = append(unknown, col)
+			delete(requested, col)
+		}
+	}
+	return
+}
+
+// columnType returns the slice type of column col
+func (f *File) columnType(col string) (reflect.Type, bool) {
+	for n, set := range f.colSets {
+		for _, c := range set {
+			if c == col {
+				field, _ := reflect.TypeOf(f.colSetsFn(n)).Elem().FieldByName(col)
+				return field.Type, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// ColumnSets returns the column names of each column set, in schema order
+func (f *File) ColumnSets() [][]string {
+	ret := make([][]string, len(f.colSets))
+	for i, set := range f.colSets {
+		ret[i] = append([]string{}, set...)
+	}
+	return ret
+}
+
+// Columns returns the names of all columns, in schema order
+func (f *File) Columns() (ret []string) {
+	for _, set := range f.colSets {
+		ret = append(ret, set...)
+	}
+	return
+}
+
+// HasColumn reports whether the schema has column name
+func (f *File) HasColumn(name string) bool {
+	_, ok := f.columnType(name)
+	return ok
+}
+
+// ColumnSet returns the index of the column set of column name, and false if there is no such column
+func (f *File) ColumnSet(name string) (int, bool) {
+	for n, set := range f.colSets {
+		for _, col := range set {
+			if col == name {
+				return n, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Plan returns the indexes of column sets decoded by iterations of cols. sets are decoded as a whole, so columns often read together are better put in the same set.
+// columns not in the schema are ignored.
+func (f *File) Plan(cols []string) []int {
+	columns := make(map[string]bool)
+	for _, col := range cols {
+		columns[col] = true
+	}
+	ret := []int{}
+	for n, decode := range f.setsToDecode(columns) {
+		if decode {
+			ret = append(ret, n)
+		}
+	}
+	return ret
+}
+
+// setsToDecode reports for each column set whether it has any of columns
+func (f *File) setsToDecode(columns map[string]bool) []bool {
+	ret := make([]bool, len(f.colSets))
+	for n, set := range f.colSets {
+		for _, col := range set {
+			if columns[col] {
+				ret[n] = true
+			}
+		}
+	}
+	return ret
+}
+
+// ReadColumn appends values of column col of all blocks to the slice targetSlicePtr points to, like *[]int for an int column. only the column set of col is decoded.
+// if block headers have row counts, the slice is grown once for all rows.
+func (f *File) ReadColumn(col string, targetSlicePtr interface{}) error {
+	ptr := reflect.ValueOf(targetSlicePtr)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() || ptr.Elem().Kind() != reflect.Slice {
+		return makeErr(nil, fmt.Sprintf("expecting pointer to slice, got %T", targetSlicePtr))
+	}
+	slice := ptr.Elem()
+	t, ok := f.columnType(col)
+	if !ok {
+		return makeErr(nil, fmt.Sprintf("no such column: %s", col))
+	}
+	if t.Elem() != slice.Type().Elem() {
+		return makeErr(nil, fmt.Sprintf("column %s is %v, not %v", col, t, slice.Type()))
+	}
+	if f.flags&_FLAG_ROWS > 0 {
+		n, err := f.NumRows()
+		if err != nil {
+			return err
+		}
+		if l := slice.Len(); int64(slice.Cap()-l) < n {
+			grown := reflect.MakeSlice(slice.Type(), l, l+int(n))
+			reflect.Copy(grown, slice)
+			slice.Set(grown)
+		}
+	}
+	return f.Iter([]string{col}, func(columns ...interface{}) bool {
+		slice.Set(reflect.AppendSlice(slice, reflect.ValueOf(columns[0])))
+		return true
+	})
+}
@@ -0,0 +1,369 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAppendColumns(t *testing.T) {
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := NewWithOptions(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+				Baz []bool
+			}{}
+		}
+		return
+	}, Options{
+		SortColumn: "Foo",
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	err = f.AppendColumns(map[string]interface{}{
+		"Foo": []int{3, 1, 2},
+		"Bar": []string{"c", "a", "b"},
+		"Baz": []bool{false, true, false},
+	}, "meta")
+	if err != nil {
+		t.Fatalf("append columns: %v", err)
+	}
+
+	var meta string
+	var columns struct {
+		Foo []int
+		Bar []string
+		Baz []bool
+	}
+	n := 0
+	err = f.IterAll(&meta, &columns, func() bool {
+		if meta != "meta" {
+			t.Fatalf("bad meta")
+		}
+		if fmt.Sprint(columns.Foo, columns.Bar, columns.Baz) != "[1 2 3] [a b c] [true false false]" {
+			t.Fatalf("bad columns: %v", columns)
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter all: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("got %d blocks", n)
+	}
+
+	for name, columns := range map[string]map[string]interface{}{
+		"missing": {
+			"Foo": []int{1},
+			"Bar": []string{"a"},
+		},
+		"unknown": {
+			"Foo": []int{1},
+			"Bar": []string{"a"},
+			"Baz": []bool{true},
+			"Qux": []int{1},
+		},
+		"length": {
+			"Foo": []int{1, 2},
+			"Bar": []string{"a"},
+			"Baz": []bool{true},
+		},
+		"type": {
+			"Foo": []int64{1},
+			"Bar": []string{"a"},
+			"Baz": []bool{true},
+		},
+		"not slice": {
+			"Foo": 1,
+			"Bar": []string{"a"},
+			"Baz": []bool{true},
+		},
+	} {
+		if err := f.AppendColumns(columns, "meta"); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestColumns(t *testing.T) {
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+				Baz []bool
+			}{}
+		case 2:
+			ret = &struct {
+				Qux []float64
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	sets := f.ColumnSets()
+	if !reflect.DeepEqual(sets, [][]string{{"Foo"}, {"Bar", "Baz"}, {"Qux"}}) {
+		t.Fatalf("got %v", sets)
+	}
+	sets[1][0] = "Quux"
+	if f.ColumnSets()[1][0] != "Bar" {
+		t.Fatal("internal state mutated")
+	}
+
+	columns := f.Columns()
+	if !reflect.DeepEqual(columns, []string{"Foo", "Bar", "Baz", "Qux"}) {
+		t.Fatalf("got %v", columns)
+	}
+}
+
+func TestDuplicatedColumn(t *testing.T) {
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	_, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+				Foo []int
+			}{}
+		}
+		return
+	})
+	if err == nil {
+		t.Fatal("should fail")
+	}
+	if !strings.Contains(err.Error(), "column Foo is in both column set 0 and 1") {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestColumnSet(t *testing.T) {
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+				Baz []bool
+			}{}
+		case 2:
+			ret = &struct {
+				Qux  [][]int
+				Quux []map[string]string
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	for col, expected := range map[string]int{
+		"Foo":  0,
+		"Bar":  1,
+		"Baz":  1,
+		"Qux":  2,
+		"Quux": 2,
+	} {
+		n, ok := f.ColumnSet(col)
+		if !ok || n != expected {
+			t.Fatalf("%s: got %d %v", col, n, ok)
+		}
+	}
+	if _, ok := f.ColumnSet("Corge"); ok {
+		t.Fatal("unknown column found")
+	}
+}
+
+func TestUnknownColumns(t *testing.T) {
+	schema := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, schema)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	if err := f.Append([]struct{ Foo int }{{1}}, 1); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	if !f.HasColumn("Foo") || f.HasColumn("Bar") {
+		t.Fatal("bad HasColumn")
+	}
+	err = f.Iter([]string{"Foo", "Bar", "Baz"}, func(columns ...interface{}) bool {
+		t.Fatal("should not be called")
+		return true
+	})
+	if err == nil || !strings.Contains(err.Error(), "Bar, Baz") {
+		t.Fatalf("got %v", err)
+	}
+	if err := f.IterRows([]string{"Bar"}, func(bar int) bool { return true }); err == nil {
+		t.Fatal("expecting error")
+	}
+
+	f2, err := NewWithOptions(path, schema, Options{
+		AllowUnknownColumns: true,
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f2.Close()
+	n := 0
+	err = f2.Iter([]string{"Foo", "Bar"}, func(columns ...interface{}) bool {
+		if len(columns) != 1 {
+			t.Fatalf("got %d columns", len(columns))
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("got %d blocks", n)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+				Baz []bool
+			}{}
+		case 2:
+			ret = &struct {
+				Qux  [][]int
+				Quux []map[string]string
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	for _, c := range []struct {
+		cols     []string
+		expected []int
+	}{
+		{nil, []int{}},
+		{[]string{"Foo"}, []int{0}},
+		{[]string{"Bar"}, []int{1}},
+		{[]string{"Bar", "Baz"}, []int{1}},
+		{[]string{"Quux", "Foo"}, []int{0, 2}},
+		{[]string{"Foo", "Baz", "Qux"}, []int{0, 1, 2}},
+		{[]string{"Foo", "NoSuchColumn"}, []int{0}},
+	} {
+		if got := f.Plan(c.cols); !reflect.DeepEqual(got, c.expected) {
+			t.Fatalf("%v: got %v, expecting %v", c.cols, got, c.expected)
+		}
+	}
+}
+
+func TestReadColumn(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	var expected []int
+	for i := 0; i < 10; i++ {
+		var rows []Foo
+		for j := 0; j < i; j++ {
+			rows = append(rows, Foo{i*100 + j, "bar"})
+			expected = append(expected, i*100+j)
+		}
+		if err := f.Append(rows, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	foos := []int{-1}
+	if err := f.ReadColumn("Foo", &foos); err != nil {
+		t.Fatalf("read column: %v", err)
+	}
+	if !reflect.DeepEqual(foos, append([]int{-1}, expected...)) {
+		t.Fatalf("got %v", foos)
+	}
+	var bars []string
+	if err := f.ReadColumn("Bar", &bars); err != nil {
+		t.Fatalf("read column: %v", err)
+	}
+	if len(bars) != len(expected) || bars[0] != "bar" {
+		t.Fatalf("got %v", bars)
+	}
+
+	var strs []string
+	if err := f.ReadColumn("Foo", &strs); err == nil {
+		t.Fatal("expecting error")
+	}
+	if err := f.ReadColumn("Qux", &foos); err == nil {
+		t.Fatal("expecting error")
+	}
+	if err := f.ReadColumn("Foo", foos); err == nil {
+		t.Fatal("expecting error")
+	}
+}
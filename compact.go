@@ -0,0 +1,191 @@
+package rcf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+)
+
+// CompactOptions configures CompactWithOptions
+type CompactOptions struct {
+	// RequireSameMeta makes compacting fail if blocks merged into one have different metas, compared by their encoded bytes.
+	// if false, merged blocks keep the meta of the first block.
+	RequireSameMeta bool
+}
+
+// Compact is CompactWithOptions with default options
+func (f *File) Compact(targetRowsPerBlock int) error {
+	return f.CompactWithOptions(targetRowsPerBlock, CompactOptions{})
+}
+
+// CompactWithOptions rewrites the file with successive blocks merged into blocks of at least targetRowsPerBlock rows, except the last one.
+// blocks are written to a temporary file in the same directory, which then replaces the file by renaming. the format of the file is kept.
+// it must not be called concurrently with appends.
+func (f *File) CompactWithOptions(targetRowsPerBlock int, opts CompactOptions) (err error) {
+	if err := f.checkWritable(); err != nil {
+		return err
+	}
+	if targetRowsPerBlock <= 0 {
+		return makeErr(nil, "target rows per block must be positive")
+	}
+	if _, ok := f.backend.(*memoryFile); ok {
+		return makeErr(nil, "compacting is not supported for memory files")
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".compact-")
+	if err != nil {
+		return makeErr(err, "create temporary file")
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer func() {
+		if err != nil {
+			os.Remove(tmpPath)
+		}
+	}()
+	if err := createWithHeader(tmpPath, f); err != nil {
+		return err
+	}
+	dst, err := NewWithOptions(tmpPath, f.colSetsFn, Options{
+		Codec:     f.codec,
+		GobStream: f.gobStream,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if dst != nil {
+			dst.Close()
+		}
+	}()
+	dst.sortColumn = f.sortColumn
+	dst.dictFields = f.dictFields
+	dst.deltaFields = f.deltaFields
+
+	cols := f.Columns()
+	var group map[string]reflect.Value
+	var groupMeta []byte
+	rows := 0
+	flush := func() error {
+		if group == nil {
+			return nil
+		}
+		if dst.sortColumn != "" {
+			order := sortedOrder(group[dst.sortColumn])
+			for col, column := range group {
+				group[col] = permute(column, order)
+			}
+		}
+		sets, err := dst.columnsSets(group)
+		if err != nil {
+			return err
+		}
+		meta := groupMeta
+		_, err = dst.appendSetsMeta(sets, func() ([]byte, error) {
+			return meta, nil
+		})
+		group = nil
+		rows = 0
+		return err
+	}
+	var cbErr error
+	err = f.iterBlocks(context.Background(), cols, nil, nil, true, func(_ *BlockHeader, meta []byte, columns []interface{}) bool {
+		if group == nil {
+			group = make(map[string]reflect.Value)
+			groupMeta = meta
+		} else if opts.RequireSameMeta && !bytes.Equal(meta, groupMeta) {
+			cbErr = makeErr(nil, "merged blocks have different metas")
+			return false
+		}
+		values := make([]reflect.Value, len(cols))
+		for i, column := range columns {
+			values[i] = reflect.ValueOf(column)
+		}
+		n := blockRows(values)
+		if n == 0 {
+			return true
+		}
+		for i, col := range cols {
+			column := values[i]
+			if column.Len() < n { // of a set added after the block was appended
+				padded := reflect.MakeSlice(column.Type(), n, n)
+				reflect.Copy(padded, column)
+				column = padded
+			}
+			if merged, ok := group[col]; ok {
+				column = reflect.AppendSlice(merged, column)
+			}
+			group[col] = column
+		}
+		rows += n
+		if rows >= targetRowsPerBlock {
+			if cbErr = flush(); cbErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err == nil {
+		err = cbErr
+	}
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return err
+	}
+	err = dst.Close()
+	dst = nil
+	if err != nil {
+		return makeErr(err, "close temporary file")
+	}
+
+	return f.replace(tmpPath)
+}
+
+// replace replaces the file by the file at tmpPath by renaming, and opens it again
+func (f *File) replace(tmpPath string) (err error) {
+	f.Lock()
+	defer f.Unlock()
+	if err := f.flush(); err != nil {
+		return err
+	}
+	// open or mapped files can not be replaced on some platforms
+	if err := f.unmap(); err != nil {
+		return err
+	}
+	f.backend.Close()
+	err = os.Rename(tmpPath, f.path)
+	if err != nil {
+		err = makeErr(err, "rename")
+	}
+	if reopenErr := f.reopen(); reopenErr != nil {
+		return makeErr(reopenErr, fmt.Sprintf("reopen %s", f.path))
+	}
+	return err
+}
+
+// reopen opens the file at the path again, after the backend is closed, and the file may be replaced by another file. the mutex must be held.
+// the file is mapped again by the next read if Options.Mmap is set.
+func (f *File) reopen() error {
+	file, err := os.OpenFile(f.path, os.O_RDWR, 0644)
+	if err != nil {
+		return makeErr(err, "open file")
+	}
+	f.backend = &osBackend{file, f.path}
+	if f.buffer != nil {
+		f.buffer.Reset(backendWriter{f})
+	}
+	f.blockIndex = nil
+	f.validateOnce = sync.Once{}
+	f.validateErr = nil
+	for i := range f.streamEncoders {
+		f.streamEncoders[i] = nil
+	}
+	return f.initHeader(Options{Codec: f.codec})
+}
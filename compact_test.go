@@ -0,0 +1,213 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompact(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d.snappy", rand.Int63()))
+	f, err := New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	for i := 0; i < 1000; i++ {
+		if err := f.Append([]Foo{{i, fmt.Sprintf("%d", i)}}, i/100); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	before, err := f.Stats()
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+
+	// metas differ in blocks of 150 rows
+	if err := f.CompactWithOptions(150, CompactOptions{
+		RequireSameMeta: true,
+	}); err == nil {
+		t.Fatal("should fail")
+	}
+
+	if err := f.CompactWithOptions(100, CompactOptions{
+		RequireSameMeta: true,
+	}); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	after, err := f.Stats()
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if after.Blocks != 10 {
+		t.Fatalf("got %d blocks", after.Blocks)
+	}
+	if after.FileSize >= before.FileSize {
+		t.Fatalf("got %d bytes, %d bytes before compacting", after.FileSize, before.FileSize)
+	}
+	if f.compressMethod != _COMPRESS_SNAPPY {
+		t.Fatalf("got compression %d", f.compressMethod)
+	}
+
+	check := func(f *File) {
+		var meta int
+		var columns struct {
+			Foo []int
+			Bar []string
+		}
+		n := 0
+		err := f.IterAll(&meta, &columns, func() bool {
+			if len(columns.Foo) != 100 || meta != columns.Foo[0]/100 {
+				t.Fatalf("got %d rows, meta %d", len(columns.Foo), meta)
+			}
+			for i, foo := range columns.Foo {
+				if foo != n || columns.Bar[i] != fmt.Sprintf("%d", n) {
+					t.Fatalf("got %d %s, expecting %d", foo, columns.Bar[i], n)
+				}
+				n++
+			}
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter all: %v", err)
+		}
+		if n != 1000 {
+			t.Fatalf("got %d rows", n)
+		}
+	}
+	check(f)
+	var meta int
+	var columns struct {
+		Foo []int
+	}
+	if err := f.ReadBlock(9, &meta, &columns); err != nil {
+		t.Fatalf("read block: %v", err)
+	}
+	if meta != 9 || columns.Foo[0] != 900 {
+		t.Fatalf("got %d %v", meta, columns.Foo[:1])
+	}
+
+	// appending after compacting
+	if err := f.Append([]Foo{{1000, "1000"}}, 10); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if n, err := f.Count(); err != nil || n != 11 {
+		t.Fatalf("got %d %v", n, err)
+	}
+
+	// keeping the first meta
+	if err := f.Compact(300); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	var metas []int
+	if err := f.IterMetas(func(meta int) bool {
+		metas = append(metas, meta)
+		return true
+	}); err != nil {
+		t.Fatalf("iter metas: %v", err)
+	}
+	if fmt.Sprint(metas) != "[0 3 6 9]" {
+		t.Fatalf("got %v", metas)
+	}
+	rows, err := f.NumRows()
+	if err != nil {
+		t.Fatalf("num rows: %v", err)
+	}
+	if rows != 1001 {
+		t.Fatalf("got %d rows", rows)
+	}
+}
+
+func TestCompactAddedSet(t *testing.T) {
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := f.Append([]struct{ Foo int }{{i}}, 0); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	// a set added
+	f, err = New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	type Row struct {
+		Foo int
+		Bar string
+	}
+	for i := 3; i < 5; i++ {
+		if err := f.Append([]Row{{i, fmt.Sprintf("%d", i)}}, 0); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	if err := f.Compact(100); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	n, err := f.Count()
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("got %d blocks", n)
+	}
+	var meta int
+	var columns struct {
+		Foo []int
+		Bar []string
+	}
+	if err := f.ReadBlock(0, &meta, &columns); err != nil {
+		t.Fatalf("read block: %v", err)
+	}
+	if !reflect.DeepEqual(columns.Foo, []int{0, 1, 2, 3, 4}) || !reflect.DeepEqual(columns.Bar, []string{"", "", "", "3", "4"}) {
+		t.Fatalf("got %v %q", columns.Foo, columns.Bar)
+	}
+}
@@ -0,0 +1,191 @@
+package rcf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+)
+
+// CopyBlocks appends blocks [from, to) of f to dst. schemas of the two files must be compatible.
+// if the files share compression, codec and block layout, blocks are copied as bytes. otherwise column sets are decoded and encoded again, and metas are recompressed,
+// or decoded into interface{} and encoded again if codecs differ, which fails for gob metas of non-interface types.
+// files written in gob stream mode can not be copied from.
+func (f *File) CopyBlocks(dst *File, from, to int) error {
+	if f.gobStream {
+		return makeErr(nil, "copying blocks is not supported in gob stream mode")
+	}
+	if from < 0 || to < from {
+		return ErrBlockOutOfRange
+	}
+	if ok, diff := SchemasCompatible(f.colSetsFn, dst.colSetsFn); !ok {
+		return makeErr(nil, fmt.Sprintf("schema not compatible: %s", diff))
+	}
+	if from == to {
+		return nil
+	}
+	start, ok, err := f.blockOffset(from)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrBlockOutOfRange
+	}
+	end, ok, err := f.blockOffset(to)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if _, ok, _ := f.blockOffset(to - 1); !ok {
+			return ErrBlockOutOfRange
+		}
+		f.Lock()
+		end = f.blockIndexEnd
+		f.Unlock()
+	}
+
+	if f.formatDiff(dst) == "" && !dst.gobStream {
+		return f.copyRaw(dst, start, end)
+	}
+
+	cursor, err := f.Cursor()
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	_, err = cursor.Seek(start, os.SEEK_SET)
+	if err != nil {
+		return err
+	}
+	toDecode := make([]bool, len(f.colSets))
+	for i := range toDecode {
+		toDecode[i] = true
+	}
+	for i := from; i < to; i++ {
+		_, err := cursor.NextHeader()
+		if err == io.EOF {
+			err = makeErr(io.ErrUnexpectedEOF, "read block header")
+		}
+		if err != nil {
+			return err
+		}
+		metaBytes, setBytes, err := cursor.readParts(true, toDecode)
+		if err != nil {
+			return err
+		}
+		sets := make([]interface{}, len(f.colSets))
+		for n := range sets {
+			if n >= len(setBytes) { // set added to the schema after the block was appended
+				sets[n] = dst.emptySet(n).Addr().Interface()
+				continue
+			}
+			s := f.colSetsFn(n)
+			if err := f.decodeSet(nil, n, setBytes[n], &s); err != nil {
+				return makeKindErr(KindDecode, err, "decode column set")
+			}
+			// to the type of dst
+			d := dst.colSetsFn(n)
+			v := reflect.ValueOf(d).Elem()
+			v.Set(reflect.ValueOf(s).Elem().Convert(v.Type()))
+			sets[n] = d
+		}
+		_, err = dst.appendSetsMeta(sets, func() ([]byte, error) {
+			if len(metaBytes) == 0 { // nil meta
+				return nil, nil
+			}
+			if f.codec.ID() == dst.codec.ID() {
+				data, err := ioutil.ReadAll(f.metaDecompressReader(bytes.NewReader(metaBytes)))
+				if err != nil {
+					return nil, err
+				}
+				buf := new(bytes.Buffer)
+				w := dst.metaCompressWriter(buf)
+				if _, err := w.Write(data); err != nil {
+					w.Close()
+					return nil, err
+				}
+				if err := w.Close(); err != nil {
+					return nil, err
+				}
+				return buf.Bytes(), nil
+			}
+			var meta interface{}
+			if err := f.decodeMeta(metaBytes, &meta); err != nil {
+				return nil, err
+			}
+			return dst.encodeMeta(meta)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AppendFile appends all blocks of other to f as bytes, without decoding. schemas must be compatible, and the files must share compression, codec and block layout.
+// files written in gob stream mode can not be appended or appended to.
+func (f *File) AppendFile(other *File) error {
+	if f.gobStream || other.gobStream {
+		return makeErr(nil, "appending files is not supported in gob stream mode")
+	}
+	if ok, diff := SchemasCompatible(other.colSetsFn, f.colSetsFn); !ok {
+		return makeErr(nil, fmt.Sprintf("schema not compatible: %s", diff))
+	}
+	if diff := other.formatDiff(f); diff != "" {
+		return makeErr(nil, fmt.Sprintf("format not compatible: %s", diff))
+	}
+	offsets, err := other.blockOffsets()
+	if err != nil {
+		return err
+	}
+	if len(offsets) == 0 {
+		return nil
+	}
+	other.Lock()
+	end := other.blockIndexEnd
+	other.Unlock()
+	size, err := other.dataSize()
+	if err != nil {
+		return err
+	}
+	if end > size {
+		last := offsets[len(offsets)-1]
+		return makeKindErr(KindTornBlock, &TornBlockError{
+			Offset:   last,
+			Expected: end - last,
+			Got:      size - last,
+		}, "torn block")
+	}
+	return other.copyRaw(f, offsets[0], end)
+}
+
+// formatDiff describes the difference of encodings of f and dst that prevents copying blocks as bytes, or returns an empty string
+func (f *File) formatDiff(dst *File) string {
+	switch {
+	case f.compressMethod != dst.compressMethod:
+		return fmt.Sprintf("compression method %d, not %d", f.compressMethod, dst.compressMethod)
+	case f.codec.ID() != dst.codec.ID():
+		return fmt.Sprintf("codec %d, not %d", f.codec.ID(), dst.codec.ID())
+	case f.flags != dst.flags || f.wideSets != dst.wideSets:
+		return "different block layouts"
+	}
+	return ""
+}
+
+// copyRaw appends bytes of f in [start, end) to dst
+func (f *File) copyRaw(dst *File, start, end int64) error {
+	r, closer, err := f.openData()
+	if err != nil {
+		return err
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+	_, err = r.Seek(start, os.SEEK_SET)
+	if err != nil {
+		return makeErr(err, "seek")
+	}
+	return dst.appendRaw(io.LimitReader(r, end-start))
+}
@@ -0,0 +1,183 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyBlocks(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	schema := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	}
+	newFile := func(ext string) *File {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d%s", rand.Int63(), ext))
+		f, err := New(path, schema)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		return f
+	}
+
+	for _, ext := range []string{"", ".msgpack"} {
+		src := newFile(ext)
+		defer src.Close()
+		for i := 0; i < 10; i++ {
+			if err := src.Append([]Foo{{i, fmt.Sprintf("%d", i)}, {i + 1, "x"}}, i); err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+
+		// same format, and recompressed
+		for _, dstExt := range []string{ext, ".snappy" + ext, ".zstd" + ext} {
+			dst := newFile(dstExt)
+			defer dst.Close()
+			if err := src.CopyBlocks(dst, 2, 5); err != nil {
+				t.Fatalf("%s to %s: copy blocks: %v", ext, dstExt, err)
+			}
+			if err := src.CopyBlocks(dst, 8, 10); err != nil {
+				t.Fatalf("%s to %s: copy blocks: %v", ext, dstExt, err)
+			}
+			var metas []int
+			var meta int
+			var columns struct {
+				Foo []int
+				Bar []string
+			}
+			err := dst.IterAll(&meta, &columns, func() bool {
+				if len(columns.Foo) != 2 || columns.Foo[0] != meta || columns.Bar[0] != fmt.Sprintf("%d", meta) || columns.Bar[1] != "x" {
+					t.Fatalf("%s to %s: got %v %v", ext, dstExt, columns.Foo, columns.Bar)
+				}
+				metas = append(metas, meta)
+				return true
+			})
+			if err != nil {
+				t.Fatalf("iter all: %v", err)
+			}
+			if fmt.Sprint(metas) != "[2 3 4 8 9]" {
+				t.Fatalf("%s to %s: got %v", ext, dstExt, metas)
+			}
+			if err := dst.VerifyIntegrity(); err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+		}
+
+		dst := newFile(ext)
+		defer dst.Close()
+		if err := src.CopyBlocks(dst, 5, 11); err != ErrBlockOutOfRange {
+			t.Fatalf("expecting out of range, got %v", err)
+		}
+		if err := src.CopyBlocks(dst, 3, 2); err != ErrBlockOutOfRange {
+			t.Fatalf("expecting out of range, got %v", err)
+		}
+	}
+
+	// incompatible schema
+	src := newFile("")
+	defer src.Close()
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	dst, err := New(path, func(i int) (ret interface{}) {
+		if i == 0 {
+			ret = &struct {
+				Foo []string
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer dst.Close()
+	if err := src.CopyBlocks(dst, 0, 0); err == nil {
+		t.Fatal("expecting error")
+	}
+}
+
+func TestAppendFile(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	schema := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	}
+	newFile := func(ext string, from, to int) *File {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d%s", rand.Int63(), ext))
+		f, err := New(path, schema)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		for i := from; i < to; i++ {
+			if err := f.Append([]Foo{{i, fmt.Sprintf("%d", i)}}, i); err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+		return f
+	}
+
+	a := newFile(".snappy", 0, 5)
+	defer a.Close()
+	b := newFile(".snappy", 5, 12)
+	defer b.Close()
+	if err := a.AppendFile(b); err != nil {
+		t.Fatalf("append file: %v", err)
+	}
+	n := 0
+	var meta int
+	var columns struct {
+		Foo []int
+		Bar []string
+	}
+	err := a.IterAll(&meta, &columns, func() bool {
+		if meta != n || columns.Foo[0] != n || columns.Bar[0] != fmt.Sprintf("%d", n) {
+			t.Fatalf("got %d %v %v", meta, columns.Foo, columns.Bar)
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter all: %v", err)
+	}
+	if n != 12 {
+		t.Fatalf("got %d blocks", n)
+	}
+	if err := a.VerifyIntegrity(); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	// different compression
+	c := newFile(".zstd", 0, 1)
+	defer c.Close()
+	if err := a.AppendFile(c); err == nil {
+		t.Fatal("expecting error")
+	}
+	if n, err := a.Count(); err != nil || n != 12 {
+		t.Fatalf("got %d blocks, %v", n, err)
+	}
+}
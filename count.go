@@ -0,0 +1,113 @@
+package rcf
+
+import (
+	"io"
+	"reflect"
+)
+
+// Count returns the number of blocks in the file. only block headers are read, payloads are skipped without decoding.
+// if Options.IndexFile is set, headers of blocks in the loaded index are not read.
+func (f *File) Count() (int, error) {
+	if f.indexFile {
+		return f.countIndexed()
+	}
+	cursor, err := f.Cursor()
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close()
+	size, err := f.dataSize()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for {
+		header, err := cursor.NextHeader()
+		if err == io.EOF { // no more
+			return n, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if header.Offset+header.Size() > size {
+			return 0, makeKindErr(KindTornBlock, &TornBlockError{
+				Offset:   header.Offset,
+				Expected: header.Size(),
+				Got:      size - header.Offset,
+			}, "torn block")
+		}
+		n++
+	}
+}
+
+// countIndexed is Count by the offset index
+func (f *File) countIndexed() (int, error) {
+	offsets, err := f.blockOffsets()
+	if err != nil {
+		return 0, err
+	}
+	size, err := f.dataSize()
+	if err != nil {
+		return 0, err
+	}
+	f.Lock()
+	end := f.blockIndexEnd
+	f.Unlock()
+	if end > size {
+		last := offsets[len(offsets)-1]
+		return 0, makeKindErr(KindTornBlock, &TornBlockError{
+			Offset:   last,
+			Expected: end - last,
+			Got:      size - last,
+		}, "torn block")
+	}
+	return len(offsets), nil
+}
+
+// NumRows returns the number of rows in the file. row counts are read from block headers.
+// files written without row counts are counted by decoding the first column.
+func (f *File) NumRows() (int64, error) {
+	var n int64
+	if f.flags&_FLAG_ROWS == 0 {
+		if len(f.colSets) == 0 || len(f.colSets[0]) == 0 {
+			return 0, makeErr(nil, "no column to count")
+		}
+		err := f.Iter(f.colSets[0][:1], func(columns ...interface{}) bool {
+			n += int64(reflect.ValueOf(columns[0]).Len())
+			return true
+		})
+		if err != nil {
+			return 0, err
+		}
+		return n, nil
+	}
+	cursor, err := f.Cursor()
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close()
+	for {
+		header, err := cursor.NextHeader()
+		if err == io.EOF { // no more
+			return n, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		n += int64(header.Rows)
+	}
+}
+
+// setsRows returns the number of rows of the filled column sets
+func setsRows(sets []interface{}) int {
+	for _, set := range sets {
+		v := reflect.ValueOf(set)
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		if v.NumField() > 0 {
+			return v.Field(0).Len()
+		}
+	}
+	return 0
+}
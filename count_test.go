@@ -0,0 +1,178 @@
+package rcf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCount(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	n, err := f.Count()
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if n != 0 {
+		t.Fatalf("got %d for empty file", n)
+	}
+
+	for i := 0; i < 42; i++ {
+		err = f.Append([]Foo{{i}, {i + 1}}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	n, err = f.Count()
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if n != 42 {
+		t.Fatalf("got %d", n)
+	}
+
+	f.Sync()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tail := range [][]byte{
+		{1, 0, 0, 0, 0, 9, 0, 0},        // partial set length
+		{1, 8, 0, 0, 0, 9, 0, 0, 0, 42}, // partial payload
+	} {
+		file, err := os.OpenFile(path, os.O_RDWR, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := file.Truncate(info.Size()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.WriteAt(tail, info.Size()); err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+		_, err = f.Count()
+		if !isTornBlock(err) {
+			t.Fatalf("expecting torn block, got %v", err)
+		}
+	}
+}
+
+func TestNumRows(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	}
+	appendRows := func(f *File) {
+		for i := 0; i < 10; i++ {
+			err := f.Append(make([]Foo, i), i)
+			if err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+	}
+
+	t.Run("new", func(t *testing.T) {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+		f, err := New(path, colSetsFn)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		defer f.Close()
+		if f.flags&_FLAG_ROWS == 0 {
+			t.Fatal("no row counts")
+		}
+		n, err := f.NumRows()
+		if err != nil {
+			t.Fatalf("num rows: %v", err)
+		}
+		if n != 0 {
+			t.Fatalf("got %d", n)
+		}
+		appendRows(f)
+		n, err = f.NumRows()
+		if err != nil {
+			t.Fatalf("num rows: %v", err)
+		}
+		if n != 45 {
+			t.Fatalf("got %d", n)
+		}
+	})
+
+	t.Run("legacy", func(t *testing.T) {
+		// version 2 file without row counts
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+		err := ioutil.WriteFile(path, fileHeader{
+			Version: 2,
+			Flags:   _FLAG_CHECKSUM | _FLAG_STATS,
+			Codec:   GobCodec{}.ID(),
+		}.marshal(), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := New(path, colSetsFn)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		defer f.Close()
+		if f.flags&_FLAG_ROWS > 0 {
+			t.Fatal("should not have row counts")
+		}
+		appendRows(f)
+		n, err := f.NumRows()
+		if err != nil {
+			t.Fatalf("num rows: %v", err)
+		}
+		if n != 45 {
+			t.Fatalf("got %d", n)
+		}
+	})
+
+	t.Run("bad version", func(t *testing.T) {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+		err := ioutil.WriteFile(path, fileHeader{
+			Version: 2,
+			Flags:   _FLAG_ROWS,
+			Codec:   GobCodec{}.ID(),
+		}.marshal(), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := New(path, colSetsFn); err == nil {
+			t.Fatal("should fail")
+		}
+	})
+}
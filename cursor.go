@@ -0,0 +1,374 @@
+package rcf
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+// BlockHeader describes the framing of a block
+type BlockHeader struct {
+	Offset      int64 // position of the block in the file
+	MetaLength  uint32
+	SetLengths  []uint32
+	Rows        uint32 // number of rows in the block
+	HasRows     bool   // false for files written without row counts
+	Time        int64  // time of writing the block in unix nanoseconds
+	HasTime     bool   // false for files written without Options.StampTime
+	Checksummed bool   // false for files written without checksums
+	Checksum    uint32 // CRC32 (Castagnoli) of the statistics, meta and column set payloads
+	hasStats    bool
+	stats       []byte // encoded column statistics
+	wideSets    bool   // the number of sets is uint16
+}
+
+// HeaderSize returns the encoded length of the header
+func (h *BlockHeader) HeaderSize() int64 {
+	size := int64(1 + 4 + 4*len(h.SetLengths))
+	if h.wideSets {
+		size++
+	}
+	if h.HasRows {
+		size += 4
+	}
+	if h.HasTime {
+		size += 8
+	}
+	if h.hasStats {
+		size += 4 + int64(len(h.stats))
+	}
+	if h.Checksummed {
+		size += 4
+	}
+	return size
+}
+
+// PayloadSize returns the total length of the meta and column set payloads
+func (h *BlockHeader) PayloadSize() int64 {
+	size := int64(h.MetaLength)
+	for _, l := range h.SetLengths {
+		size += int64(l)
+	}
+	return size
+}
+
+// Size returns the length of the whole block
+func (h *BlockHeader) Size() int64 {
+	return h.HeaderSize() + h.PayloadSize()
+}
+
+// FrameCursor navigates the blocks of a file without decoding them
+type FrameCursor struct {
+	r      io.ReadSeeker
+	closer io.Closer
+	pos    int64
+	header *BlockHeader
+	flags  uint8
+	// wideSets is true if numbers of column sets are uint16
+	wideSets bool
+	data     []byte // the mapped file, if reading by mmap
+	// partial allows reading parts of checksummed blocks without verifying checksums
+	partial bool
+}
+
+// NewFrameCursor returns a cursor reading from the current position of r, which must be the start of a block.
+// blocks are expected to have no checksums or statistics, like files without header.
+func NewFrameCursor(r io.ReadSeeker) (*FrameCursor, error) {
+	pos, err := r.Seek(0, os.SEEK_CUR)
+	if err != nil {
+		return nil, makeErr(err, "tell")
+	}
+	return &FrameCursor{
+		r:   r,
+		pos: pos,
+	}, nil
+}
+
+// Cursor opens a FrameCursor positioned at the first block of the file. the cursor should be closed after use.
+func (f *File) Cursor() (*FrameCursor, error) {
+	f.Sync()
+	r, closer, err := f.openData()
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.Seek(f.dataStart, os.SEEK_SET)
+	if err != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, makeErr(err, "seek")
+	}
+	return &FrameCursor{
+		r:        r,
+		closer:   closer,
+		pos:      f.dataStart,
+		flags:    f.flags,
+		wideSets: f.wideSets,
+		partial:  f.partialReads,
+	}, nil
+}
+
+// Close closes the file opened by File.Cursor
+func (c *FrameCursor) Close() error {
+	if c.closer == nil {
+		return nil
+	}
+	return c.closer.Close()
+}
+
+// Tell returns the current position in the file
+func (c *FrameCursor) Tell() int64 {
+	return c.pos
+}
+
+// Seek implements io.Seeker. the resulting position must be the start of a block.
+func (c *FrameCursor) Seek(offset int64, whence int) (int64, error) {
+	c.header = nil
+	switch whence {
+	case os.SEEK_CUR:
+		offset += c.pos
+	case os.SEEK_END:
+		pos, err := c.r.Seek(offset, os.SEEK_END)
+		if err != nil {
+			return 0, makeErr(err, "seek")
+		}
+		c.pos = pos
+		return pos, nil
+	}
+	err := c.seek(offset)
+	if err != nil {
+		return 0, err
+	}
+	return c.pos, nil
+}
+
+func (c *FrameCursor) seek(offset int64) error {
+	if offset == c.pos {
+		return nil
+	}
+	_, err := c.r.Seek(offset, os.SEEK_SET)
+	if err != nil {
+		return makeErr(err, "seek")
+	}
+	c.pos = offset
+	return nil
+}
+
+func (c *FrameCursor) read(bs []byte) error {
+	n, err := io.ReadFull(c.r, bs)
+	c.pos += int64(n)
+	return err
+}
+
+// NextHeader reads the header of the next block, skipping the rest of the current block. returns io.EOF if there is no more block.
+func (c *FrameCursor) NextHeader() (*BlockHeader, error) {
+	if c.header != nil {
+		err := c.seek(c.header.Offset + c.header.Size())
+		if err != nil {
+			return nil, err
+		}
+		c.header = nil
+	}
+	offset := c.pos
+	// read number of sets
+	var numSetsBytes [2]byte
+	countLen := 1
+	if c.wideSets {
+		countLen = 2
+	}
+	err := c.read(numSetsBytes[:countLen])
+	if err == io.EOF { // no more
+		return nil, io.EOF
+	}
+	if err == io.ErrUnexpectedEOF {
+		return nil, c.torn(offset, int64(countLen))
+	}
+	if err != nil {
+		return nil, makeErr(err, "read number of column sets")
+	}
+	numSets := int(numSetsBytes[0])
+	if c.wideSets {
+		numSets = int(binary.LittleEndian.Uint16(numSetsBytes[:]))
+	}
+	// read meta and sets length, row count, statistics length and checksum
+	l := 4 * (numSets + 1)
+	hasRows := c.flags&_FLAG_ROWS > 0
+	if hasRows {
+		l += 4
+	}
+	hasTime := c.flags&_FLAG_TIME > 0
+	if hasTime {
+		l += 8
+	}
+	hasStats := c.flags&_FLAG_STATS > 0
+	if hasStats {
+		l += 4
+	}
+	checksummed := c.flags&_FLAG_CHECKSUM > 0
+	if checksummed {
+		l += 4
+	}
+	lens := make([]byte, l)
+	err = c.read(lens)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, c.torn(offset, int64(countLen+l))
+	}
+	if err != nil {
+		return nil, makeErr(err, "read payload length")
+	}
+	header := &BlockHeader{
+		Offset:      offset,
+		MetaLength:  binary.LittleEndian.Uint32(lens),
+		HasRows:     hasRows,
+		HasTime:     hasTime,
+		Checksummed: checksummed,
+		hasStats:    hasStats,
+		wideSets:    c.wideSets,
+	}
+	lens = lens[4:]
+	for i := 0; i < numSets; i++ {
+		header.SetLengths = append(header.SetLengths, binary.LittleEndian.Uint32(lens))
+		lens = lens[4:]
+	}
+	if hasRows {
+		header.Rows = binary.LittleEndian.Uint32(lens)
+		lens = lens[4:]
+	}
+	if hasTime {
+		header.Time = int64(binary.LittleEndian.Uint64(lens))
+		lens = lens[8:]
+	}
+	var statsLength uint32
+	if hasStats {
+		statsLength = binary.LittleEndian.Uint32(lens)
+		lens = lens[4:]
+	}
+	if checksummed {
+		header.Checksum = binary.LittleEndian.Uint32(lens)
+	}
+	if statsLength > 0 {
+		header.stats, err = c.next(int64(statsLength))
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, c.torn(offset, header.HeaderSize())
+		}
+		if err != nil {
+			return nil, makeErr(err, "read statistics")
+		}
+	}
+	c.header = header
+	return header, nil
+}
+
+// SkipPayload moves the cursor to the end of the current block
+func (c *FrameCursor) SkipPayload() error {
+	if c.header == nil {
+		return makeErr(nil, "no current block")
+	}
+	return c.seek(c.header.Offset + c.header.Size())
+}
+
+// ReadPayload reads the meta and column set payloads of the current block
+func (c *FrameCursor) ReadPayload() ([]byte, error) {
+	if c.header == nil {
+		return nil, makeErr(nil, "no current block")
+	}
+	err := c.seek(c.header.Offset + c.header.HeaderSize())
+	if err != nil {
+		return nil, err
+	}
+	bs, err := c.next(c.header.PayloadSize())
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, c.torn(c.header.Offset, c.header.Size())
+	}
+	if err != nil {
+		return nil, makeErr(err, "read payload")
+	}
+	err = c.header.verify(bs)
+	if err != nil {
+		return nil, err
+	}
+	return bs, nil
+}
+
+// readParts reads the meta if meta is true, and the column sets selected by sets, of the current block.
+// the returned slice has one entry per column set in the block, nil for sets not read.
+func (c *FrameCursor) readParts(meta bool, sets []bool) (metaBytes []byte, setBytes [][]byte, err error) {
+	if c.header == nil {
+		return nil, nil, makeErr(nil, "no current block")
+	}
+	all := meta
+	for n := range c.header.SetLengths {
+		all = all && n < len(sets) && sets[n]
+	}
+	if c.header.Checksummed && (all || !c.partial) {
+		// the whole payload is needed to verify
+		payload, err := c.ReadPayload()
+		if err != nil {
+			return nil, nil, err
+		}
+		if meta {
+			metaBytes = payload[:c.header.MetaLength]
+		}
+		payload = payload[c.header.MetaLength:]
+		setBytes = make([][]byte, len(c.header.SetLengths))
+		for n, l := range c.header.SetLengths {
+			if n < len(sets) && sets[n] {
+				setBytes[n] = payload[:l]
+			}
+			payload = payload[l:]
+		}
+		return metaBytes, setBytes, nil
+	}
+	offset := c.header.Offset + c.header.HeaderSize()
+	read := func(l uint32) ([]byte, error) {
+		err := c.seek(offset)
+		if err != nil {
+			return nil, err
+		}
+		bs, err := c.next(int64(l))
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, c.torn(c.header.Offset, c.header.Size())
+		}
+		return bs, err
+	}
+	if meta {
+		metaBytes, err = read(c.header.MetaLength)
+		if err != nil {
+			return nil, nil, wrapReadErr(err, "read meta")
+		}
+	}
+	offset += int64(c.header.MetaLength)
+	setBytes = make([][]byte, len(c.header.SetLengths))
+	for n, l := range c.header.SetLengths {
+		if n < len(sets) && sets[n] {
+			setBytes[n], err = read(l)
+			if err != nil {
+				return nil, nil, wrapReadErr(err, "read column set")
+			}
+		}
+		offset += int64(l)
+	}
+	return
+}
+
+// torn returns the error of a block at offset cut short by the end of the file. expected is the length of the block or of the part read.
+func (c *FrameCursor) torn(offset, expected int64) error {
+	end, err := c.r.Seek(0, os.SEEK_END)
+	if err != nil {
+		return makeErr(err, "seek")
+	}
+	c.pos = end
+	return makeKindErr(KindTornBlock, &TornBlockError{
+		Offset:   offset,
+		Expected: expected,
+		Got:      end - offset,
+	}, "torn block")
+}
+
+// wrapReadErr wraps read errors, except torn block errors, which are returned as is
+func wrapReadErr(err error, info string) error {
+	if isTornBlock(err) {
+		return err
+	}
+	return makeErr(err, info)
+}
@@ -0,0 +1,110 @@
+package rcf
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFrameCursor(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	for i := 0; i < 5; i++ {
+		err = f.Append([]Foo{{i, "foo"}}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	cursor, err := f.Cursor()
+	if err != nil {
+		t.Fatalf("cursor: %v", err)
+	}
+	defer cursor.Close()
+
+	var offsets []int64
+	end := f.dataStart
+	for {
+		header, err := cursor.NextHeader()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next header: %v", err)
+		}
+		if header.Offset != end {
+			t.Fatalf("bad offset")
+		}
+		if cursor.Tell() != header.Offset+header.HeaderSize() {
+			t.Fatalf("bad position after header")
+		}
+		offset := header.Offset
+		end = header.Offset + header.Size()
+		if len(header.SetLengths) != 2 {
+			t.Fatalf("bad number of sets")
+		}
+		offsets = append(offsets, offset)
+		if len(offsets)%2 == 0 {
+			err = cursor.SkipPayload()
+			if err != nil {
+				t.Fatalf("skip payload: %v", err)
+			}
+			if cursor.Tell() != header.Offset+header.Size() {
+				t.Fatalf("bad position after skip")
+			}
+		}
+	}
+	if len(offsets) != 5 {
+		t.Fatalf("got %d blocks", len(offsets))
+	}
+
+	// random access
+	_, err = cursor.Seek(offsets[3], io.SeekStart)
+	if err != nil {
+		t.Fatalf("seek: %v", err)
+	}
+	header, err := cursor.NextHeader()
+	if err != nil {
+		t.Fatalf("next header: %v", err)
+	}
+	payload, err := cursor.ReadPayload()
+	if err != nil {
+		t.Fatalf("read payload: %v", err)
+	}
+	if int64(len(payload)) != header.PayloadSize() {
+		t.Fatalf("bad payload length")
+	}
+	var meta int
+	err = f.decode(payload[:header.MetaLength], &meta)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if meta != 3 {
+		t.Fatalf("got meta %d", meta)
+	}
+}
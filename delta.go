@@ -0,0 +1,57 @@
+package rcf
+
+import (
+	"reflect"
+)
+
+// checkDeltaColumns resolves the delta encoded columns to the field indexes of each column set
+func (f *File) checkDeltaColumns(cols []string) (err error) {
+	f.deltaFields, err = f.transformFields(cols, "delta", func(t reflect.Type) bool {
+		kind := statKind(t)
+		return kind == _STAT_INT || kind == _STAT_UINT
+	})
+	return
+}
+
+// deltaEncode returns a new slice of the first value of column followed by differences of successive values.
+// differences wrap around like the arithmetic of the element type, so decoding restores any values.
+func deltaEncode(column reflect.Value) reflect.Value {
+	l := column.Len()
+	ret := reflect.MakeSlice(column.Type(), l, l)
+	if statKind(column.Type().Elem()) == _STAT_INT {
+		var last int64
+		for i := 0; i < l; i++ {
+			v := column.Index(i).Int()
+			ret.Index(i).SetInt(v - last)
+			last = v
+		}
+	} else {
+		var last uint64
+		for i := 0; i < l; i++ {
+			v := column.Index(i).Uint()
+			ret.Index(i).SetUint(v - last)
+			last = v
+		}
+	}
+	return ret
+}
+
+// deltaDecode reverses deltaEncode in place
+func deltaDecode(column reflect.Value) {
+	l := column.Len()
+	if statKind(column.Type().Elem()) == _STAT_INT {
+		var last int64
+		for i := 0; i < l; i++ {
+			elem := column.Index(i)
+			elem.SetInt(last + elem.Int())
+			last = elem.Int()
+		}
+	} else {
+		var last uint64
+		for i := 0; i < l; i++ {
+			elem := column.Index(i)
+			elem.SetUint(last + elem.Uint())
+			last = elem.Uint()
+		}
+	}
+}
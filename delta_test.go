@@ -0,0 +1,114 @@
+package rcf
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeltaColumns(t *testing.T) {
+	type Item struct {
+		Nid      int64
+		UpdateAt uint32
+		Small    int8
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Nid      []int64
+				UpdateAt []uint32
+			}{}
+		case 1:
+			ret = &struct {
+				Small []int8
+			}{}
+		}
+		return
+	}
+	var rows []Item
+	nid := int64(math.MaxInt64 - 5000000)
+	updateAt := uint32(1500000000)
+	for i := 0; i < 10000; i++ {
+		nid += rand.Int63n(1000)
+		updateAt += uint32(rand.Intn(60))
+		rows = append(rows, Item{
+			Nid:      nid,
+			UpdateAt: updateAt,
+			Small:    int8(rand.Intn(256) - 128), // differences overflow int8
+		})
+	}
+
+	write := func(opts Options) (*File, int64) {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d.snappy", rand.Int63()))
+		f, err := NewWithOptions(path, colSetsFn, opts)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		for i := 0; i < 5; i++ {
+			if err := f.Append(rows, i); err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+		if err := f.Sync(); err != nil {
+			t.Fatalf("sync: %v", err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f, info.Size()
+	}
+
+	plain, plainSize := write(Options{})
+	defer plain.Close()
+	f, size := write(Options{
+		DeltaColumns: []string{"Nid", "UpdateAt", "Small"},
+	})
+	defer f.Close()
+	if size >= plainSize {
+		t.Fatalf("delta encoded file is %d bytes, plain file is %d bytes", size, plainSize)
+	}
+
+	n := 0
+	err := f.Iter([]string{"Nid", "UpdateAt", "Small"}, func(columns ...interface{}) bool {
+		nids := columns[0].([]int64)
+		updateAts := columns[1].([]uint32)
+		smalls := columns[2].([]int8)
+		if len(nids) != len(rows) {
+			t.Fatalf("got %d rows", len(nids))
+		}
+		for i, row := range rows {
+			if nids[i] != row.Nid || updateAts[i] != row.UpdateAt || smalls[i] != row.Small {
+				t.Fatalf("got %d %d %d, expecting %+v", nids[i], updateAts[i], smalls[i], row)
+			}
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter: %v", err)
+	}
+	if n != 5 {
+		t.Fatalf("got %d blocks", n)
+	}
+
+	// bad options
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	if _, err := NewWithOptions(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []string
+			}{}
+		}
+		return
+	}, Options{
+		DeltaColumns: []string{"Foo"},
+	}); err == nil {
+		t.Fatal("should fail")
+	}
+}
@@ -0,0 +1,53 @@
+package rcf
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// dictColumn is a dictionary encoded column. values of rows are Values[Indexes[i]].
+type dictColumn struct {
+	Name    string
+	Values  []string
+	Indexes []uint32
+}
+
+// checkDictColumns resolves the dictionary columns to the field indexes of each column set
+func (f *File) checkDictColumns(cols []string) (err error) {
+	f.dictFields, err = f.transformFields(cols, "dictionary", func(t reflect.Type) bool {
+		return t.Kind() == reflect.String
+	})
+	return
+}
+
+func makeDictColumn(name string, column reflect.Value) dictColumn {
+	ret := dictColumn{
+		Name:    name,
+		Indexes: make([]uint32, column.Len()),
+	}
+	indexes := make(map[string]uint32)
+	for i, l := 0, column.Len(); i < l; i++ {
+		s := column.Index(i).String()
+		index, ok := indexes[s]
+		if !ok {
+			index = uint32(len(ret.Values))
+			indexes[s] = index
+			ret.Values = append(ret.Values, s)
+		}
+		ret.Indexes[i] = index
+	}
+	return ret
+}
+
+// decodeDictColumn sets field to the values of dict
+func decodeDictColumn(dict dictColumn, field reflect.Value) error {
+	column := reflect.MakeSlice(field.Type(), len(dict.Indexes), len(dict.Indexes))
+	for i, index := range dict.Indexes {
+		if int(index) >= len(dict.Values) {
+			return makeKindErr(KindCorrupt, nil, fmt.Sprintf("bad dictionary index %d of column %s", index, dict.Name))
+		}
+		column.Index(i).SetString(dict.Values[index])
+	}
+	field.Set(column)
+	return nil
+}
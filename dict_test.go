@@ -0,0 +1,143 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDictionaryColumns(t *testing.T) {
+	type Item struct {
+		ID       int
+		Location string
+		Seller   string
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				ID []int
+			}{}
+		case 1:
+			ret = &struct {
+				Location []string
+				Seller   []string
+			}{}
+		}
+		return
+	}
+	locations := []string{"Hangzhou, Zhejiang", "Guangzhou, Guangdong", "Shenzhen, Guangdong", "Shanghai", "Beijing"}
+	var rows []Item
+	for i := 0; i < 10000; i++ {
+		rows = append(rows, Item{
+			ID:       i,
+			Location: locations[rand.Intn(len(locations))],
+			Seller:   fmt.Sprintf("seller-%d", i),
+		})
+	}
+
+	write := func(opts Options) (*File, int64) {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d.snappy", rand.Int63()))
+		f, err := NewWithOptions(path, colSetsFn, opts)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		for i := 0; i < 5; i++ {
+			if err := f.Append(rows, i); err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+		// empty block
+		if err := f.Append([]Item{}, 5); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		if err := f.Sync(); err != nil {
+			t.Fatalf("sync: %v", err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f, info.Size()
+	}
+
+	plain, plainSize := write(Options{})
+	defer plain.Close()
+	f, size := write(Options{
+		DictionaryColumns: []string{"Location"},
+	})
+	defer f.Close()
+	if size >= plainSize {
+		t.Fatalf("dictionary encoded file is %d bytes, plain file is %d bytes", size, plainSize)
+	}
+
+	check := func(f *File) {
+		n := 0
+		err := f.Iter([]string{"ID", "Location", "Seller"}, func(columns ...interface{}) bool {
+			ids := columns[0].([]int)
+			locs := columns[1].([]string)
+			sellers := columns[2].([]string)
+			if n == 5 {
+				if ids != nil || locs != nil || sellers != nil {
+					t.Fatalf("got %v %v %v", ids, locs, sellers)
+				}
+			} else {
+				if len(locs) != len(rows) {
+					t.Fatalf("got %d locations", len(locs))
+				}
+				for i, row := range rows {
+					if ids[i] != row.ID || locs[i] != row.Location || sellers[i] != row.Seller {
+						t.Fatalf("got %d %s %s, expecting %+v", ids[i], locs[i], sellers[i], row)
+					}
+				}
+			}
+			n++
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter: %v", err)
+		}
+		if n != 6 {
+			t.Fatalf("got %d blocks", n)
+		}
+
+		var meta int
+		var columns struct {
+			Location []string
+		}
+		if err := f.ReadBlock(2, &meta, &columns); err != nil {
+			t.Fatalf("read block: %v", err)
+		}
+		if meta != 2 || columns.Location[42] != rows[42].Location {
+			t.Fatalf("got %d %s", meta, columns.Location[42])
+		}
+	}
+	check(f)
+
+	// readable without the option
+	f2, err := New(f.path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f2.Close()
+	check(f2)
+	stats, err := f2.StatsUncompressed()
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if stats.UncompressedBytes <= stats.PayloadBytes {
+		t.Fatalf("bad stats %+v", stats)
+	}
+
+	// bad options
+	for _, cols := range [][]string{{"ID"}, {"Foo"}} {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+		if _, err := NewWithOptions(path, colSetsFn, Options{
+			DictionaryColumns: cols,
+		}); err == nil {
+			t.Fatalf("should fail for %v", cols)
+		}
+	}
+}
@@ -1,11 +1,28 @@
 package rcf
 
-import "fmt"
+import (
+	"fmt"
+	"reflect"
+)
+
+// Kind classifies errors. errors.Is(err, sentinel) reports whether err is of the kind of the sentinel, like errors.Is(err, ErrCorrupt).
+type Kind uint8
+
+const (
+	KindUnknown        Kind = iota
+	KindEmpty               // the file has no block, ErrEmpty
+	KindOutOfRange          // no such block, ErrBlockOutOfRange
+	KindTornBlock           // a block is cut short by the end of the file, ErrTornBlock
+	KindCorrupt             // malformed block, or checksum mismatch, ErrCorrupt
+	KindSchemaMismatch      // column sets do not match the file, ErrSchemaMismatch
+	KindDecode              // a payload can not be decoded by the codec, ErrDecode
+)
 
 type Err struct {
 	Pkg  string
 	Info string
 	Err  error
+	Kind Kind
 }
 
 func (e *Err) Error() string {
@@ -15,10 +32,91 @@ func (e *Err) Error() string {
 	return fmt.Sprintf("%s: %s\n%v", e.Pkg, e.Info, e.Err)
 }
 
+// Unwrap returns the wrapped error, for errors.Is and errors.As
+func (e *Err) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the sentinel of the kind of e
+func (e *Err) Is(target error) bool {
+	return e.Kind != KindUnknown && kindErrs[e.Kind] == target
+}
+
+// makeErr returns an error wrapping err, of the kind of err if err is an *Err
 func makeErr(err error, info string) *Err {
-	return &Err{
+	ret := &Err{
 		Pkg:  `rcf`,
 		Info: info,
 		Err:  err,
 	}
+	if e, ok := err.(*Err); ok {
+		ret.Kind = e.Kind
+	}
+	return ret
+}
+
+// makeKindErr is like makeErr, with kind if err has no kind
+func makeKindErr(kind Kind, err error, info string) *Err {
+	ret := makeErr(err, info)
+	if ret.Kind == KindUnknown {
+		ret.Kind = kind
+	}
+	return ret
+}
+
+var (
+	ErrDeadlineReached = makeErr(nil, "deadline reached")
+	ErrBlockOutOfRange = makeKindErr(KindOutOfRange, nil, "block out of range")
+	ErrChecksum        = makeKindErr(KindCorrupt, nil, "checksum mismatch")
+	ErrEmpty           = makeKindErr(KindEmpty, nil, "file has no block")
+
+	// sentinels of kinds, to be matched by errors.Is
+	ErrTornBlock      = makeKindErr(KindTornBlock, nil, "torn block")
+	ErrCorrupt        = makeKindErr(KindCorrupt, nil, "corrupted block")
+	ErrSchemaMismatch = makeKindErr(KindSchemaMismatch, nil, "schema mismatch")
+	ErrDecode         = makeKindErr(KindDecode, nil, "decode error")
+)
+
+var kindErrs = map[Kind]error{
+	KindEmpty:          ErrEmpty,
+	KindOutOfRange:     ErrBlockOutOfRange,
+	KindTornBlock:      ErrTornBlock,
+	KindCorrupt:        ErrCorrupt,
+	KindSchemaMismatch: ErrSchemaMismatch,
+	KindDecode:         ErrDecode,
+}
+
+// TornBlockError is the Err field of the *Err returned when a block is cut short by the end of the file, as by an interrupted Append.
+// a file ending at a block boundary is not an error.
+type TornBlockError struct {
+	Offset   int64 // offset of the block
+	Expected int64 // length of the block, or of its header if the header is cut short
+	Got      int64 // length of the block before the end of the file
+}
+
+func (e *TornBlockError) Error() string {
+	return fmt.Sprintf("block at offset %d has %d bytes, expecting %d", e.Offset, e.Got, e.Expected)
+}
+
+func isTornBlock(err error) bool {
+	if e, ok := err.(*Err); ok {
+		_, ok = e.Err.(*TornBlockError)
+		return ok
+	}
+	return false
+}
+
+// SchemaMismatchError is the Err field of the *Err returned by New when a column of the provided column sets can not be decoded from the file
+type SchemaMismatchError struct {
+	Set    int          // index of the column set
+	Column string       // name of the column, empty if no single column is to blame
+	Type   reflect.Type // type of the column, or of the column set if Column is empty
+	Err    error        // the decode error, naming the type in the file
+}
+
+func (e *SchemaMismatchError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("column set %d of type %v does not match the file: %v", e.Set, e.Type, e.Err)
+	}
+	return fmt.Sprintf("column %s of set %d is %v, not matching the file: %v", e.Column, e.Set, e.Type, e.Err)
 }
@@ -0,0 +1,143 @@
+package rcf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestErrKinds(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	expect := func(err error, sentinel error) {
+		t.Helper()
+		if !errors.Is(err, sentinel) {
+			t.Fatalf("expecting %v, got %v", sentinel, err)
+		}
+		for _, other := range kindErrs {
+			if other != sentinel && errors.Is(err, other) {
+				t.Fatalf("%v should not be %v", err, other)
+			}
+		}
+	}
+
+	// empty
+	var meta int
+	expect(f.LastMeta(&meta), ErrEmpty)
+
+	for i := 0; i < 3; i++ {
+		if err := f.Append([]Foo{{i}}, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	// out of range
+	var columns struct {
+		Foo []int
+	}
+	expect(f.ReadBlock(3, &meta, &columns), ErrBlockOutOfRange)
+	expect(makeErr(ErrBlockOutOfRange, "wrapped"), ErrBlockOutOfRange)
+
+	// decode
+	var stringMeta string
+	expect(f.ReadBlock(0, &stringMeta, &columns), ErrDecode)
+
+	// checksum mismatch
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 1)
+	if _, err := file.ReadAt(b, info.Size()-1); err != nil {
+		t.Fatal(err)
+	}
+	b[0] ^= 0xff
+	if _, err := file.WriteAt(b, info.Size()-1); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	err = f.VerifyIntegrity()
+	expect(err, ErrCorrupt)
+	if !isChecksumError(err) {
+		t.Fatalf("expecting checksum error, got %v", err)
+	}
+
+	// torn block
+	if err := os.Truncate(path, info.Size()-1); err != nil {
+		t.Fatal(err)
+	}
+	expect(f.Iter([]string{"Foo"}, func(columns ...interface{}) bool {
+		return true
+	}), ErrTornBlock)
+
+	// messages are not changed by kinds
+	if ErrEmpty.Error() != "rcf: file has no block" {
+		t.Fatalf("got %s", ErrEmpty.Error())
+	}
+}
+
+func TestErrUnwrap(t *testing.T) {
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	if err := f.Append([]struct{ Foo int }{{1}}, 1); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	// no block at the offset
+	var meta int
+	var columns struct {
+		Foo []int
+	}
+	err = f.ReadBlockAt(1<<20, &meta, &columns)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expecting unexpected EOF, got %v", err)
+	}
+
+	err = makeErr(makeErr(ErrChecksum, "block"), "verify")
+	if !errors.Is(err, ErrChecksum) {
+		t.Fatalf("expecting checksum error, got %v", err)
+	}
+
+	err = makeErr(&TornBlockError{Offset: 42}, "torn block")
+	var torn *TornBlockError
+	if !errors.As(err, &torn) || torn.Offset != 42 {
+		t.Fatalf("got %v", err)
+	}
+}
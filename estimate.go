@@ -0,0 +1,36 @@
+package rcf
+
+// EstimateBlockSize encodes sampleRows, like rows of Append, into the column sets returned by colSetsFn, and returns the encoded size of each set payload, keyed by the set index.
+// sets are encoded like in new files of compression and the default codec, CompressAuto is no compression. nothing is written, so layouts of column sets can be compared before creating files.
+func EstimateBlockSize(colSetsFn func(int) interface{}, sampleRows interface{}, compression Compression) (map[int]int, error) {
+	colSets, err := schemaColSets(colSetsFn)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{
+		colSets:   colSets,
+		colSetsFn: colSetsFn,
+		codec:     GobCodec{},
+		flags:     _NEW_FILE_FLAGS,
+	}
+	if compression != CompressAuto {
+		method, err := compressMethod(compression)
+		if err != nil {
+			return nil, err
+		}
+		f.compressMethod = method
+	}
+	sets, err := f.rowsSets(sampleRows)
+	if err != nil {
+		return nil, err
+	}
+	sizes := make(map[int]int, len(sets))
+	for n, set := range sets {
+		bs, err := f.encodeSet(n, set)
+		if err != nil {
+			return nil, makeErr(err, "encode column set")
+		}
+		sizes[n] = len(bs)
+	}
+	return sizes, nil
+}
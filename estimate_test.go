@@ -0,0 +1,114 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEstimateBlockSize(t *testing.T) {
+	type Foo struct {
+		Foo  int
+		Bar  string
+		Baz  bool
+		Qux  []int
+		Quux map[string]string
+	}
+	var rows []Foo
+	for i := 0; i < 1000; i++ {
+		s := fmt.Sprintf("%d", i)
+		rows = append(rows, Foo{i, s, i%2 == 0, []int{i}, map[string]string{s: s}})
+	}
+
+	// three sets, as in TestBasics
+	three := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+				Baz []bool
+			}{}
+		case 2:
+			ret = &struct {
+				Qux  [][]int
+				Quux []map[string]string
+			}{}
+		}
+		return
+	}
+	// one wide set
+	one := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo  []int
+				Bar  []string
+				Baz  []bool
+				Qux  [][]int
+				Quux []map[string]string
+			}{}
+		}
+		return
+	}
+
+	// estimates are the lengths of set payloads of appended blocks
+	check := func(colSetsFn func(int) interface{}, compression Compression) map[int]int {
+		sizes, err := EstimateBlockSize(colSetsFn, rows, compression)
+		if err != nil {
+			t.Fatalf("estimate: %v", err)
+		}
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+		f, err := NewWithOptions(path, colSetsFn, Options{
+			Compression: compression,
+		})
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		defer f.Close()
+		if err := f.Append(rows, nil); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		cursor, err := f.Cursor()
+		if err != nil {
+			t.Fatalf("cursor: %v", err)
+		}
+		defer cursor.Close()
+		header, err := cursor.NextHeader()
+		if err != nil {
+			t.Fatalf("next header: %v", err)
+		}
+		if len(sizes) != len(header.SetLengths) {
+			t.Fatalf("got %v, expected %v", sizes, header.SetLengths)
+		}
+		for n, l := range header.SetLengths {
+			if sizes[n] != int(l) {
+				t.Fatalf("got %v, expected %v", sizes, header.SetLengths)
+			}
+		}
+		return sizes
+	}
+	for _, compression := range []Compression{CompressNone, CompressSnappy, CompressZstd} {
+		threeSizes := check(three, compression)
+		oneSizes := check(one, compression)
+		if compression == CompressNone {
+			// each set carries its own type description
+			total := 0
+			for _, size := range threeSizes {
+				total += size
+			}
+			if total <= oneSizes[0] {
+				t.Fatalf("got %v, wide set is %d", threeSizes, oneSizes[0])
+			}
+		}
+	}
+
+	if _, err := EstimateBlockSize(three, rows, Compression(42)); err == nil {
+		t.Fatal("expecting error")
+	}
+}
@@ -0,0 +1,152 @@
+package rcf
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// exportBlocks iterates blocks with columns in the order of cols. meta is nil if withMeta is false.
+func (f *File) exportBlocks(cols []string, withMeta bool, cb func(meta []byte, columns []reflect.Value) error) error {
+	if len(cols) == 0 {
+		return makeErr(nil, "no columns to export")
+	}
+	order, unknown := f.iterOrder(cols)
+	if len(unknown) > 0 {
+		return makeErr(nil, fmt.Sprintf("no such column: %s", strings.Join(unknown, ", ")))
+	}
+	if len(order) != len(cols) {
+		return makeErr(nil, "duplicated columns")
+	}
+	indexes := make([]int, len(cols))
+	for i, col := range cols {
+		for j, c := range order {
+			if c == col {
+				indexes[i] = j
+			}
+		}
+	}
+	var cbErr error
+	err := f.iterBlocks(context.Background(), order, nil, nil, withMeta, func(_ *BlockHeader, meta []byte, columns []interface{}) bool {
+		values := make([]reflect.Value, len(cols))
+		for i, j := range indexes {
+			values[i] = reflect.ValueOf(columns[j])
+		}
+		cbErr = cb(meta, values)
+		return cbErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	return cbErr
+}
+
+// ExportJSON writes rows of the requested columns as newline-delimited JSON objects keyed by column names.
+// the meta of the block is under the "_meta" key. metas are decoded without knowing their types, which is supported by codecs like MsgpackCodec, but not by GobCodec.
+// an error is returned if a meta can not be decoded, then ExportJSONMeta exports metas of their types.
+func (f *File) ExportJSON(w io.Writer, cols []string) error {
+	return f.ExportJSONMeta(w, cols, nil)
+}
+
+// ExportJSONMeta is like ExportJSON, but metas are decoded into values of the type metaTarget points to
+func (f *File) ExportJSONMeta(w io.Writer, cols []string, metaTarget interface{}) error {
+	encoder := json.NewEncoder(w)
+	return f.exportBlocks(cols, true, func(metaBytes []byte, columns []reflect.Value) error {
+		var meta interface{}
+		if metaTarget != nil {
+			v := reflect.New(reflect.TypeOf(metaTarget).Elem())
+			if err := f.decodeMeta(metaBytes, v.Interface()); err != nil {
+				return makeKindErr(KindDecode, err, "decode meta")
+			}
+			meta = v.Elem().Interface()
+		} else if err := f.decodeMeta(metaBytes, &meta); err != nil {
+			return makeKindErr(KindDecode, err, "decode meta without its type, ExportJSONMeta decodes metas of their types")
+		}
+		meta = jsonValue(meta)
+		for i, l := 0, blockRows(columns); i < l; i++ {
+			row := make(map[string]interface{}, len(cols)+1)
+			for n, col := range cols {
+				row[col] = jsonValue(columnValue(columns[n], i).Interface())
+			}
+			row["_meta"] = meta
+			if err := encoder.Encode(row); err != nil {
+				return makeErr(err, "encode json")
+			}
+		}
+		return nil
+	})
+}
+
+// jsonValue converts maps with non-string keys, as decoded by msgpack, to maps encodable by encoding/json
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = jsonValue(value)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, value := range v {
+			s[i] = jsonValue(value)
+		}
+		return s
+	}
+	return v
+}
+
+// CSVOptions are options of ExportCSVWithOptions
+type CSVOptions struct {
+	// JSON renders values of complex columns, like slices, maps and structs, as JSON. they are rendered by fmt's %v by default.
+	JSON bool
+}
+
+// ExportCSV writes a header row of cols, and rows of the requested columns, in the order of cols
+func (f *File) ExportCSV(w io.Writer, cols []string) error {
+	return f.ExportCSVWithOptions(w, cols, CSVOptions{})
+}
+
+// ExportCSVWithOptions is like ExportCSV, with options
+func (f *File) ExportCSVWithOptions(w io.Writer, cols []string, opts CSVOptions) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(cols); err != nil {
+		return makeErr(err, "write csv")
+	}
+	record := make([]string, len(cols))
+	err := f.exportBlocks(cols, false, func(_ []byte, columns []reflect.Value) error {
+		for i, l := 0, blockRows(columns); i < l; i++ {
+			for n, column := range columns {
+				value := columnValue(column, i)
+				switch value.Kind() {
+				case reflect.Slice, reflect.Map, reflect.Struct, reflect.Array, reflect.Ptr, reflect.Interface:
+					if opts.JSON {
+						bs, err := json.Marshal(jsonValue(value.Interface()))
+						if err != nil {
+							return makeErr(err, "encode json")
+						}
+						record[n] = string(bs)
+						continue
+					}
+				}
+				record[n] = fmt.Sprint(value.Interface())
+			}
+			if err := writer.Write(record); err != nil {
+				return makeErr(err, "write csv")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return makeErr(err, "write csv")
+	}
+	return nil
+}
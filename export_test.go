@@ -0,0 +1,197 @@
+package rcf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type exportRow struct {
+	Foo  int
+	Bar  string
+	Tags []string
+}
+
+func newExportFile(t *testing.T, ext string) *File {
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d%s", rand.Int63(), ext))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+				Bar []string
+			}{}
+		case 1:
+			ret = &struct {
+				Tags [][]string
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		err = f.Append([]exportRow{
+			{i * 2, fmt.Sprintf("%d", i*2), []string{"a"}},
+			{i*2 + 1, fmt.Sprintf("%d", i*2+1), nil},
+		}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	return f
+}
+
+func TestExportJSON(t *testing.T) {
+	f := newExportFile(t, "")
+	defer f.Close()
+
+	decode := func(buf *bytes.Buffer) (rows []map[string]interface{}) {
+		decoder := json.NewDecoder(buf)
+		for decoder.More() {
+			var row map[string]interface{}
+			if err := decoder.Decode(&row); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			rows = append(rows, row)
+		}
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	var meta int
+	if err := f.ExportJSONMeta(buf, []string{"Tags", "Foo"}, &meta); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	rows := decode(buf)
+	expected := []map[string]interface{}{
+		{"Foo": 0.0, "Tags": []interface{}{"a"}, "_meta": 0.0},
+		{"Foo": 1.0, "Tags": nil, "_meta": 0.0},
+		{"Foo": 2.0, "Tags": []interface{}{"a"}, "_meta": 1.0},
+		{"Foo": 3.0, "Tags": nil, "_meta": 1.0},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("got %v", rows)
+	}
+
+	// gob metas are not decodable without types
+	buf.Reset()
+	if err := f.ExportJSON(buf, []string{"Bar"}); !errors.Is(err, ErrDecode) {
+		t.Fatalf("got %v", err)
+	}
+
+	if err := f.ExportJSON(buf, []string{"Baz"}); err == nil {
+		t.Fatal("expecting error")
+	}
+
+	// msgpack metas
+	f2 := newExportFile(t, ".msgpack")
+	defer f2.Close()
+	buf.Reset()
+	if err := f2.ExportJSON(buf, []string{"Foo"}); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	rows = decode(buf)
+	if len(rows) != 4 || rows[3]["Foo"] != 3.0 || rows[3]["_meta"] != 1.0 {
+		t.Fatalf("got %v", rows)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	f := newExportFile(t, "")
+	defer f.Close()
+
+	buf := new(bytes.Buffer)
+	if err := f.ExportCSV(buf, []string{"Tags", "Bar", "Foo"}); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	expected := "Tags,Bar,Foo\n[a],0,0\n[],1,1\n[a],2,2\n[],3,3\n"
+	if buf.String() != expected {
+		t.Fatalf("got %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := f.ExportCSVWithOptions(buf, []string{"Foo", "Tags"}, CSVOptions{JSON: true}); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	expected = "Foo,Tags\n0,\"[\"\"a\"\"]\"\n1,null\n2,\"[\"\"a\"\"]\"\n3,null\n"
+	if buf.String() != expected {
+		t.Fatalf("got %q", buf.String())
+	}
+}
+
+func TestExportAddedSet(t *testing.T) {
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if err := f.Append([]struct{ Foo int }{{1}, {2}}, 0); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	// a set added
+	f, err = New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	if err := f.Append([]struct {
+		Foo int
+		Bar string
+	}{{3, "c"}}, 1); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := f.ExportCSV(buf, []string{"Bar", "Foo"}); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	expected := "Bar,Foo\n,1\n,2\nc,3\n"
+	if buf.String() != expected {
+		t.Fatalf("got %q", buf.String())
+	}
+
+	buf.Reset()
+	var meta int
+	if err := f.ExportJSONMeta(buf, []string{"Bar", "Foo"}, &meta); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	expected = `{"Bar":"","Foo":1,"_meta":0}
+{"Bar":"","Foo":2,"_meta":0}
+{"Bar":"c","Foo":3,"_meta":1}
+`
+	if buf.String() != expected {
+		t.Fatalf("got %q", buf.String())
+	}
+}
@@ -0,0 +1,101 @@
+package rcf
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+)
+
+// FileStats describes the size of a file
+type FileStats struct {
+	FileSize          int64 // size of the file on disk
+	Blocks            int
+	PayloadBytes      int64 // total length of meta and column set payloads, as stored
+	UncompressedBytes int64 // total length of decompressed payloads, only reported by StatsUncompressed
+}
+
+// Stats returns sizes of the file, by reading block headers only
+func (f *File) Stats() (FileStats, error) {
+	return f.stats(false)
+}
+
+// StatsUncompressed is like Stats, and also reports UncompressedBytes, by decompressing all payloads
+func (f *File) StatsUncompressed() (FileStats, error) {
+	return f.stats(true)
+}
+
+func (f *File) stats(uncompressed bool) (stats FileStats, err error) {
+	cursor, err := f.Cursor()
+	if err != nil {
+		return
+	}
+	defer cursor.Close()
+	stats.FileSize, err = f.dataSize()
+	if err != nil {
+		return
+	}
+	sets := make([]bool, len(f.colSets))
+	for i := range sets {
+		sets[i] = true
+	}
+	for {
+		header, err := cursor.NextHeader()
+		if err == io.EOF { // no more
+			return stats, nil
+		}
+		if err != nil {
+			return stats, err
+		}
+		stats.Blocks++
+		stats.PayloadBytes += header.PayloadSize()
+		if !uncompressed {
+			continue
+		}
+		metaBytes, setBytes, err := cursor.readParts(true, sets)
+		if err != nil {
+			return stats, err
+		}
+		if len(metaBytes) > 0 { // empty if nil
+			n, err := f.uncompressedSize(f.metaDecompressReader(bytes.NewReader(metaBytes)))
+			if err != nil {
+				return stats, err
+			}
+			stats.UncompressedBytes += n
+		}
+		for _, bs := range setBytes {
+			if f.gobStream && len(bs) > 0 {
+				// stream flag is not compressed
+				stats.UncompressedBytes++
+				bs = bs[1:]
+			} else if !f.gobStream && bs != nil {
+				_, setBin, transformsBin, err := f.splitSetPayload(bs)
+				if err != nil {
+					return stats, err
+				}
+				// encoding and lengths are not compressed
+				stats.UncompressedBytes += int64(len(bs) - len(setBin) - len(transformsBin))
+				if len(transformsBin) > 0 {
+					n, err := f.uncompressedSize(f.decompressReader(bytes.NewReader(transformsBin)))
+					if err != nil {
+						return stats, err
+					}
+					stats.UncompressedBytes += n
+				}
+				bs = setBin
+			}
+			n, err := f.uncompressedSize(f.decompressReader(bytes.NewReader(bs)))
+			if err != nil {
+				return stats, err
+			}
+			stats.UncompressedBytes += n
+		}
+	}
+}
+
+func (f *File) uncompressedSize(r io.Reader) (int64, error) {
+	n, err := io.Copy(ioutil.Discard, r)
+	if err != nil {
+		return 0, makeErr(err, "decompress")
+	}
+	return n, nil
+}
@@ -0,0 +1,79 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+
+	for _, suffix := range []string{".snappy", ".zstd"} {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d%s", rand.Int63(), suffix))
+		f, err := New(path, func(i int) (ret interface{}) {
+			switch i {
+			case 0:
+				ret = &struct {
+					Foo []int
+				}{}
+			case 1:
+				ret = &struct {
+					Bar []string
+				}{}
+			}
+			return
+		})
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+
+		for i := 0; i < 10; i++ {
+			var rows []Foo
+			for j := 0; j < 100; j++ {
+				rows = append(rows, Foo{j, strings.Repeat("foo", 100)})
+			}
+			err = f.Append(rows, i)
+			if err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+		f.Sync()
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stats, err := f.Stats()
+		if err != nil {
+			t.Fatalf("stats: %v", err)
+		}
+		if stats.FileSize != info.Size() || stats.Blocks != 10 {
+			t.Fatalf("bad stats %+v", stats)
+		}
+		if stats.PayloadBytes <= 0 || stats.PayloadBytes >= stats.FileSize {
+			t.Fatalf("bad payload bytes %+v", stats)
+		}
+		if stats.UncompressedBytes != 0 {
+			t.Fatalf("uncompressed bytes reported %+v", stats)
+		}
+
+		full, err := f.StatsUncompressed()
+		if err != nil {
+			t.Fatalf("stats: %v", err)
+		}
+		if full.FileSize != stats.FileSize || full.Blocks != stats.Blocks || full.PayloadBytes != stats.PayloadBytes {
+			t.Fatalf("bad stats %+v", full)
+		}
+		if full.PayloadBytes >= full.UncompressedBytes {
+			t.Fatalf("not compressed %+v", full)
+		}
+		f.Close()
+	}
+}
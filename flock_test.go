@@ -0,0 +1,93 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestFileLock(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	var files []*File
+	for i := 0; i < 2; i++ {
+		f, err := NewWithOptions(path, colSetsFn, Options{
+			FileLock: true,
+		})
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		defer f.Close()
+		files = append(files, f)
+	}
+
+	wg := new(sync.WaitGroup)
+	for n, f := range files {
+		n := n
+		f := f
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				err := f.Append([]Foo{{n, fmt.Sprintf("%d", i)}}, n*1000+i)
+				if err != nil {
+					t.Errorf("append: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	f := files[0]
+	if err := f.VerifyIntegrity(); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	next := make([]int, 2)
+	var meta int
+	var columns struct {
+		Foo []int
+		Bar []string
+	}
+	err := f.IterAll(&meta, &columns, func() bool {
+		n := columns.Foo[0]
+		if meta != n*1000+next[n] || columns.Bar[0] != fmt.Sprintf("%d", next[n]) {
+			t.Fatalf("bad block %d %v", meta, columns)
+		}
+		next[n]++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter all: %v", err)
+	}
+	if next[0] != 200 || next[1] != 200 {
+		t.Fatalf("got %v blocks", next)
+	}
+
+	if _, err := NewWithOptions(path, colSetsFn, Options{
+		FileLock:        true,
+		WriteBufferSize: 1024,
+	}); err == nil {
+		t.Fatalf("should fail")
+	}
+}
@@ -0,0 +1,90 @@
+package rcf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Follow is like Iter, but after the last block, waits for blocks appended later, by this or other handles or processes, and passes them to cb too.
+// it returns when cb returns false. the file is checked for new blocks every Options.FollowInterval, and a block partially written when checked is read by a later check.
+// files written in gob stream mode can not be followed.
+func (f *File) Follow(cols []string, cb func(columns ...interface{}) bool) error {
+	return f.FollowContext(context.Background(), cols, cb)
+}
+
+// FollowContext is like Follow, but stops when ctx is done, returning the error of ctx wrapped by *Err
+func (f *File) FollowContext(ctx context.Context, cols []string, cb func(columns ...interface{}) bool) error {
+	if f.gobStream {
+		return makeErr(nil, "following is not supported in gob stream mode")
+	}
+	if !f.allowUnknownColumns {
+		if _, unknown := f.iterOrder(cols); len(unknown) > 0 {
+			return makeErr(nil, fmt.Sprintf("no such column: %s", strings.Join(unknown, ", ")))
+		}
+	}
+	pos := f.dataStart
+	for {
+		offsets, end, err := f.completeBlocks(pos)
+		if err != nil {
+			return err
+		}
+		if len(offsets) > 0 {
+			stopped := false
+			err := f.iterBlocks(ctx, cols, offsets, nil, false, func(_ *BlockHeader, _ []byte, columns []interface{}) bool {
+				if !cb(columns...) {
+					stopped = true
+					return false
+				}
+				return true
+			})
+			if err != nil || stopped {
+				return err
+			}
+			pos = end
+			continue
+		}
+		timer := time.NewTimer(f.followInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return makeErr(ctx.Err(), "context done")
+		case <-timer.C:
+		}
+	}
+}
+
+// completeBlocks returns the offsets of the completely written blocks from pos, and the end of the last one.
+// blocks after the first partially written one are not returned.
+func (f *File) completeBlocks(pos int64) (offsets []int64, end int64, err error) {
+	size, err := f.dataSize()
+	if err != nil {
+		return nil, 0, err
+	}
+	cursor, err := f.Cursor()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close()
+	if _, err := cursor.Seek(pos, os.SEEK_SET); err != nil {
+		return nil, 0, err
+	}
+	end = pos
+	for {
+		header, err := cursor.NextHeader()
+		if err == io.EOF || isTornBlock(err) { // no more, or being written
+			return offsets, end, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if header.Offset+header.Size() > size { // being written
+			return offsets, end, nil
+		}
+		offsets = append(offsets, header.Offset)
+		end = header.Offset + header.Size()
+	}
+}
@@ -0,0 +1,136 @@
+package rcf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFollow(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	w, err := New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer w.Close()
+	for i := 0; i < 10; i++ {
+		if err := w.Append([]Foo{{i, fmt.Sprintf("%d", i)}}, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	r, err := OpenWithOptions(path, colSetsFn, Options{
+		FollowInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer r.Close()
+
+	const n = 100
+	errCh := make(chan error, 1)
+	go func() {
+		for i := 10; i < n; i++ {
+			if err := w.Append([]Foo{{i, fmt.Sprintf("%d", i)}}, i); err != nil {
+				errCh <- err
+				return
+			}
+			if i%10 == 0 {
+				time.Sleep(time.Millisecond * 5)
+			}
+		}
+		errCh <- nil
+	}()
+
+	next := 0
+	err = r.Follow([]string{"Foo", "Bar"}, func(columns ...interface{}) bool {
+		foos := columns[0].([]int)
+		bars := columns[1].([]string)
+		if len(foos) != 1 || foos[0] != next || bars[0] != fmt.Sprintf("%d", next) {
+			t.Fatalf("got %v %v, expecting %d", foos, bars, next)
+		}
+		next++
+		return next < n
+	})
+	if err != nil {
+		t.Fatalf("follow: %v", err)
+	}
+	if next != n {
+		t.Fatalf("got %d blocks", next)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	// a partially written block is read after completed
+	block, err := w.encodeBlock([]interface{}{
+		&struct{ Foo []int }{[]int{n}},
+		&struct{ Bar []string }{[]string{fmt.Sprintf("%d", n)}},
+	}, func() ([]byte, error) {
+		return w.encodeMeta(n)
+	})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(block[:3]); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		_, err := file.Write(block[3:])
+		errCh <- err
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	got := 0
+	err = r.FollowContext(ctx, []string{"Foo"}, func(columns ...interface{}) bool {
+		got++
+		return got <= n
+	})
+	if err != nil {
+		t.Fatalf("follow: %v", err)
+	}
+	if got != n+1 {
+		t.Fatalf("got %d blocks", got)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// stopped by the context
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	err = r.FollowContext(ctx, []string{"Foo"}, func(columns ...interface{}) bool {
+		return true
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v", err)
+	}
+}
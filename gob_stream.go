@@ -0,0 +1,79 @@
+package rcf
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"io/ioutil"
+)
+
+// payload flags of gob stream mode column sets
+const (
+	_STREAM_CONTINUE = iota
+	_STREAM_START
+)
+
+type gobStreamEncoder struct {
+	w   switchWriter
+	enc *gob.Encoder
+}
+
+type gobStreamDecoder struct {
+	r   switchReader
+	dec *gob.Decoder
+}
+
+type switchWriter struct {
+	io.Writer
+}
+
+type switchReader struct {
+	*bytes.Reader
+}
+
+// encodeStream encodes column set n with its persistent encoder. must be called in file order.
+func (f *File) encodeStream(n int, o interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	s := f.streamEncoders[n]
+	if s == nil {
+		s = new(gobStreamEncoder)
+		s.enc = gob.NewEncoder(&s.w)
+		f.streamEncoders[n] = s
+		buf.WriteByte(_STREAM_START)
+	} else {
+		buf.WriteByte(_STREAM_CONTINUE)
+	}
+	w := f.compressWriter(buf)
+	s.w.Writer = w
+	err := s.enc.Encode(o)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeStream decodes column set n with the decoders of an iteration. must be called in file order.
+func (f *File) decodeStream(decoders []*gobStreamDecoder, n int, bs []byte, target interface{}) error {
+	if len(bs) == 0 {
+		return makeKindErr(KindCorrupt, nil, "empty gob stream payload")
+	}
+	d := decoders[n]
+	if bs[0] == _STREAM_START {
+		d = new(gobStreamDecoder)
+		d.dec = gob.NewDecoder(&d.r)
+		decoders[n] = d
+	} else if d == nil {
+		return makeKindErr(KindCorrupt, nil, "gob stream not started")
+	}
+	data, err := ioutil.ReadAll(f.decompressReader(bytes.NewReader(bs[1:])))
+	if err != nil {
+		return err
+	}
+	d.r.Reader = bytes.NewReader(data)
+	return d.dec.Decode(target)
+}
@@ -0,0 +1,180 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGobStream(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+
+	for _, suffix := range []string{"", ".snappy", ".zstd"} {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d%s", rand.Int63(), suffix))
+		var f *File
+		var err error
+		open := func() {
+			f, err = NewWithOptions(path, func(i int) (ret interface{}) {
+				switch i {
+				case 0:
+					ret = &struct {
+						Foo []int
+					}{}
+				case 1:
+					ret = &struct {
+						Bar []string
+					}{}
+				}
+				return
+			}, Options{
+				GobStream: true,
+			})
+			if err != nil {
+				t.Fatalf("new: %v", err)
+			}
+		}
+		open()
+
+		appendBlocks := func(from, to int) {
+			for i := from; i < to; i++ {
+				err = f.Append([]Foo{{i, fmt.Sprintf("%d", i)}}, i)
+				if err != nil {
+					t.Fatalf("append: %v", err)
+				}
+			}
+		}
+		appendBlocks(0, 10)
+		f.Close()
+		open() // appending after reopening starts new streams
+		appendBlocks(10, 20)
+
+		n := 0
+		err = f.Iter([]string{"Bar"}, func(cols ...interface{}) bool {
+			if cols[0].([]string)[0] != fmt.Sprintf("%d", n) {
+				t.Fatalf("bar value not match")
+			}
+			n++
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter: %v", err)
+		}
+		if n != 20 {
+			t.Fatalf("iter: got %d blocks", n)
+		}
+
+		var meta int
+		var columns struct {
+			Foo []int
+			Bar []string
+		}
+		n = 0
+		err = f.IterAll(&meta, &columns, func() bool {
+			if columns.Foo[0] != meta || columns.Bar[0] != fmt.Sprintf("%d", meta) {
+				t.Fatalf("wrong iter value %v %v", meta, columns)
+			}
+			n++
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter all: %v", err)
+		}
+		if n != 20 {
+			t.Fatalf("iter all: got %d blocks", n)
+		}
+
+		// skipped blocks must still be decoded
+		n = 0
+		err = f.IterDistinctMeta(func(meta interface{}) interface{} {
+			return meta.(int) / 2
+		}, &meta, &columns, func() bool {
+			if meta%2 != 0 || columns.Foo[0] != meta {
+				t.Fatalf("wrong iter value %v %v", meta, columns)
+			}
+			n++
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter distinct meta: %v", err)
+		}
+		if n != 10 {
+			t.Fatalf("iter distinct meta: got %d blocks", n)
+		}
+
+		f.Close()
+	}
+}
+
+func TestGobStreamFlag(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := NewWithOptions(path, colSetsFn, Options{
+		GobStream: true,
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := f.Append([]Foo{{i}}, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	// read without the option
+	f, err = New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	if !f.gobStream || !f.Header().GobStream {
+		t.Fatal("gob stream mode not recorded")
+	}
+	if err := f.Append([]Foo{{10}}, 10); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	n := 0
+	if err := f.Iter([]string{"Foo"}, func(columns ...interface{}) bool {
+		if foos := columns[0].([]int); len(foos) != 1 || foos[0] != n {
+			t.Fatalf("got %v at %d", foos, n)
+		}
+		n++
+		return true
+	}); err != nil {
+		t.Fatalf("iter: %v", err)
+	}
+	if n != 11 {
+		t.Fatalf("got %d blocks", n)
+	}
+
+	// not copied as bytes
+	dst, err := New(filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63())), colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer dst.Close()
+	if err := f.CopyBlocks(dst, 0, 1); err == nil {
+		t.Fatal("should fail")
+	}
+	if err := dst.AppendFile(f); err == nil {
+		t.Fatal("should fail")
+	}
+}
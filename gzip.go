@@ -0,0 +1,45 @@
+package rcf
+
+import (
+	"compress/gzip"
+	"io"
+	"sync"
+)
+
+var gzipReaderPool sync.Pool
+
+// newGzipWriter returns a writer of the gzip level, or of the default level if level is 0
+func newGzipWriter(w io.Writer, level int) *gzip.Writer {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	// the level is checked, no error
+	zw, _ := gzip.NewWriterLevel(w, level)
+	return zw
+}
+
+// gzipReader reads the gzip header on first read, since decompressReader returns no error
+type gzipReader struct {
+	r  io.Reader
+	zr *gzip.Reader
+}
+
+func (g *gzipReader) Read(p []byte) (int, error) {
+	if g.zr == nil {
+		if v := gzipReaderPool.Get(); v != nil {
+			zr := v.(*gzip.Reader)
+			if err := zr.Reset(g.r); err != nil {
+				gzipReaderPool.Put(zr)
+				return 0, makeErr(err, "read gzip header")
+			}
+			g.zr = zr
+		} else {
+			zr, err := gzip.NewReader(g.r)
+			if err != nil {
+				return 0, makeErr(err, "read gzip header")
+			}
+			g.zr = zr
+		}
+	}
+	return g.zr.Read(p)
+}
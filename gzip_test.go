@@ -0,0 +1,124 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGzip(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	}
+
+	for _, c := range []struct {
+		suffix string
+		opts   Options
+	}{
+		{".gzip", Options{}},
+		{"", Options{Compression: CompressGzip}},
+		{"", Options{Compression: CompressGzip, CompressionLevel: 9}},
+	} {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d%s", rand.Int63(), c.suffix))
+		f, err := NewWithOptions(path, colSetsFn, c.opts)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		if f.compressMethod != _COMPRESS_GZIP || f.compression() != CompressGzip {
+			t.Fatalf("not gzip")
+		}
+		for i := 0; i < 100; i++ {
+			err = f.Append([]Foo{
+				{i, fmt.Sprintf("%d", i)},
+				{i * 2, fmt.Sprintf("%d", i*2)},
+			}, i)
+			if err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+		if err := f.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+
+		// the method is read from the header
+		f, err = New(path, colSetsFn)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		if f.compressMethod != _COMPRESS_GZIP {
+			t.Fatalf("not gzip")
+		}
+		if f.compressLevel != c.opts.CompressionLevel {
+			t.Fatalf("got level %d", f.compressLevel)
+		}
+		n := 0
+		var meta int
+		var columns struct {
+			Foo []int
+			Bar []string
+		}
+		err = f.IterAll(&meta, &columns, func() bool {
+			if meta != n || len(columns.Foo) != 2 || columns.Foo[1] != n*2 || columns.Bar[1] != fmt.Sprintf("%d", n*2) {
+				t.Fatalf("wrong iter value %d %v %v", n, meta, columns)
+			}
+			n++
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter all: %v", err)
+		}
+		if n != 100 {
+			t.Fatalf("got %d blocks", n)
+		}
+		f.Close()
+	}
+}
+
+func TestGzipLevel(t *testing.T) {
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+	for _, level := range []int{-1, 10} {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+		_, err := NewWithOptions(path, colSetsFn, Options{
+			Compression:      CompressGzip,
+			CompressionLevel: level,
+		})
+		if err == nil || !strings.Contains(err.Error(), "invalid gzip compression level") {
+			t.Fatalf("expecting level error, got %v", err)
+		}
+	}
+	// ignored by other methods
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := NewWithOptions(path, colSetsFn, Options{
+		Compression:      CompressSnappy,
+		CompressionLevel: 10,
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	f.Close()
+}
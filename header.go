@@ -0,0 +1,148 @@
+package rcf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// files start with a header. files written before headers were introduced have none, and start with the first block.
+// layout: magic, uint16 length of the following fields, version, compression method, flags, codec id, byte order, compression level.
+// integers of headers and blocks are little endian. the byte order field is 'L', files without it are little endian.
+// the compression level is the one of appends, for information only, since decoding does not depend on it. files without it use the default level.
+// new fields are appended, readers skip fields they do not know.
+// version 1 headers have no flags, and early version 2 headers have no codec id. the rows flag requires version 3.
+// block headers of version 4 files have uint16 numbers of column sets, instead of uint8. new files are written in version 3 unless they have more than 255 column sets.
+var headerMagic = [4]byte{0x89, 'R', 'C', 'F'}
+
+const (
+	_FORMAT_VERSION    = 4 // the highest supported version
+	_WIDE_SETS_VERSION = 4
+)
+
+// formatVersion returns the version of new files
+func formatVersion(wideSets bool) uint8 {
+	if wideSets {
+		return _WIDE_SETS_VERSION
+	}
+	return 3
+}
+
+const (
+	_FLAG_CHECKSUM     = 1 << iota // block headers have checksums of payloads
+	_FLAG_STATS                    // block headers have column statistics
+	_FLAG_PLAIN_META               // meta payloads are not compressed
+	_FLAG_ROWS                     // block headers have row counts
+	_FLAG_SET_ENCODING             // column set payloads start with their encoding, except in gob stream mode
+	_FLAG_TIME                     // block headers have timestamps
+	_FLAG_GOB_STREAM               // column set payloads form gob streams, as written in gob stream mode
+
+	_KNOWN_FLAGS = _FLAG_CHECKSUM | _FLAG_STATS | _FLAG_PLAIN_META | _FLAG_ROWS | _FLAG_SET_ENCODING | _FLAG_TIME | _FLAG_GOB_STREAM
+	// flags of new files, besides options
+	_NEW_FILE_FLAGS = _FLAG_CHECKSUM | _FLAG_STATS | _FLAG_ROWS | _FLAG_SET_ENCODING
+)
+
+const _BYTE_ORDER_LITTLE = 'L'
+
+type fileHeader struct {
+	Version        uint8
+	CompressMethod uint8
+	Flags          uint8
+	Codec          uint8
+	ByteOrder      uint8
+	CompressLevel  uint8
+	hasCodec       bool
+}
+
+func (h fileHeader) marshal() []byte {
+	fields := []byte{h.Version, h.CompressMethod, h.Flags, h.Codec, _BYTE_ORDER_LITTLE, h.CompressLevel}
+	buf := new(bytes.Buffer)
+	buf.Write(headerMagic[:])
+	binary.Write(buf, binary.LittleEndian, uint16(len(fields)))
+	buf.Write(fields)
+	return buf.Bytes()
+}
+
+// readFileHeader reads the header at the start of r. returns false if r has no header.
+// size is the length of the header, which is the offset of the first block.
+func readFileHeader(r io.Reader) (header fileHeader, size int64, ok bool, err error) {
+	var magic [4]byte
+	_, err = io.ReadFull(r, magic[:])
+	if err == io.EOF || err == io.ErrUnexpectedEOF || err == nil && magic != headerMagic {
+		return header, 0, false, nil
+	}
+	if err != nil {
+		return header, 0, false, makeErr(err, "read header magic")
+	}
+	var length uint16
+	err = binary.Read(r, binary.LittleEndian, &length)
+	if err != nil {
+		return header, 0, false, makeErr(err, "read header length")
+	}
+	fields := make([]byte, length)
+	_, err = io.ReadFull(r, fields)
+	if err != nil {
+		return header, 0, false, makeErr(err, "read header")
+	}
+	if len(fields) < 2 {
+		return header, 0, false, makeErr(nil, "header too short")
+	}
+	header.Version = fields[0]
+	header.CompressMethod = fields[1]
+	if len(fields) > 2 {
+		header.Flags = fields[2]
+	}
+	if len(fields) > 3 {
+		header.Codec = fields[3]
+		header.hasCodec = true
+	}
+	header.ByteOrder = _BYTE_ORDER_LITTLE
+	if len(fields) > 4 {
+		header.ByteOrder = fields[4]
+	}
+	if len(fields) > 5 {
+		header.CompressLevel = fields[5]
+	}
+	return header, int64(len(headerMagic)) + 2 + int64(length), true, nil
+}
+
+// FileHeader is the stored format of a file, as returned by File.Header
+type FileHeader struct {
+	Magic            [4]byte // zero if the file has no header, as written by early versions
+	Version          uint8   // format version, 0 if the file has no header
+	Compression      Compression
+	CompressionLevel int // 0 for the default level of the method
+	Codec            Codec
+	Checksums        bool // block headers have checksums of payloads
+	Stats            bool // block headers have column statistics
+	UncompressedMeta bool // meta payloads are not compressed
+	RowCounts        bool // block headers have row counts
+	SetEncodings     bool // column set payloads record their encoding, as for dictionary and delta encoding
+	Timestamps       bool // block headers have the time of writing, as by Options.StampTime
+	WideSets         bool // block headers have uint16 numbers of column sets
+	GobStream        bool // column set payloads form gob streams, as written in gob stream mode
+}
+
+// Header returns the format of the file as read from its header. formats of files without header are decided by the path and options, like when reading them.
+func (f *File) Header() FileHeader {
+	f.Lock()
+	defer f.Unlock()
+	header := FileHeader{
+		Version:          f.version,
+		Compression:      f.compression(),
+		CompressionLevel: f.compressLevel,
+		Codec:            f.codec,
+		Checksums:        f.flags&_FLAG_CHECKSUM > 0,
+		Stats:            f.flags&_FLAG_STATS > 0,
+		UncompressedMeta: f.flags&_FLAG_PLAIN_META > 0,
+		RowCounts:        f.flags&_FLAG_ROWS > 0,
+		SetEncodings:     f.flags&_FLAG_SET_ENCODING > 0,
+		Timestamps:       f.flags&_FLAG_TIME > 0,
+		WideSets:         f.wideSets,
+		GobStream:        f.flags&_FLAG_GOB_STREAM > 0,
+	}
+	if f.version > 0 {
+		header.Magic = headerMagic
+	}
+	return header
+}
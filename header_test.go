@@ -0,0 +1,507 @@
+package rcf
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompressionOption(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+
+	// option wins over path
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d.snappy", rand.Int63()))
+	f, err := NewWithOptions(path, colSetsFn, Options{
+		Compression: CompressZstd,
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if f.compressMethod != _COMPRESS_ZSTD {
+		t.Fatalf("option not used")
+	}
+	for i := 0; i < 10; i++ {
+		err = f.Append([]Foo{{i}}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	f.Close()
+
+	check := func(f *File, n int) {
+		i := 0
+		var meta int
+		var columns struct {
+			Foo []int
+		}
+		err := f.IterAll(&meta, &columns, func() bool {
+			if meta != i || columns.Foo[0] != i {
+				t.Fatalf("wrong iter value %d %v %v", i, meta, columns)
+			}
+			i++
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter all: %v", err)
+		}
+		if i != n {
+			t.Fatalf("got %d blocks", i)
+		}
+	}
+
+	// stored method wins over path and option
+	for _, opts := range []Options{
+		{},
+		{Compression: CompressNone},
+	} {
+		f, err = NewWithOptions(path, colSetsFn, opts)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		if f.compressMethod != _COMPRESS_ZSTD {
+			t.Fatalf("stored method not used")
+		}
+		check(f, 10)
+		f.Close()
+	}
+
+	// append after reopen
+	f, err = New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	err = f.Append([]Foo{{10}}, 10)
+	if err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	check(f, 11)
+	f.Close()
+
+	// unknown
+	_, err = NewWithOptions(path, colSetsFn, Options{
+		Compression: 42,
+	})
+	if err == nil {
+		t.Fatalf("should fail")
+	}
+}
+
+func TestLegacyFile(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d.snappy", rand.Int63()))
+	f, err := New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	// legacy blocks have no checksums or statistics
+	f.flags = 0
+	for i := 0; i < 10; i++ {
+		err = f.Append([]Foo{{i}}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	dataStart := f.dataStart
+	f.Close()
+
+	// strip header
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path, bs[dataStart:], 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	if f.dataStart != 0 {
+		t.Fatalf("got header")
+	}
+	if header := f.Header(); header.Version != 0 || header.Magic != [4]byte{} || header.Compression != CompressSnappy || header.Checksums {
+		t.Fatalf("got %+v", header)
+	}
+	n, err := f.Count()
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if n != 10 {
+		t.Fatalf("got %d", n)
+	}
+	i := 0
+	err = f.IterMetas(func(meta int) bool {
+		if meta != i {
+			t.Fatalf("got %d", meta)
+		}
+		i++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter metas: %v", err)
+	}
+}
+
+func TestRenamedFile(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d.snappy", rand.Int63()))
+	f, err := New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		err = f.Append([]Foo{{i}}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	f.Close()
+
+	renamed := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	err = os.Rename(path, renamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err = New(renamed, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	i := 0
+	var meta int
+	var columns struct {
+		Foo []int
+	}
+	err = f.IterAll(&meta, &columns, func() bool {
+		if meta != i || columns.Foo[0] != i {
+			t.Fatalf("wrong iter value %d %v %v", i, meta, columns)
+		}
+		i++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter all: %v", err)
+	}
+	if i != 10 {
+		t.Fatalf("got %d blocks", i)
+	}
+}
+
+func TestBadHeader(t *testing.T) {
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+	for _, header := range [][]byte{
+		{0x89, 'R', 'C', 'F', 2, 0, 42, 0},      // unknown version
+		{0x89, 'R', 'C', 'F', 2, 0, 1, 42},      // unknown compression
+		{0x89, 'R', 'C', 'F', 3, 0, 2, 0, 0x80}, // unknown flags
+		{0x89, 'R', 'C', 'F', 1, 0, 1},          // short header
+		{0x89, 'R', 'C', 'F', 8, 0, 1, 0, 0},    // truncated header
+	} {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+		err := ioutil.WriteFile(path, header, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = New(path, colSetsFn)
+		if err == nil {
+			t.Fatalf("should fail: %v", header)
+		}
+	}
+}
+
+func TestUncompressedMeta(t *testing.T) {
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+	metaLength := func(opts Options) int {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d.snappy", rand.Int63()))
+		f, err := NewWithOptions(path, colSetsFn, opts)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		for i := 0; i < 3; i++ {
+			if err := f.Append([]struct{ Foo int }{{i}}, i); err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+		if err := f.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+
+		// the flag is read from the header
+		f, err = New(path, colSetsFn)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		defer f.Close()
+		if (f.flags&_FLAG_PLAIN_META > 0) != opts.UncompressedMeta {
+			t.Fatalf("bad flags %b", f.flags)
+		}
+		var metas []int
+		err = f.IterMetas(func(meta int) bool {
+			metas = append(metas, meta)
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter metas: %v", err)
+		}
+		if fmt.Sprint(metas) != "[0 1 2]" {
+			t.Fatalf("got %v", metas)
+		}
+
+		cursor, err := f.Cursor()
+		if err != nil {
+			t.Fatalf("cursor: %v", err)
+		}
+		defer cursor.Close()
+		if _, err := cursor.NextHeader(); err != nil {
+			t.Fatalf("next header: %v", err)
+		}
+		metaBytes, _, err := cursor.readParts(true, nil)
+		if err != nil {
+			t.Fatalf("read parts: %v", err)
+		}
+		var meta int
+		err = gob.NewDecoder(bytes.NewReader(metaBytes)).Decode(&meta)
+		if opts.UncompressedMeta && err != nil {
+			t.Fatalf("meta is not plain gob: %v", err)
+		} else if !opts.UncompressedMeta && err == nil {
+			t.Fatal("meta is not compressed")
+		}
+		return len(metaBytes)
+	}
+
+	compressed := metaLength(Options{})
+	plain := metaLength(Options{UncompressedMeta: true})
+	if plain >= compressed {
+		t.Fatalf("got %d bytes of uncompressed meta, %d bytes of compressed", plain, compressed)
+	}
+}
+
+func TestWideSets(t *testing.T) {
+	const numSets = 300
+	setTypes := make([]reflect.Type, numSets)
+	rowFields := make([]reflect.StructField, numSets)
+	for i := range setTypes {
+		name := fmt.Sprintf("C%d", i)
+		setTypes[i] = reflect.StructOf([]reflect.StructField{
+			{Name: name, Type: reflect.TypeOf([]int{})},
+		})
+		rowFields[i] = reflect.StructField{Name: name, Type: reflect.TypeOf(0)}
+	}
+	colSetsFn := func(i int) interface{} {
+		if i >= numSets {
+			return nil
+		}
+		return reflect.New(setTypes[i]).Interface()
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	rowType := reflect.StructOf(rowFields)
+	for i := 0; i < 3; i++ {
+		rows := reflect.MakeSlice(reflect.SliceOf(rowType), 2, 2)
+		for j := 0; j < numSets; j++ {
+			rows.Index(0).Field(j).SetInt(int64(i + j))
+			rows.Index(1).Field(j).SetInt(int64(i * j))
+		}
+		if err := f.Append(rows.Interface(), i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	f, err = New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	if !f.wideSets {
+		t.Fatal("expecting wide sets")
+	}
+	n := 0
+	err = f.Iter([]string{"C0", "C299"}, func(cols ...interface{}) bool {
+		c0 := cols[0].([]int)
+		c299 := cols[1].([]int)
+		if len(c0) != 2 || c0[0] != n || c0[1] != 0 || c299[0] != n+299 || c299[1] != n*299 {
+			t.Fatalf("got %v %v", c0, c299)
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("got %d blocks", n)
+	}
+	rows, err := f.NumRows()
+	if err != nil {
+		t.Fatalf("num rows: %v", err)
+	}
+	if rows != 6 {
+		t.Fatalf("got %d rows", rows)
+	}
+}
+
+func TestByteOrder(t *testing.T) {
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if err := f.Append([]struct{ Foo int }{{1}}, 1); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// magic, length, version, compression, flags, codec, byte order
+	if content[10] != 'L' {
+		t.Fatalf("got byte order %q", content[10])
+	}
+
+	content[10] = 'B'
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = New(path, colSetsFn)
+	if err == nil || !strings.Contains(err.Error(), "byte order") {
+		t.Fatalf("expecting byte order error, got %v", err)
+	}
+}
+
+func TestHeader(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := NewWithOptions(path, colSetsFn, Options{
+		Compression:      CompressZstd,
+		CompressionLevel: 5,
+		Codec:            MsgpackCodec{},
+		UncompressedMeta: true,
+		StampTime:        true,
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if err := f.Append([]Foo{{1}}, 1); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	expected := FileHeader{
+		Magic:            headerMagic,
+		Version:          3,
+		Compression:      CompressZstd,
+		CompressionLevel: 5,
+		Codec:            MsgpackCodec{},
+		Checksums:        true,
+		Stats:            true,
+		UncompressedMeta: true,
+		RowCounts:        true,
+		SetEncodings:     true,
+		Timestamps:       true,
+	}
+	if header := f.Header(); header != expected {
+		t.Fatalf("got %+v", header)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	// read from the file
+	f, err = Open(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	if header := f.Header(); header != expected {
+		t.Fatalf("got %+v", header)
+	}
+}
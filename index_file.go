@@ -0,0 +1,111 @@
+package rcf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// sidecar files of offset indexes.
+// layout: magic, version, size and modification time (unix nanoseconds) of the indexed file, end of the last block, number of blocks, offsets of blocks.
+// integers are little endian, sizes and offsets are int64, the number of blocks is uint32.
+var indexFileMagic = [4]byte{0x89, 'R', 'C', 'I'}
+
+const _INDEX_FILE_VERSION = 1
+
+func (f *File) indexFilePath() string {
+	return f.path + ".idx"
+}
+
+// loadIndexFile sets the offset index to the one in the sidecar file, if it matches the file. missing, stale or malformed sidecars are ignored.
+func (f *File) loadIndexFile() error {
+	info, err := f.backend.(*osBackend).Stat()
+	if err != nil {
+		return makeErr(err, "stat file")
+	}
+	content, err := ioutil.ReadFile(f.indexFilePath())
+	if err != nil {
+		return nil
+	}
+	r := bytes.NewReader(content)
+	var header struct {
+		Magic   [4]byte
+		Version uint8
+		Size    int64
+		ModTime int64
+		End     int64
+		Count   uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil
+	}
+	if header.Magic != indexFileMagic ||
+		header.Version != _INDEX_FILE_VERSION ||
+		header.Size != info.Size() ||
+		header.ModTime != info.ModTime().UnixNano() ||
+		header.End < f.dataStart ||
+		header.End > header.Size ||
+		int64(header.Count) != int64(r.Len())/8 {
+		return nil
+	}
+	offsets := make([]int64, header.Count)
+	if err := binary.Read(r, binary.LittleEndian, offsets); err != nil {
+		return nil
+	}
+	for i, offset := range offsets {
+		if offset < f.dataStart || offset >= header.End || i > 0 && offset <= offsets[i-1] {
+			return nil
+		}
+	}
+	f.blockIndex = offsets
+	f.blockIndexEnd = header.End
+	return nil
+}
+
+// saveIndexFile indexes all blocks and writes the index to the sidecar file, replacing the old one by renaming
+func (f *File) saveIndexFile() (err error) {
+	offsets, err := f.blockOffsets()
+	if err != nil {
+		return err
+	}
+	f.Lock()
+	end := f.blockIndexEnd
+	f.Unlock()
+	info, err := f.backend.(*osBackend).Stat()
+	if err != nil {
+		return makeErr(err, "stat file")
+	}
+
+	path := f.indexFilePath()
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+"-")
+	if err != nil {
+		return makeErr(err, "create index file")
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	w := bufio.NewWriter(tmp)
+	w.Write(indexFileMagic[:])
+	w.WriteByte(_INDEX_FILE_VERSION)
+	binary.Write(w, binary.LittleEndian, info.Size())
+	binary.Write(w, binary.LittleEndian, info.ModTime().UnixNano())
+	binary.Write(w, binary.LittleEndian, end)
+	binary.Write(w, binary.LittleEndian, uint32(len(offsets)))
+	binary.Write(w, binary.LittleEndian, offsets)
+	if err = w.Flush(); err != nil {
+		return makeErr(err, "write index file")
+	}
+	if err = tmp.Close(); err != nil {
+		return makeErr(err, "close index file")
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return makeErr(err, "rename index file")
+	}
+	return nil
+}
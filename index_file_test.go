@@ -0,0 +1,122 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIndexFile(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	opts := Options{
+		IndexFile: true,
+	}
+	appendBlocks := func(f *File, from, to int) {
+		for i := from; i < to; i++ {
+			if err := f.Append([]Foo{{i}}, i); err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+	}
+	check := func(f *File, n int) {
+		count, err := f.Count()
+		if err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		if count != n {
+			t.Fatalf("got %d blocks, expected %d", count, n)
+		}
+		for _, i := range []int{0, n / 2, n - 1} {
+			var meta int
+			var columns struct {
+				Foo []int
+			}
+			if err := f.ReadBlock(i, &meta, &columns); err != nil {
+				t.Fatalf("read block: %v", err)
+			}
+			if meta != i || columns.Foo[0] != i {
+				t.Fatalf("got %d %v at %d", meta, columns.Foo, i)
+			}
+		}
+	}
+
+	t.Run("fresh build", func(t *testing.T) {
+		f, err := NewWithOptions(path, colSetsFn, opts)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		appendBlocks(f, 0, 10)
+		check(f, 10)
+		if err := f.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+		if _, err := os.Stat(path + ".idx"); err != nil {
+			t.Fatalf("stat index file: %v", err)
+		}
+	})
+
+	t.Run("reuse", func(t *testing.T) {
+		f, err := NewWithOptions(path, colSetsFn, opts)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		if len(f.blockIndex) != 10 {
+			t.Fatalf("index not loaded, got %d offsets", len(f.blockIndex))
+		}
+		check(f, 10)
+		appendBlocks(f, 10, 15)
+		check(f, 15)
+		if err := f.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		// appended without updating the index file
+		f, err := New(path, colSetsFn)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		appendBlocks(f, 15, 20)
+		if err := f.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+
+		f, err = NewWithOptions(path, colSetsFn, opts)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		if len(f.blockIndex) != 0 {
+			t.Fatalf("stale index loaded")
+		}
+		check(f, 20)
+		if err := f.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+
+		// rebuilt
+		f, err = NewWithOptions(path, colSetsFn, opts)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		defer f.Close()
+		if len(f.blockIndex) != 20 {
+			t.Fatalf("index not rebuilt, got %d offsets", len(f.blockIndex))
+		}
+		check(f, 20)
+	})
+}
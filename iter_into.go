@@ -0,0 +1,142 @@
+package rcf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// IterInto is like Iter, but sets the slices pointed by targets, one for each column of cols in the same order, to the values of each block, then calls cb.
+// column sets are decoded in file order into the slices left by the previous callback, so backing arrays are reused across blocks and only grown when a block has more rows.
+// cb must not retain the slices, or their elements of reference types, after returning.
+// GobCodec encodes column sets as interface values, which are always decoded into new values. for files of GobCodec, targets are set to the decoded columns without copying.
+// targets are like *[]int, of the types of the columns. unknown columns allowed by Options.AllowUnknownColumns are set to empty slices.
+func (f *File) IterInto(cols []string, targets []interface{}, cb func() bool) error {
+	if len(targets) != len(cols) {
+		return makeErr(nil, fmt.Sprintf("got %d targets for %d columns", len(targets), len(cols)))
+	}
+	_, unknown := f.iterOrder(cols)
+	if len(unknown) > 0 && !f.allowUnknownColumns {
+		return makeErr(nil, fmt.Sprintf("no such column: %s", strings.Join(unknown, ", ")))
+	}
+	values := make([]reflect.Value, len(targets))
+	for i, target := range targets {
+		v := reflect.ValueOf(target)
+		if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+			return makeErr(nil, fmt.Sprintf("target of %s is not a pointer to slice: %T", cols[i], target))
+		}
+		values[i] = v.Elem()
+		if t, ok := f.columnType(cols[i]); ok && t != values[i].Type() {
+			return makeErr(nil, fmt.Sprintf("column %s is %v, not %v", cols[i], t, values[i].Type()))
+		}
+	}
+	// index of the target of each field of each set, or -1
+	fields := make([][]int, len(f.colSets))
+	toDecode := make([]bool, len(f.colSets))
+	for n, set := range f.colSets {
+		fields[n] = make([]int, len(set))
+		for j, col := range set {
+			fields[n][j] = -1
+			for i, c := range cols {
+				if c == col {
+					fields[n][j] = i
+					toDecode[n] = true
+				}
+			}
+		}
+	}
+
+	var decoders []*gobStreamDecoder
+	if f.gobStream {
+		decoders = make([]*gobStreamDecoder, len(f.colSets))
+	}
+	direct := f.codec.ID() != _CODEC_GOB
+	sets := make([]reflect.Value, len(f.colSets)) // reused by direct decoding
+
+	decode := func(bss [][]byte) error {
+		for _, v := range values { // unknown columns and sets added after the block was appended
+			v.Set(v.Slice(0, 0))
+		}
+		for n, bs := range bss {
+			if n >= len(toDecode) || !toDecode[n] {
+				continue
+			}
+			var set reflect.Value
+			if direct {
+				if !sets[n].IsValid() {
+					sets[n] = reflect.ValueOf(f.colSetsFn(n))
+				}
+				set = sets[n].Elem()
+				for j, i := range fields[n] {
+					if i >= 0 {
+						set.Field(j).Set(values[i])
+					} else {
+						set.Field(j).Set(set.Field(j).Slice(0, 0))
+					}
+				}
+				if err := f.decodeSetAs(decoders, n, bs, sets[n].Interface()); err != nil {
+					return err
+				}
+			} else {
+				s := f.colSetsFn(n)
+				if err := f.decodeSet(decoders, n, bs, &s); err != nil {
+					return err
+				}
+				set = reflect.ValueOf(s).Elem()
+			}
+			for j, i := range fields[n] {
+				if i < 0 {
+					continue
+				}
+				column := set.Field(j)
+				if direct && column.IsNil() { // keep the backing array
+					column = values[i]
+				}
+				values[i].Set(column)
+			}
+		}
+		return nil
+	}
+
+	cursor, err := f.Cursor()
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+
+	it := f.newIteration(context.Background())
+
+	return it.run(func() {
+		for !it.stopped() {
+			it.read(cursor.Tell())
+			_, err := cursor.NextHeader()
+			if err == io.EOF { // no more
+				it.end(cursor.Tell())
+				return
+			}
+			if err != nil {
+				it.abort(err)
+				return
+			}
+			_, bss, err := cursor.readParts(false, toDecode)
+			if err != nil {
+				it.abort(err)
+				return
+			}
+			// decoded when delivering, after the previous callback returned the targets
+			if !it.block(nil, nil, func() {
+				if err := decode(bss); err != nil {
+					it.abort(makeKindErr(KindDecode, err, "decode column set"))
+					return
+				}
+				if !cb() {
+					it.abort(nil)
+				}
+			}, false) {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIterInto(t *testing.T) {
+	for _, ext := range []string{"gob", "msgpack"} {
+		t.Run(ext, func(t *testing.T) {
+			testIterInto(t, ext)
+		})
+	}
+}
+
+func testIterInto(t *testing.T, ext string) {
+	type Foo struct {
+		Foo int
+		Bar string
+		Baz bool
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d.%s", rand.Int63(), ext))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+				Baz []bool
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	for i := 1; i <= 5; i++ {
+		var rows []Foo
+		for j := 0; j < i; j++ {
+			rows = append(rows, Foo{i, fmt.Sprintf("%d", j), j%2 == 0})
+		}
+		if err := f.Append(rows, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	var bars []string
+	var foos []int
+	n := 0
+	err = f.IterInto([]string{"Bar", "Foo"}, []interface{}{&bars, &foos}, func() bool {
+		n++
+		if len(foos) != n || len(bars) != n {
+			t.Fatalf("got %v %v", foos, bars)
+		}
+		for j := range foos {
+			if foos[j] != n || bars[j] != fmt.Sprintf("%d", j) {
+				t.Fatalf("got %v %v", foos, bars)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter into: %v", err)
+	}
+	if n != 5 {
+		t.Fatalf("got %d blocks", n)
+	}
+
+	// backing arrays are reused by codecs decoding into existing values
+	if err := f.Append([]Foo{}, 6); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	last := &foos[:cap(foos)][0]
+	n = 0
+	err = f.IterInto([]string{"Bar", "Foo"}, []interface{}{&bars, &foos}, func() bool {
+		n++
+		if ext == "msgpack" && &foos[:cap(foos)][0] != last {
+			t.Fatal("backing array not reused")
+		}
+		if n == 6 && (len(foos) != 0 || len(bars) != 0) {
+			t.Fatalf("got %v %v", foos, bars)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter into: %v", err)
+	}
+	if n != 6 {
+		t.Fatalf("got %d blocks", n)
+	}
+
+	// bad targets
+	if err := f.IterInto([]string{"Foo"}, []interface{}{&bars}, func() bool { return true }); err == nil {
+		t.Fatal("expecting type error")
+	}
+	if err := f.IterInto([]string{"Foo"}, []interface{}{foos}, func() bool { return true }); err == nil {
+		t.Fatal("expecting pointer error")
+	}
+	if err := f.IterInto([]string{"Foo", "Bar"}, []interface{}{&foos}, func() bool { return true }); err == nil {
+		t.Fatal("expecting count error")
+	}
+	if err := f.IterInto([]string{"Qux"}, []interface{}{&foos}, func() bool { return true }); err == nil {
+		t.Fatal("expecting unknown column error")
+	}
+}
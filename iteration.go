@@ -0,0 +1,183 @@
+package rcf
+
+import (
+	"context"
+	"sync"
+
+	"github.com/reusee/pipeline"
+)
+
+// stageConfig configures a decoding stage of iterations
+type stageConfig struct {
+	parallelism int
+	queueSize   int
+}
+
+func (c stageConfig) withDefaults(parallelism, queueSize int) stageConfig {
+	if c.parallelism <= 0 {
+		c.parallelism = parallelism
+	}
+	if c.queueSize <= 0 {
+		c.queueSize = queueSize
+	}
+	return c
+}
+
+// iteration drives a read pipeline. the reading goroutine schedules blocks, whose metas and column sets are decoded in two parallel stages, and delivered sequentially in file order.
+type iteration struct {
+	ctx           context.Context
+	line          *pipeline.Pipeline
+	metaDecodes   *pipeline.Pipe
+	columnDecodes *pipeline.Pipe
+	delivers      *pipeline.Pipe
+	metaConfig    stageConfig
+	columnConfig  stageConfig
+	stop          chan struct{}
+	stopOnce      sync.Once
+	err           error
+	onProgress    func(bytesRead int64) // nil if not reporting
+	reads         int                   // number of read calls
+}
+
+// progress is reported every _PROGRESS_INTERVAL blocks
+const _PROGRESS_INTERVAL = 64
+
+func (f *File) newIteration(ctx context.Context) *iteration {
+	line := pipeline.NewPipeline()
+	it := &iteration{
+		ctx:           ctx,
+		line:          line,
+		metaDecodes:   line.NewPipe(f.metaDecode.queueSize),
+		columnDecodes: line.NewPipe(f.columnDecode.queueSize),
+		delivers:      line.NewPipe(f.callbackQueueSize),
+		metaConfig:    f.metaDecode,
+		columnConfig:  f.columnDecode,
+		stop:          make(chan struct{}),
+	}
+	if f.onProgress != nil {
+		total, _ := f.dataSize()
+		it.onProgress = func(bytesRead int64) {
+			f.onProgress(bytesRead, total)
+		}
+	}
+	return it
+}
+
+// read is called by the reading goroutine before reading each block, with the position of the cursor, to report progress sparsely
+func (it *iteration) read(pos int64) {
+	if it.onProgress == nil {
+		return
+	}
+	it.reads++
+	if it.reads%_PROGRESS_INTERVAL == 0 {
+		it.onProgress(pos)
+	}
+}
+
+// abort stops the iteration. the first error is returned by run.
+func (it *iteration) abort(err error) {
+	it.stopOnce.Do(func() {
+		it.err = err
+		close(it.stop)
+		it.line.Close()
+	})
+}
+
+// stopped reports whether the iteration is stopped. a done context stops the iteration.
+func (it *iteration) stopped() bool {
+	select {
+	case <-it.stop:
+		return true
+	default:
+	}
+	if err := it.ctx.Err(); err != nil {
+		it.abort(makeErr(err, "context done"))
+		return true
+	}
+	return false
+}
+
+// block schedules one block. decodeMeta and decodeColumns may be nil, and return false after aborting the iteration.
+// deliver is called after decoding succeeded, in the order of block calls.
+// if sequentialColumns is true, decodeColumns runs in the calling goroutine. returns false if the iteration is stopped.
+func (it *iteration) block(decodeMeta, decodeColumns func() bool, deliver func(), sequentialColumns bool) bool {
+	var results []chan bool
+	schedule := func(pipe *pipeline.Pipe, decode func() bool, sequential bool) bool {
+		if decode == nil {
+			return true
+		}
+		result := make(chan bool, 1)
+		results = append(results, result)
+		job := func() {
+			if it.stopped() {
+				result <- false
+				return
+			}
+			result <- decode()
+		}
+		if sequential {
+			job()
+			return true
+		}
+		return pipe.Do(job)
+	}
+	if !schedule(it.metaDecodes, decodeMeta, false) ||
+		!schedule(it.columnDecodes, decodeColumns, sequentialColumns) {
+		return false
+	}
+	return it.delivers.Do(func() {
+		for _, result := range results {
+			select {
+			case ok := <-result:
+				if !ok {
+					return
+				}
+			case <-it.stop:
+				return
+			}
+		}
+		if !it.stopped() {
+			deliver()
+		}
+	})
+}
+
+// end stops the iteration after all scheduled blocks are delivered. pos is the position of the cursor after the last block, reported as the final progress.
+func (it *iteration) end(pos int64) {
+	if it.onProgress != nil {
+		it.onProgress(pos)
+	}
+	it.delivers.Do(func() {
+		it.abort(nil)
+	})
+}
+
+// run calls read in a new goroutine and processes the pipeline until the iteration stops.
+// all spawned goroutines are exited when run returns.
+func (it *iteration) run(read func()) error {
+	wg := new(sync.WaitGroup)
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		read()
+	}()
+	go func() {
+		defer wg.Done()
+		it.metaDecodes.ParallelProcess(it.metaConfig.parallelism)
+	}()
+	go func() {
+		defer wg.Done()
+		it.columnDecodes.ParallelProcess(it.columnConfig.parallelism)
+	}()
+	go func() {
+		defer wg.Done()
+		select {
+		case <-it.ctx.Done():
+			it.abort(makeErr(it.ctx.Err(), "context done"))
+		case <-it.stop:
+		}
+	}()
+	it.delivers.Process()
+	wg.Wait()
+	return it.err
+}
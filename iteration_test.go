@@ -0,0 +1,342 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeStageOptions(t *testing.T) {
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := NewWithOptions(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	}, Options{
+		MetaDecodeParallelism:   1,
+		MetaDecodeQueueSize:     1,
+		ColumnDecodeParallelism: 3,
+		ColumnDecodeQueueSize:   2,
+		CallbackQueueSize:       1,
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	if f.metaDecode.parallelism != 1 || f.metaDecode.queueSize != 1 {
+		t.Fatalf("wrong meta decode config %+v", f.metaDecode)
+	}
+	if f.columnDecode.parallelism != 3 || f.columnDecode.queueSize != 2 {
+		t.Fatalf("wrong column decode config %+v", f.columnDecode)
+	}
+	if f.callbackQueueSize != 1 {
+		t.Fatalf("wrong callback queue size %d", f.callbackQueueSize)
+	}
+
+	for i := 0; i < 512; i++ {
+		err = f.Append([]struct {
+			Foo int
+			Bar string
+		}{
+			{i, fmt.Sprintf("%d", i)},
+		}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	n := 0
+	var meta int
+	var columns struct {
+		Foo []int
+		Bar []string
+	}
+	err = f.IterAll(&meta, &columns, func() bool {
+		if meta != n || columns.Foo[0] != n || columns.Bar[0] != fmt.Sprintf("%d", n) {
+			t.Fatalf("wrong iter value %d %v %v", n, meta, columns)
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter all: %v", err)
+	}
+	if n != 512 {
+		t.Fatalf("got %d blocks", n)
+	}
+
+	n = 0
+	err = f.IterMetas(func(m int) bool {
+		if m != n {
+			t.Fatalf("wrong meta %d %d", n, m)
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter metas: %v", err)
+	}
+	if n != 512 {
+		t.Fatalf("got %d metas", n)
+	}
+
+	n = 0
+	err = f.Iter([]string{"Bar"}, func(cols ...interface{}) bool {
+		if cols[0].([]string)[0] != fmt.Sprintf("%d", n) {
+			t.Fatalf("wrong column %d %v", n, cols[0])
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter: %v", err)
+	}
+	if n != 512 {
+		t.Fatalf("got %d blocks", n)
+	}
+}
+
+func TestDecodeParallelism(t *testing.T) {
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	schema := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	}
+	f, err := New(path, schema)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	for i := 0; i < 256; i++ {
+		err = f.Append([]struct {
+			Foo int
+			Bar string
+		}{
+			{i, fmt.Sprintf("%d", i)},
+			{i + 1, fmt.Sprintf("%d", i+1)},
+		}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	type result struct {
+		metas []int
+		foos  []int
+		bars  []string
+	}
+	iterate := func(parallelism int) (ret result) {
+		f, err := NewWithOptions(path, schema, Options{
+			DecodeParallelism: parallelism,
+		})
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		defer f.Close()
+		if f.metaDecode.parallelism != parallelism || f.columnDecode.parallelism != parallelism {
+			t.Fatalf("wrong parallelism %d %d", f.metaDecode.parallelism, f.columnDecode.parallelism)
+		}
+		err = f.IterMetas(func(meta int) bool {
+			ret.metas = append(ret.metas, meta)
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter metas: %v", err)
+		}
+		err = f.Iter([]string{"Foo"}, func(columns ...interface{}) bool {
+			ret.foos = append(ret.foos, columns[0].([]int)...)
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter: %v", err)
+		}
+		var meta int
+		var columns struct {
+			Bar []string
+		}
+		err = f.IterAll(&meta, &columns, func() bool {
+			ret.bars = append(ret.bars, columns.Bar...)
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter all: %v", err)
+		}
+		return
+	}
+
+	sequential := iterate(1)
+	for i, meta := range sequential.metas {
+		if meta != i || sequential.foos[i*2] != i || sequential.bars[i*2+1] != fmt.Sprintf("%d", i+1) {
+			t.Fatalf("bad result at %d", i)
+		}
+	}
+	if len(sequential.metas) != 256 {
+		t.Fatalf("got %d metas", len(sequential.metas))
+	}
+	if parallel := iterate(8); !reflect.DeepEqual(sequential, parallel) {
+		t.Fatal("results not match")
+	}
+}
+
+func TestOnProgress(t *testing.T) {
+	type progress struct {
+		read, total int64
+	}
+	var reports []progress
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := NewWithOptions(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}, Options{
+		OnProgress: func(read, total int64) {
+			reports = append(reports, progress{read, total})
+		},
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	for i := 0; i < 200; i++ {
+		if err := f.Append([]struct{ Foo int }{{i}}, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+
+	check := func(what string) {
+		if len(reports) != 200/_PROGRESS_INTERVAL+1 {
+			t.Fatalf("%s: got %d reports", what, len(reports))
+		}
+		for i, report := range reports {
+			if report.total != info.Size() {
+				t.Fatalf("%s: got total %d", what, report.total)
+			}
+			if i > 0 && report.read <= reports[i-1].read {
+				t.Fatalf("%s: progress not increasing: %v", what, reports)
+			}
+		}
+		if last := reports[len(reports)-1]; last.read != last.total {
+			t.Fatalf("%s: not complete: %v", what, last)
+		}
+		reports = nil
+	}
+
+	if err := f.Iter([]string{"Foo"}, func(cols ...interface{}) bool { return true }); err != nil {
+		t.Fatalf("iter: %v", err)
+	}
+	check("iter")
+	var meta int
+	var columns struct {
+		Foo []int
+	}
+	if err := f.IterAll(&meta, &columns, func() bool { return true }); err != nil {
+		t.Fatalf("iter all: %v", err)
+	}
+	check("iter all")
+	if err := f.IterMetas(func(meta int) bool { return true }); err != nil {
+		t.Fatalf("iter metas: %v", err)
+	}
+	check("iter metas")
+}
+
+func TestIterIndexed(t *testing.T) {
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := NewWithOptions(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}, Options{
+		DecodeParallelism: 8,
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	for i := 0; i < 256; i++ {
+		var rows []struct {
+			Foo int
+		}
+		for j := 0; j < i%3; j++ { // including empty blocks
+			rows = append(rows, struct{ Foo int }{i})
+		}
+		if err := f.Append(rows, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	next := 0
+	err = f.IterIndexed([]string{"Foo"}, func(i int, columns ...interface{}) bool {
+		if i != next {
+			t.Fatalf("got block %d, expecting %d", i, next)
+		}
+		next++
+		foos := columns[0].([]int)
+		if len(foos) != i%3 {
+			t.Fatalf("got %v at %d", foos, i)
+		}
+		var meta int
+		var block struct {
+			Foo []int
+		}
+		if err := f.ReadBlock(i, &meta, &block); err != nil {
+			t.Fatalf("read block: %v", err)
+		}
+		if meta != i || !reflect.DeepEqual(block.Foo, foos) {
+			t.Fatalf("got %d %v, expecting %d %v", meta, block.Foo, i, foos)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter: %v", err)
+	}
+	if next != 256 {
+		t.Fatalf("got %d blocks", next)
+	}
+
+	// stopped
+	next = 0
+	err = f.IterIndexed([]string{"Foo"}, func(i int, columns ...interface{}) bool {
+		next++
+		return i < 9
+	})
+	if err != nil {
+		t.Fatalf("iter: %v", err)
+	}
+	if next != 10 {
+		t.Fatalf("got %d blocks", next)
+	}
+}
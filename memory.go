@@ -0,0 +1,85 @@
+package rcf
+
+import (
+	"io"
+	"sync"
+)
+
+// NewMemory returns a File stored in memory, for tests and transient pipelines. the data is lost when the File is dropped.
+func NewMemory(colSetsFn func(int) interface{}) (*File, error) {
+	return NewMemoryWithOptions(colSetsFn, Options{})
+}
+
+// NewMemoryWithOptions is NewMemory with options. there is no path, so CompressAuto is no compression.
+// Mmap, IndexFile and FileLock are not supported, and Compact returns an error.
+func NewMemoryWithOptions(colSetsFn func(int) interface{}, opts Options) (*File, error) {
+	return newFile("", new(memoryFile), false, colSetsFn, opts)
+}
+
+// memoryFile is the backend of NewMemory, a growable buffer.
+// reads are safe for concurrent use with writes, for iterations reading while appending.
+type memoryFile struct {
+	sync.RWMutex
+	data []byte
+}
+
+func (m *memoryFile) ReadAt(p []byte, offset int64) (int, error) {
+	m.RLock()
+	defer m.RUnlock()
+	if offset >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[offset:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *memoryFile) WriteAt(p []byte, offset int64) (int, error) {
+	m.Lock()
+	defer m.Unlock()
+	if offset < 0 {
+		return 0, makeErr(nil, "negative offset")
+	}
+	if gap := offset - int64(len(m.data)); gap > 0 { // beyond the end
+		m.data = append(m.data, make([]byte, gap)...)
+	}
+	n := copy(m.data[offset:], p)
+	m.data = append(m.data, p[n:]...)
+	return len(p), nil
+}
+
+func (m *memoryFile) Truncate(size int64) error {
+	m.Lock()
+	defer m.Unlock()
+	if size < 0 {
+		return makeErr(nil, "negative size")
+	}
+	if size <= int64(len(m.data)) {
+		m.data = m.data[:size]
+		return nil
+	}
+	m.data = append(m.data, make([]byte, size-int64(len(m.data)))...)
+	return nil
+}
+
+func (m *memoryFile) Size() (int64, error) {
+	m.RLock()
+	defer m.RUnlock()
+	return int64(len(m.data)), nil
+}
+
+// Reader returns a reader of the data at the time of calling
+func (m *memoryFile) Reader() (io.ReadSeeker, io.Closer, error) {
+	size, _ := m.Size()
+	return io.NewSectionReader(m, 0, size), nil, nil
+}
+
+func (m *memoryFile) Sync() error {
+	return nil
+}
+
+func (m *memoryFile) Close() error {
+	return nil
+}
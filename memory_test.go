@@ -0,0 +1,113 @@
+package rcf
+
+import (
+	"io"
+	"testing"
+)
+
+func TestBasicsMemory(t *testing.T) {
+	m := new(memoryFile)
+	testBasics(t, func(colSetsFn func(int) interface{}) (*File, error) {
+		return newFile("", m, false, colSetsFn, Options{})
+	})
+}
+
+func TestNewMemory(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+	f, err := NewMemoryWithOptions(colSetsFn, Options{
+		Compression: CompressSnappy,
+	})
+	if err != nil {
+		t.Fatalf("new memory: %v", err)
+	}
+	defer f.Close()
+	for i := 0; i < 10; i++ {
+		if err := f.Append([]Foo{{i}}, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	n, err := f.Count()
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if n != 10 {
+		t.Fatalf("got %d blocks", n)
+	}
+	var meta int
+	var columns struct {
+		Foo []int
+	}
+	if err := f.ReadBlock(7, &meta, &columns); err != nil {
+		t.Fatalf("read block: %v", err)
+	}
+	if meta != 7 || columns.Foo[0] != 7 {
+		t.Fatalf("got %d %v", meta, columns.Foo)
+	}
+	if err := f.Truncate(5); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	if err := f.Append([]Foo{{42}}, 42); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	var metas []int
+	err = f.IterMetas(func(meta int) bool {
+		metas = append(metas, meta)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter metas: %v", err)
+	}
+	if len(metas) != 6 || metas[4] != 4 || metas[5] != 42 {
+		t.Fatalf("got %v", metas)
+	}
+
+	if _, err := NewMemoryWithOptions(colSetsFn, Options{Mmap: true}); err == nil {
+		t.Fatal("expecting error")
+	}
+	if err := f.Compact(100); err == nil {
+		t.Fatal("expecting error")
+	}
+}
+
+func TestMemoryFile(t *testing.T) {
+	m := new(memoryFile)
+	m.WriteAt([]byte("foobar"), 0)
+	m.WriteAt([]byte("baz"), 8)
+	if size, _ := m.Size(); size != 11 {
+		t.Fatalf("got size %d", size)
+	}
+	if err := m.Truncate(3); err != nil {
+		t.Fatal(err)
+	}
+	m.WriteAt([]byte("qux"), 3)
+	bs := make([]byte, 8)
+	n, err := m.ReadAt(bs, 0)
+	if err != io.EOF || string(bs[:n]) != "fooqux" {
+		t.Fatalf("got %q %v", bs[:n], err)
+	}
+	r, _, err := m.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Seek(5, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	n, err = r.Read(bs)
+	if err != nil || string(bs[:n]) != "x" {
+		t.Fatalf("got %q %v", bs[:n], err)
+	}
+	if _, err := r.Read(bs); err != io.EOF {
+		t.Fatalf("got %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package rcf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// remap maps the file again if it grew since the last mapping. returns true if the mapping grew.
+func (f *File) remap() (bool, error) {
+	f.Lock()
+	err := f.flush()
+	f.Unlock()
+	if err != nil {
+		return false, err
+	}
+	f.mapLock.Lock()
+	defer f.mapLock.Unlock()
+	file := f.backend.(*osBackend).File
+	info, err := file.Stat()
+	if err != nil {
+		return false, makeErr(err, "stat file")
+	}
+	if info.Size() <= int64(len(f.mapped)) {
+		return false, nil
+	}
+	data, err := mmapFile(file, info.Size())
+	if err != nil {
+		return false, makeErr(err, "mmap")
+	}
+	if f.mapped != nil {
+		if err := munmapFile(f.mapped); err != nil {
+			munmapFile(data)
+			return false, makeErr(err, "munmap")
+		}
+	}
+	f.mapped = data
+	return true, nil
+}
+
+// unmap releases the mapping, waiting for reads of the mapped region
+func (f *File) unmap() error {
+	f.mapLock.Lock()
+	defer f.mapLock.Unlock()
+	if f.mapped == nil {
+		return nil
+	}
+	err := munmapFile(f.mapped)
+	f.mapped = nil
+	if err != nil {
+		return makeErr(err, "munmap")
+	}
+	return nil
+}
+
+// readMappedBlock is ReadBlockAt on the mapped file. the file is mapped again if the block is beyond the mapping, like blocks appended after the last mapping.
+func (f *File) readMappedBlock(offset int64, metaTarget, columnsTarget interface{}) error {
+	for remapped := false; ; remapped = true {
+		f.mapLock.RLock()
+		// the file may be truncated by other handles, bytes of the mapping past the end fault when read
+		size, err := f.dataSize()
+		if err != nil {
+			f.mapLock.RUnlock()
+			return makeErr(err, "get file size")
+		}
+		if offset >= size {
+			f.mapLock.RUnlock()
+			return makeErr(nil, fmt.Sprintf("bad block offset %d", offset))
+		}
+		data := f.mapped
+		if int64(len(data)) > size {
+			data = data[:size:size]
+		}
+		cursor := &FrameCursor{
+			r:        bytes.NewReader(data),
+			data:     data,
+			flags:    f.flags,
+			wideSets: f.wideSets,
+		}
+		err = f.readBlock(cursor, offset, metaTarget, columnsTarget)
+		f.mapLock.RUnlock()
+		if err == nil || remapped {
+			return err
+		}
+		grown, remapErr := f.remap()
+		if remapErr != nil {
+			return remapErr
+		}
+		if !grown {
+			return err
+		}
+	}
+}
+
+// next reads the next n bytes. on mapped files, bytes are sliced from the mapping without copying.
+func (c *FrameCursor) next(n int64) ([]byte, error) {
+	if c.data == nil {
+		bs := make([]byte, n)
+		err := c.read(bs)
+		return bs, err
+	}
+	end := c.pos + n
+	if end > int64(len(c.data)) {
+		err := io.ErrUnexpectedEOF
+		if c.pos >= int64(len(c.data)) {
+			err = io.EOF
+		}
+		if seekErr := c.seek(int64(len(c.data))); seekErr != nil {
+			return nil, seekErr
+		}
+		return nil, err
+	}
+	bs := c.data[c.pos:end:end]
+	if err := c.seek(end); err != nil {
+		return nil, err
+	}
+	return bs, nil
+}
@@ -0,0 +1,125 @@
+package rcf
+
+import "time"
+
+// Compression selects the compression method of payloads
+type Compression uint8
+
+const (
+	// CompressAuto decides by the path: snappy if a dot-separated part of it is "snappy", zstd if "zstd", lz4 if "lz4", gzip if "gzip", none otherwise
+	CompressAuto Compression = iota
+	CompressNone
+	CompressSnappy
+	CompressZstd
+	CompressLZ4
+	CompressGzip
+)
+
+type Options struct {
+	// Compression is the compression method of a new file, and wins over the path.
+	// the method is stored in the file header, and existing files are always read and appended with the stored method.
+	// files without header, which are written by older versions, use Compression or the path.
+	Compression Compression
+
+	// CompressionLevel is the compression level of a new file, the default level of the method if zero. higher levels compress better and slower.
+	// levels are 1 to 22 for zstd, 1 to 9 for lz4 and gzip. snappy and no compression ignore it. invalid levels are errors.
+	// it is stored in the file header, and existing files are appended at the stored level. decoding does not depend on it.
+	CompressionLevel int
+
+	// Codec encodes metas and column sets of a new file, GobCodec if nil, or MsgpackCodec if a dot-separated part of the path is "msgpack".
+	// the id of the codec is stored in the file header. existing files of builtin codecs are read with the stored codec,
+	// and if Codec is not nil, it must match the stored codec.
+	Codec Codec
+
+	// UncompressedMeta writes meta payloads of a new file without compression, which saves the framing overhead of compressing tiny metas.
+	// column sets are still compressed. it is recorded in the file header, and ignored for existing files.
+	UncompressedMeta bool
+
+	// StampTime makes a new file stamp each block with the time of writing, for IterTimeRange. like UncompressedMeta, it is stored in the file header.
+	// blocks encoded again by Compact or CopyBlocks are stamped with the time they are written.
+	StampTime bool
+
+	// GobStream keeps one gob encoder per column set for the life of the File, so gob type descriptions are sent once per File instead of once per block.
+	// the column set payloads then form gob streams and must be decoded in file order, so:
+	// the mode is recorded in the header of new files, and readers follow it. files written by older versions in this mode must be opened with GobStream set too;
+	// column sets are decoded sequentially instead of in parallel;
+	// every block of a projected column set is decoded, even if its values are not used.
+	// each File starts new streams, so appending after reopening is fine.
+	// only the gob codec supports this mode.
+	GobStream bool
+
+	// SortColumn names a column to stably sort rows by in Append, before they are transposed into column sets.
+	// rows are reordered within a block, not across blocks, and all columns are reordered together.
+	// the column must be of integer, float or string type.
+	SortColumn string
+
+	// DictionaryColumns names string columns to dictionary encode in appended blocks: distinct values are stored once per block, and rows as indexes of them.
+	// it suits columns of few distinct values. each column set payload records its encoding, so files are readable regardless of this option,
+	// and it is ignored for files written by older versions. it can not be used with GobStream.
+	DictionaryColumns []string
+
+	// DeltaColumns names integer columns to delta encode in appended blocks: the first value is stored, then differences of successive values.
+	// it suits steadily increasing columns like ids or unix timestamps, whose differences are small. like DictionaryColumns, files are readable regardless of it,
+	// it is ignored for files written by older versions, and it can not be used with GobStream.
+	DeltaColumns []string
+
+	// DecodeParallelism is the number of goroutines of each decoding stage of iterations, runtime.NumCPU() if zero.
+	// 1 decodes blocks one by one. callbacks are called in file order regardless.
+	// MetaDecodeParallelism and ColumnDecodeParallelism override it for each stage.
+	DecodeParallelism int
+
+	// MetaDecodeParallelism is the number of goroutines decoding metas in iterations, DecodeParallelism if zero.
+	// MetaDecodeQueueSize is the number of blocks that can be queued for meta decoding, 100000 if zero.
+	// metas are usually small, a low parallelism leaves more CPU to column decoding.
+	MetaDecodeParallelism int
+	MetaDecodeQueueSize   int
+
+	// ColumnDecodeParallelism is the number of goroutines decoding column sets in iterations, DecodeParallelism if zero.
+	// ColumnDecodeQueueSize is the number of blocks that can be queued for column set decoding, 30000 if zero.
+	ColumnDecodeParallelism int
+	ColumnDecodeQueueSize   int
+
+	// CallbackQueueSize is the number of decoding blocks that can wait for their callbacks in iterations, 2048 if zero.
+	// large queues of the three stages let decoding run ahead of slow callbacks, but queued blocks are decoded columns held in memory.
+	// small queues bound the memory on constrained systems, and stall decoding when callbacks are slow.
+	CallbackQueueSize int
+
+	// OnProgress is called by the reading goroutine of iterations every 64 blocks, and once when all blocks are read, with the position of reading in the file, and the size of the file at the start of the iteration.
+	// it is called in the reading goroutine, so a slow OnProgress slows reading down.
+	OnProgress func(bytesRead, totalBytes int64)
+
+	// WriteBufferSize is the size of the buffer of appended blocks, no buffer if zero.
+	// callers doing many small Appends should set it, and call Flush periodically, since readers only see flushed blocks.
+	// Sync and Close flush the buffer, iterations on the File flush it too.
+	WriteBufferSize int
+
+	// FileLock acquires an advisory lock of the file (flock, or LockFileEx on windows) around each write, so handles in different processes can Append to the same file.
+	// it protects the framing of blocks only. offset indexes of other handles, as used by ReadBlock, are not updated.
+	// it can not be used with WriteBufferSize or GobStream.
+	FileLock bool
+
+	// AllowUnknownColumns makes Iter and its variants ignore requested columns not in the schema, instead of returning an error.
+	// callbacks get no values for unknown columns.
+	AllowUnknownColumns bool
+
+	// Mmap maps the file into memory to serve ReadBlock and ReadBlockAt, by slicing the mapping instead of reading the file.
+	// the file is mapped again when a read is beyond the mapping, like for blocks appended later. Close unmaps the file.
+	// codecs must not keep references to the decoded bytes.
+	Mmap bool
+
+	// PartialReads makes iterations and ReadBlock read only the projected column sets of checksummed blocks, skipping the other sets without reading them.
+	// checksums are verified only for blocks read as a whole then. it suits files in object stores read by NewReaderAt, where reads are ranged requests.
+	PartialReads bool
+
+	// IndexFile persists the offset index of blocks, as used by ReadBlock, IterReverse, Count and LastMeta, to a sidecar file of path + ".idx".
+	// the sidecar is written by Close, and loaded by opening if the size and the modification time of the file are as recorded. otherwise the index is built by scanning headers as usual.
+	// it can not be used with NewReaderAt. files opened by Open load the sidecar, but do not write it.
+	IndexFile bool
+
+	// TruncateTornTail makes the first append truncate a partially written block at the end of the file, as left by a crash during Append, instead of returning ErrTornBlock.
+	// without it, appends to such files fail until Repair or Truncate is called. corrupted blocks of complete lengths are not detected, Repair verifies checksums.
+	TruncateTornTail bool
+
+	// FollowInterval is how often Follow checks the file for new blocks, 100 milliseconds if zero
+	FollowInterval time.Duration
+}
@@ -0,0 +1,60 @@
+package rcf
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// payloadFile returns a File encoding and decoding column set payloads like a new file of the compression, with other options default
+func payloadFile(compression Compression) (*File, error) {
+	method, err := compressMethod(compression)
+	if err != nil {
+		return nil, err
+	}
+	return &File{
+		compressMethod: method,
+		codec:          GobCodec{},
+		flags:          _NEW_FILE_FLAGS,
+	}, nil
+}
+
+// EncodeColumnSet encodes v, a column set like values returned by colSetsFn of New, as a payload of a new file of the compression with default options:
+// the gob codec, the default compression level, and no dictionary or delta encoded columns. compression must not be CompressAuto.
+// payloads of it can be appended to such files by AppendEncoded.
+func EncodeColumnSet(compression Compression, v interface{}) ([]byte, error) {
+	f, err := payloadFile(compression)
+	if err != nil {
+		return nil, err
+	}
+	if err := registerGob(v); err != nil {
+		return nil, err
+	}
+	return f.encodeSet(0, v)
+}
+
+// DecodeColumnSet decodes column set payload bs, of a file of the compression with default codec, into target, a pointer to the column set struct.
+// payloads of dictionary or delta encoded columns are decoded too.
+func DecodeColumnSet(compression Compression, bs []byte, target interface{}) error {
+	f, err := payloadFile(compression)
+	if err != nil {
+		return err
+	}
+	t := reflect.TypeOf(target)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return makeErr(nil, fmt.Sprintf("target is not a pointer to struct: %T", target))
+	}
+	if err := registerGob(target); err != nil {
+		return err
+	}
+	// sets are encoded as interface values
+	var set interface{}
+	if err := f.decodeSetPayload(bs, &set); err != nil {
+		return makeKindErr(KindDecode, err, "decode column set")
+	}
+	v := reflect.ValueOf(set)
+	if v.Type() != t {
+		return makeErr(nil, fmt.Sprintf("column set is %v, not %v", v.Type(), t))
+	}
+	reflect.ValueOf(target).Elem().Set(v.Elem())
+	return nil
+}
@@ -0,0 +1,100 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestColumnSetPayload(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	type FooSet struct {
+		Foo []int
+	}
+	type BarSet struct {
+		Bar []string
+	}
+	for _, compression := range []Compression{
+		CompressNone, CompressSnappy, CompressZstd, CompressLZ4, CompressGzip,
+	} {
+		fooBin, err := EncodeColumnSet(compression, &FooSet{Foo: []int{1, 2}})
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		barBin, err := EncodeColumnSet(compression, &BarSet{Bar: []string{"1", "2"}})
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+
+		// round trip
+		var fooSet FooSet
+		if err := DecodeColumnSet(compression, fooBin, &fooSet); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if len(fooSet.Foo) != 2 || fooSet.Foo[1] != 2 {
+			t.Fatalf("got %v", fooSet.Foo)
+		}
+		var barSet BarSet
+		if err := DecodeColumnSet(compression, fooBin, &barSet); err == nil {
+			t.Fatal("should fail")
+		}
+
+		// appended to a file of the compression
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+		f, err := NewWithOptions(path, func(i int) (ret interface{}) {
+			switch i {
+			case 0:
+				ret = &FooSet{}
+			case 1:
+				ret = &BarSet{}
+			}
+			return
+		}, Options{
+			Compression: compression,
+		})
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		if err := f.AppendEncoded(nil, [][]byte{fooBin, barBin}); err != nil {
+			t.Fatalf("append encoded: %v", err)
+		}
+		if err := f.Append([]Foo{{3, "3"}}, nil); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		var meta *int
+		var columns struct {
+			Foo []int
+			Bar []string
+		}
+		if err := f.ReadBlock(0, &meta, &columns); err != nil {
+			t.Fatalf("read block: %v", err)
+		}
+		if meta != nil || len(columns.Foo) != 2 || columns.Foo[0] != 1 || columns.Bar[1] != "2" {
+			t.Fatalf("got %v %v %v", meta, columns.Foo, columns.Bar)
+		}
+
+		// payloads of the file
+		raw, err := f.encodeSet(1, &BarSet{Bar: []string{"3"}})
+		if err != nil {
+			t.Fatalf("encode set: %v", err)
+		}
+		if err := DecodeColumnSet(compression, raw, &barSet); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if len(barSet.Bar) != 1 || barSet.Bar[0] != "3" {
+			t.Fatalf("got %v", barSet.Bar)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+	}
+
+	if _, err := EncodeColumnSet(CompressAuto, &FooSet{}); err == nil {
+		t.Fatal("should fail")
+	}
+}
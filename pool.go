@@ -0,0 +1,137 @@
+package rcf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+// pools of encoding buffers, and of compressors and decompressors of each compression method.
+// encode and decode take from them and put back after use. other users of compressWriter and decompressReader may leave values to the GC.
+// compressors of methods with levels are pooled by level, the default level at 0.
+var (
+	bufferPool = sync.Pool{
+		New: func() interface{} {
+			return new(bytes.Buffer)
+		},
+	}
+	snappyWriterPool sync.Pool
+	zstdWriterPools  [_MAX_LEVEL_ZSTD + 1]sync.Pool
+	lz4WriterPools   [_MAX_LEVEL_LZ4 + 1]sync.Pool
+	gzipWriterPools  [_MAX_LEVEL_GZIP + 1]sync.Pool
+	snappyReaderPool sync.Pool
+	lz4ReaderPool    sync.Pool
+)
+
+// lz4Levels are lz4 levels of compression levels
+var lz4Levels = [_MAX_LEVEL_LZ4 + 1]lz4.CompressionLevel{
+	lz4.Fast,
+	lz4.Level1, lz4.Level2, lz4.Level3, lz4.Level4, lz4.Level5, lz4.Level6, lz4.Level7, lz4.Level8, lz4.Level9,
+}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > 16<<20 { // do not keep buffers of huge blocks
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+func (f *File) compressWriter(w io.Writer) io.WriteCloser {
+	switch f.compressMethod {
+	case _COMPRESS_SNAPPY:
+		if v := snappyWriterPool.Get(); v != nil {
+			sw := v.(*snappy.Writer)
+			sw.Reset(w)
+			return sw
+		}
+		return snappy.NewWriter(w)
+	case _COMPRESS_ZSTD:
+		if v := zstdWriterPools[f.compressLevel].Get(); v != nil {
+			zw := v.(*zstd.Encoder)
+			zw.Reset(w)
+			return zw
+		}
+		return newZstdWriter(w, f.compressLevel)
+	case _COMPRESS_LZ4:
+		if v := lz4WriterPools[f.compressLevel].Get(); v != nil {
+			lw := v.(*lz4.Writer)
+			lw.Reset(w)
+			return lw
+		}
+		lw := lz4.NewWriter(w)
+		// the level is checked, no error
+		lw.Apply(lz4.CompressionLevelOption(lz4Levels[f.compressLevel]))
+		return lw
+	case _COMPRESS_GZIP:
+		if v := gzipWriterPools[f.compressLevel].Get(); v != nil {
+			gw := v.(*gzip.Writer)
+			gw.Reset(w)
+			return gw
+		}
+		return newGzipWriter(w, f.compressLevel)
+	}
+	return nopWriteCloser{w}
+}
+
+// putCompressWriter puts w returned by compressWriter back to its pool. w must be closed, or not be used anymore.
+func (f *File) putCompressWriter(w io.WriteCloser) {
+	switch w := w.(type) {
+	case *snappy.Writer:
+		snappyWriterPool.Put(w)
+	case *zstd.Encoder:
+		zstdWriterPools[f.compressLevel].Put(w)
+	case *lz4.Writer:
+		lz4WriterPools[f.compressLevel].Put(w)
+	case *gzip.Writer:
+		gzipWriterPools[f.compressLevel].Put(w)
+	}
+}
+
+func (f *File) decompressReader(r io.Reader) io.Reader {
+	switch f.compressMethod {
+	case _COMPRESS_SNAPPY:
+		if v := snappyReaderPool.Get(); v != nil {
+			sr := v.(*snappy.Reader)
+			sr.Reset(r)
+			return sr
+		}
+		return snappy.NewReader(r)
+	case _COMPRESS_ZSTD:
+		return &zstdReader{r: r}
+	case _COMPRESS_LZ4:
+		if v := lz4ReaderPool.Get(); v != nil {
+			lr := v.(*lz4.Reader)
+			lr.Reset(r)
+			return lr
+		}
+		return lz4.NewReader(r)
+	case _COMPRESS_GZIP:
+		return &gzipReader{r: r}
+	}
+	return r
+}
+
+// putDecompressReader puts r returned by decompressReader back to its pool
+func putDecompressReader(r io.Reader) {
+	switch r := r.(type) {
+	case *snappy.Reader:
+		snappyReaderPool.Put(r)
+	case *lz4.Reader:
+		lz4ReaderPool.Put(r)
+	case *gzipReader:
+		if r.zr != nil {
+			gzipReaderPool.Put(r.zr)
+		}
+	}
+}
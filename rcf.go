@@ -1,209 +1,807 @@
 package rcf
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/binary"
 	"encoding/gob"
+	"errors"
 	"fmt"
-	"github.com/golang/snappy"
-	"github.com/reusee/pipeline"
-	"gopkg.in/vmihailenco/msgpack.v2"
 	"io"
+	"math"
 	"os"
 	"reflect"
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 )
 
 const (
 	_COMPRESS_NONE = iota
 	_COMPRESS_SNAPPY
+	_COMPRESS_ZSTD
+	_COMPRESS_LZ4
+	_COMPRESS_GZIP
 )
 
+// the highest compression levels
 const (
-	_CODEC_GOB = iota
-	_CODEC_MSGPACK
+	_MAX_LEVEL_ZSTD = 22
+	_MAX_LEVEL_LZ4  = 9
+	_MAX_LEVEL_GZIP = 9
 )
 
 type File struct {
+	bytesRead int64 // accessed atomically, first for the alignment on 32-bit platforms
 	sync.Mutex
-	file           *os.File
-	path           string
-	colSets        [][]string
-	colSetsFn      func(int) interface{}
-	validateOnce   sync.Once
-	compressMethod int
-	codec          int
+	backend             backend
+	writeOffset         int64 // where the next block is written, guarded by the mutex
+	readOnly            bool  // opened by Open or NewReaderAt
+	partialReads        bool
+	path                string
+	colSets             [][]string
+	colSetsFn           func(int) interface{}
+	validateOnce        sync.Once
+	validateErr         error // returned by validate until the file is truncated
+	compressMethod      uint8
+	compressLevel       int // 0 for the default level of the method
+	codec               Codec
+	gobStream           bool
+	sortColumn          string
+	dictFields          [][]int // indexes of dictionary encoded fields of each column set
+	deltaFields         [][]int // indexes of delta encoded fields of each column set
+	metaDecode          stageConfig
+	columnDecode        stageConfig
+	callbackQueueSize   int
+	onProgress          func(bytesRead, totalBytes int64)
+	streamEncoders      []*gobStreamEncoder
+	dataStart           int64 // offset of the first block
+	version             uint8 // format version in the file header, 0 if the file has no header
+	flags               uint8 // layout of block headers
+	wideSets            bool  // block headers have uint16 numbers of column sets
+	buffer              *bufio.Writer
+	fileLock            bool
+	allowUnknownColumns bool
+	mmap                bool
+	mapLock             sync.RWMutex // guards reads of the mapped region, and remapping
+	mapped              []byte
+	blockIndex          []int64 // offsets of scanned blocks, guarded by the mutex
+	blockIndexEnd       int64   // end of the last scanned block
+	closed              bool    // guarded by the mutex
+	indexFile           bool
+	truncateTornTail    bool
+	followInterval      time.Duration
+	opts                Options // options of the File, for Reopen
 }
 
 func (f *File) Sync() error {
 	f.Lock()
 	defer f.Unlock()
-	return f.file.Sync()
+	if err := f.flush(); err != nil {
+		return err
+	}
+	if f.readOnly {
+		return nil
+	}
+	return f.backend.Sync()
+}
+
+// Flush writes buffered blocks to the file. it is a no-op if Options.WriteBufferSize is zero.
+func (f *File) Flush() error {
+	f.Lock()
+	defer f.Unlock()
+	return f.flush()
+}
+
+func (f *File) flush() error {
+	if f.buffer == nil {
+		return nil
+	}
+	if err := f.buffer.Flush(); err != nil {
+		return makeErr(err, "flush")
+	}
+	return nil
+}
+
+// lockFile acquires the advisory file lock if Options.FileLock is set, and moves to the end of the file, which may be appended by other handles.
+// must be called with the mutex held.
+func (f *File) lockFile() (unlock func(), err error) {
+	if !f.fileLock {
+		return func() {}, nil
+	}
+	file := f.backend.(*osBackend).File
+	if err := lockFile(file); err != nil {
+		return nil, makeErr(err, "lock file")
+	}
+	size, err := f.backend.Size()
+	if err != nil {
+		unlockFile(file)
+		return nil, err
+	}
+	f.writeOffset = size
+	return func() {
+		unlockFile(file)
+	}, nil
 }
 
+// writer returns the writer of blocks
+func (f *File) writer() io.Writer {
+	if f.buffer != nil {
+		return f.buffer
+	}
+	return backendWriter{f}
+}
+
+// Close flushes buffered blocks and closes the file. later calls return nil.
 func (f *File) Close() error {
-	return f.file.Close()
+	f.Lock()
+	if f.closed {
+		f.Unlock()
+		return nil
+	}
+	f.closed = true
+	err := f.flush()
+	f.Unlock()
+	if err == nil && f.indexFile && !f.readOnly {
+		err = f.saveIndexFile()
+	}
+	if err == nil {
+		err = f.unmap()
+	}
+	if err != nil {
+		f.closeFile()
+		return err
+	}
+	return f.closeFile()
+}
+
+// closeFile closes the backend
+func (f *File) closeFile() error {
+	return f.backend.Close()
 }
 
+// Reopen closes the file, then opens or creates the file at path in place of it, with the column sets and the options of f.
+// files opened by Open are opened for reading only again. memory files and files of io.ReaderAt can not be reopened.
+// it must not be called concurrently with other methods. if an error is returned, f is closed.
+func (f *File) Reopen(path string) error {
+	if _, ok := f.backend.(*osBackend); !ok {
+		return makeErr(nil, "only files opened by path can be reopened")
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	var file *os.File
+	var err error
+	if f.readOnly {
+		file, err = os.Open(path)
+	} else {
+		file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	}
+	if err != nil {
+		return makeErr(err, "open file")
+	}
+	if err := f.init(path, &osBackend{file, path}, f.readOnly, f.colSetsFn, f.opts); err != nil {
+		f.closed = true
+		return err
+	}
+	return nil
+}
+
+// New opens or creates the file at path, with column sets returned by colSetsFn.
+// colSetsFn returns a pointer to a new struct of slice fields for each set index, and nil after the last set. each column must be in only one set.
+// named struct types, like *FooSet of type FooSet struct { Foo []int }, are recommended over anonymous ones, since gob names types by their string forms,
+// which are long for anonymous structs, and may conflict for types of the same name in different scopes. files written with either are read with the other.
+// an existing file may be opened with new column sets added after the existing ones. new blocks are appended with all sets, and columns of the new sets read from older blocks are empty slices.
 func New(path string, colSetsFn func(int) interface{}) (*File, error) {
+	return NewWithOptions(path, colSetsFn, Options{})
+}
+
+func NewWithOptions(path string, colSetsFn func(int) interface{}, opts Options) (*File, error) {
 	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
 	if err != nil {
 		return nil, makeErr(err, "open file")
 	}
-	n := 0
-	colSets := [][]string{}
-	for {
-		v := colSetsFn(n)
-		if v == nil {
-			break
-		}
-		gob.Register(v)
-		t := reflect.TypeOf(v).Elem()
-		set := []string{}
-		for i, max := 0, t.NumField(); i < max; i++ {
-			set = append(set, t.Field(i).Name)
-		}
-		colSets = append(colSets, set)
-		n++
+	return newFile(path, &osBackend{file, path}, false, colSetsFn, opts)
+}
+
+// Open opens the existing file at path for reading only. it returns an error if there is no file, and appending to the File returns an error.
+func Open(path string, colSetsFn func(int) interface{}) (*File, error) {
+	return OpenWithOptions(path, colSetsFn, Options{})
+}
+
+// OpenWithOptions is Open with options, like NewWithOptions
+func OpenWithOptions(path string, colSetsFn func(int) interface{}, opts Options) (*File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, makeErr(err, "open file")
+	}
+	return newFile(path, &osBackend{file, path}, true, colSetsFn, opts)
+}
+
+// newFile returns a File of the backend. path decides compression and codec like in NewWithOptions.
+// files of io.ReaderAt are always read-only. the backend is closed if an error is returned.
+func newFile(path string, b backend, readOnly bool, colSetsFn func(int) interface{}, opts Options) (*File, error) {
+	f := new(File)
+	if err := f.init(path, b, readOnly, colSetsFn, opts); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// init sets up f for the backend, like newFile
+func (f *File) init(path string, b backend, readOnly bool, colSetsFn func(int) interface{}, opts Options) error {
+	if _, inMemory := b.(*memoryFile); inMemory && (opts.Mmap || opts.IndexFile || opts.FileLock) {
+		b.Close()
+		return makeErr(nil, "mmap, index file and file lock are not supported for memory files")
+	}
+	if _, ofReader := b.(*readerAtBackend); ofReader && (opts.Mmap || opts.IndexFile) {
+		b.Close()
+		return makeErr(nil, "mmap and index file are not supported for io.ReaderAt")
+	}
+	colSets, err := schemaColSets(colSetsFn)
+	if err != nil {
+		b.Close()
+		return err
 	}
-	ret := &File{
-		file:      file,
-		path:      path,
-		colSets:   colSets,
-		colSetsFn: colSetsFn,
+	parallelism := opts.DecodeParallelism
+	if parallelism <= 0 {
+		parallelism = runtime.NumCPU()
+	}
+	*f = File{
+		backend:    b,
+		readOnly:   readOnly,
+		path:       path,
+		colSets:    colSets,
+		colSetsFn:  colSetsFn,
+		codec:      GobCodec{},
+		gobStream:  opts.GobStream,
+		sortColumn: opts.SortColumn,
+		metaDecode: stageConfig{
+			parallelism: opts.MetaDecodeParallelism,
+			queueSize:   opts.MetaDecodeQueueSize,
+		}.withDefaults(parallelism, 100000),
+		columnDecode: stageConfig{
+			parallelism: opts.ColumnDecodeParallelism,
+			queueSize:   opts.ColumnDecodeQueueSize,
+		}.withDefaults(parallelism, 30000),
+		callbackQueueSize: opts.CallbackQueueSize,
+		onProgress:        opts.OnProgress,
+		opts:              opts,
 	}
 	parts := strings.Split(path, ".")
 	for _, part := range parts {
 		switch part {
 		case "snappy":
-			ret.compressMethod = _COMPRESS_SNAPPY
+			f.compressMethod = _COMPRESS_SNAPPY
+		case "zstd":
+			f.compressMethod = _COMPRESS_ZSTD
+		case "lz4":
+			f.compressMethod = _COMPRESS_LZ4
+		case "gzip":
+			f.compressMethod = _COMPRESS_GZIP
 		case "msgpack":
-			ret.codec = _CODEC_MSGPACK
+			f.codec = MsgpackCodec{}
+		}
+	}
+	if opts.Codec != nil {
+		f.codec = opts.Codec
+	}
+	if opts.Compression != CompressAuto {
+		method, err := compressMethod(opts.Compression)
+		if err != nil {
+			f.closeFile()
+			return err
+		}
+		f.compressMethod = method
+	}
+	f.compressLevel = opts.CompressionLevel
+	err = f.initHeader(opts)
+	if err != nil {
+		f.closeFile()
+		return err
+	}
+	if f.callbackQueueSize <= 0 {
+		f.callbackQueueSize = 2048
+	}
+	if opts.WriteBufferSize > 0 {
+		f.buffer = bufio.NewWriterSize(backendWriter{f}, opts.WriteBufferSize)
+	}
+	f.allowUnknownColumns = opts.AllowUnknownColumns
+	f.partialReads = opts.PartialReads
+	if opts.Mmap {
+		f.mmap = true
+		if _, err := f.remap(); err != nil {
+			f.closeFile()
+			return err
 		}
 	}
-	return ret, nil
+	if opts.IndexFile {
+		f.indexFile = true
+		if err := f.loadIndexFile(); err != nil {
+			f.closeFile()
+			return err
+		}
+	}
+	f.truncateTornTail = opts.TruncateTornTail
+	f.followInterval = opts.FollowInterval
+	if f.followInterval <= 0 {
+		f.followInterval = 100 * time.Millisecond
+	}
+	if opts.FileLock {
+		if opts.WriteBufferSize > 0 || f.gobStream {
+			f.closeFile()
+			return makeErr(nil, "file lock can not be used with write buffer or gob stream mode")
+		}
+		f.fileLock = true
+	}
+	if f.sortColumn != "" {
+		err = f.checkSortColumn()
+		if err != nil {
+			f.closeFile()
+			return err
+		}
+	}
+	err = f.checkDictColumns(opts.DictionaryColumns)
+	if err != nil {
+		f.closeFile()
+		return err
+	}
+	err = f.checkDeltaColumns(opts.DeltaColumns)
+	if err != nil {
+		f.closeFile()
+		return err
+	}
+	if f.gobStream {
+		if f.codec.ID() != _CODEC_GOB {
+			f.closeFile()
+			return makeErr(nil, "gob stream mode requires gob codec")
+		}
+		f.streamEncoders = make([]*gobStreamEncoder, len(colSets))
+	}
+	err = f.checkSchema()
+	if err != nil {
+		f.closeFile()
+		return err
+	}
+	return nil
 }
 
-func (f *File) validate() (err error) {
-	f.validateOnce.Do(func() {
-	read:
-		// read number of sets
-		var numSets uint8
-		err = binary.Read(f.file, binary.LittleEndian, &numSets)
-		if err == io.EOF { // no more
-			return
+// initHeader writes the header of an empty file, or reads the header of an existing file.
+// if opts.Codec is set, the codec of an existing file must be f.codec.
+// the file is positioned at the first block after return.
+func (f *File) initHeader(opts Options) error {
+	size, err := f.dataSize()
+	if err != nil {
+		return err
+	}
+	if size == 0 && f.readOnly {
+		// empty read-only files are read as files without header
+	} else if size == 0 {
+		f.flags = _NEW_FILE_FLAGS
+		if opts.UncompressedMeta {
+			f.flags |= _FLAG_PLAIN_META
+		}
+		if opts.StampTime {
+			f.flags |= _FLAG_TIME
+		}
+		if f.gobStream {
+			f.flags |= _FLAG_GOB_STREAM
+		}
+		f.wideSets = len(f.colSets) > math.MaxUint8
+		f.version = formatVersion(f.wideSets)
+		if err := f.checkCompressLevel(); err != nil {
+			return err
 		}
+		if _, max := compressLevels(f.compressMethod); max == 0 {
+			f.compressLevel = 0 // ignored
+		}
+		bs := fileHeader{
+			Version:        f.version,
+			CompressMethod: f.compressMethod,
+			Flags:          f.flags,
+			Codec:          f.codec.ID(),
+			CompressLevel:  uint8(f.compressLevel),
+		}.marshal()
+		_, err = f.backend.WriteAt(bs, 0)
 		if err != nil {
-			err = makeErr(err, "read number of column sets")
-			return
+			return makeErr(err, "write header")
 		}
-		// read meta length
-		var sum, l uint32
-		err = binary.Read(f.file, binary.LittleEndian, &l)
+		f.dataStart = int64(len(bs))
+	} else {
+		r, closer, err := f.openData()
 		if err != nil {
-			err = makeErr(err, "read meta length")
-			return
+			return err
 		}
-		sum += l
-		// read sets length
-		for i, max := 0, int(numSets); i < max; i++ {
-			err = binary.Read(f.file, binary.LittleEndian, &l)
-			if err != nil {
-				err = makeErr(err, "read column set length")
-				return
-			}
-			sum += l
+		header, size, ok, err := readFileHeader(r)
+		if closer != nil {
+			closer.Close()
 		}
-		_, err = f.file.Seek(int64(sum), os.SEEK_CUR)
 		if err != nil {
-			err = makeErr(err, "validate seek")
-			return
+			return err
+		}
+		if ok {
+			if header.Version == 0 || header.Version > _FORMAT_VERSION {
+				return makeErr(nil, fmt.Sprintf("unsupported format version %d", header.Version))
+			}
+			if header.ByteOrder != _BYTE_ORDER_LITTLE {
+				return makeErr(nil, fmt.Sprintf("unsupported byte order %q in header, expecting little endian", header.ByteOrder))
+			}
+			if header.CompressMethod > _COMPRESS_GZIP {
+				return makeErr(nil, fmt.Sprintf("unknown compression method %d in header", header.CompressMethod))
+			}
+			if header.Flags&^_KNOWN_FLAGS != 0 {
+				return makeErr(nil, fmt.Sprintf("unknown flags %b in header", header.Flags))
+			}
+			if header.Flags&_FLAG_ROWS > 0 && header.Version < 3 {
+				return makeErr(nil, fmt.Sprintf("row counts in format version %d", header.Version))
+			}
+			if header.hasCodec && header.Codec != f.codec.ID() {
+				codec, ok := builtinCodecs[header.Codec]
+				if opts.Codec != nil || !ok {
+					return makeErr(nil, fmt.Sprintf("file is encoded by codec %d, not %d", header.Codec, f.codec.ID()))
+				}
+				f.codec = codec
+			}
+			f.compressMethod = header.CompressMethod
+			f.compressLevel = int(header.CompressLevel)
+			f.flags = header.Flags
+			if f.flags&_FLAG_GOB_STREAM > 0 {
+				// files written by older versions in gob stream mode have no flag, and are read as Options.GobStream says
+				f.gobStream = true
+			}
+			f.wideSets = header.Version >= _WIDE_SETS_VERSION
+			f.version = header.Version
+			f.dataStart = size
+		}
+		if err := f.checkCompressLevel(); err != nil {
+			return err
 		}
-		goto read
-		return
+	}
+	f.blockIndexEnd = f.dataStart
+	f.writeOffset = f.dataStart
+	return nil
+}
+
+// validate seeks to the end of the last block before the first append, and returns the error if any block is malformed or cut short.
+// the error is kept, so appends fail until the file is repaired by Truncate or Repair. a torn last block is truncated instead if truncateTornTail is set.
+func (f *File) validate() error {
+	f.validateOnce.Do(func() {
+		err := f.seekEnd()
+		f.Lock()
+		f.validateErr = err
+		f.Unlock()
 	})
-	return
+	f.Lock()
+	defer f.Unlock()
+	return f.validateErr
 }
 
-func (f *File) encode(o interface{}) (bs []byte, err error) {
-	buf := new(bytes.Buffer)
-	if f.compressMethod == _COMPRESS_SNAPPY {
-		w := snappy.NewWriter(buf)
-		if f.codec == _CODEC_GOB {
-			err = gob.NewEncoder(w).Encode(o)
-		} else if f.codec == _CODEC_MSGPACK {
-			err = msgpack.NewEncoder(w).Encode(o)
+func (f *File) seekEnd() error {
+	if f.readOnly { // not to be appended
+		return nil
+	}
+	size, err := f.dataSize()
+	if err != nil {
+		return err
+	}
+	r, closer, err := f.backend.Reader()
+	if err != nil {
+		return err
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+	if _, err := r.Seek(f.dataStart, os.SEEK_SET); err != nil {
+		return makeErr(err, "seek")
+	}
+	cursor, err := NewFrameCursor(r)
+	if err != nil {
+		return err
+	}
+	cursor.flags = f.flags
+	cursor.wideSets = f.wideSets
+	n := 0
+	end := f.dataStart
+	for {
+		header, err := cursor.NextHeader()
+		if err == io.EOF { // no more
+			f.Lock()
+			f.writeOffset = end
+			f.Unlock()
+			return nil
 		}
-		if err != nil {
-			return nil, err
+		if err == nil && header.Offset+header.Size() > size {
+			err = makeKindErr(KindTornBlock, &TornBlockError{
+				Offset:   header.Offset,
+				Expected: header.Size(),
+				Got:      size - header.Offset,
+			}, "torn block")
+		}
+		if isTornBlock(err) && f.truncateTornTail {
+			// drop the partially written block, and seek to the end of the last complete one
+			return f.Truncate(n)
 		}
-		err = w.Close()
 		if err != nil {
-			return nil, err
+			return makeErr(err, "validate file")
 		}
-	} else {
-		if f.codec == _CODEC_GOB {
-			err = gob.NewEncoder(buf).Encode(o)
-		} else if f.codec == _CODEC_MSGPACK {
-			err = msgpack.NewEncoder(buf).Encode(o)
+		end = header.Offset + header.Size()
+		n++
+	}
+}
+
+// schemaColSets returns column names of the sets returned by colSetsFn, and registers the sets to gob. it returns an error if a column is in more than one set.
+func schemaColSets(colSetsFn func(int) interface{}) ([][]string, error) {
+	colSets := [][]string{}
+	sets := make(map[string]int) // set index of each column
+	for n := 0; ; n++ {
+		v := colSetsFn(n)
+		if v == nil {
+			break
 		}
-		if err != nil {
-			return nil, err
+		if err := registerGob(v); err != nil {
+			return nil, makeErr(err, fmt.Sprintf("column set %d", n))
 		}
+		t := reflect.TypeOf(v).Elem()
+		set := []string{}
+		for i, max := 0, t.NumField(); i < max; i++ {
+			name := t.Field(i).Name
+			if m, ok := sets[name]; ok {
+				return nil, makeErr(nil, fmt.Sprintf("column %s is in both column set %d and %d", name, m, n))
+			}
+			sets[name] = n
+			set = append(set, name)
+		}
+		colSets = append(colSets, set)
 	}
-	return buf.Bytes(), nil
+	return colSets, nil
+}
+
+// registerGob registers v to gob, and returns an error instead of panicking if another type is registered by the same name.
+// gob names types by their string forms, which are the same for types of the same name in different scopes or packages, like struct { Foo []main.Bar }.
+func registerGob(v interface{}) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = makeErr(nil, fmt.Sprintf("%T conflicts with another type of the same name registered to gob: %v", v, p))
+		}
+	}()
+	gob.Register(v)
+	return nil
+}
+
+// compressMethod returns the compression method of the option value, which must not be CompressAuto
+func compressMethod(c Compression) (uint8, error) {
+	switch c {
+	case CompressNone:
+		return _COMPRESS_NONE, nil
+	case CompressSnappy:
+		return _COMPRESS_SNAPPY, nil
+	case CompressZstd:
+		return _COMPRESS_ZSTD, nil
+	case CompressLZ4:
+		return _COMPRESS_LZ4, nil
+	case CompressGzip:
+		return _COMPRESS_GZIP, nil
+	}
+	return 0, makeErr(nil, fmt.Sprintf("unknown compression %d", c))
+}
+
+// compressLevels returns the name and the highest compression level of the compression method, or 0 if it has no levels.
+// levels start from 1, and 0 is the default level of the method.
+func compressLevels(method uint8) (name string, max int) {
+	switch method {
+	case _COMPRESS_ZSTD:
+		return "zstd", _MAX_LEVEL_ZSTD
+	case _COMPRESS_LZ4:
+		return "lz4", _MAX_LEVEL_LZ4
+	case _COMPRESS_GZIP:
+		return "gzip", _MAX_LEVEL_GZIP
+	}
+	return "", 0
+}
+
+// checkCompressLevel returns an error if the compression level is invalid for the compression method
+func (f *File) checkCompressLevel() error {
+	name, max := compressLevels(f.compressMethod)
+	if max == 0 {
+		return nil
+	}
+	if f.compressLevel < 0 || f.compressLevel > max {
+		return makeErr(nil, fmt.Sprintf("invalid %s compression level %d, expecting 1 to %d, or 0 for the default", name, f.compressLevel, max))
+	}
+	return nil
+}
+
+// compression returns the option value of the compression method
+func (f *File) compression() Compression {
+	switch f.compressMethod {
+	case _COMPRESS_SNAPPY:
+		return CompressSnappy
+	case _COMPRESS_ZSTD:
+		return CompressZstd
+	case _COMPRESS_LZ4:
+		return CompressLZ4
+	case _COMPRESS_GZIP:
+		return CompressGzip
+	}
+	return CompressNone
+}
+
+func (f *File) encode(o interface{}) (bs []byte, err error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	w := f.compressWriter(buf)
+	defer f.putCompressWriter(w)
+	err = f.codec.Encode(w, o)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), buf.Bytes()...), nil
 }
 
 func (f *File) decode(bs []byte, target interface{}) (err error) {
-	var r io.Reader
-	if f.compressMethod == _COMPRESS_SNAPPY {
-		r = snappy.NewReader(bytes.NewReader(bs))
-	} else {
-		r = bytes.NewReader(bs)
+	r := f.decompressReader(bytes.NewReader(bs))
+	defer putDecompressReader(r)
+	return f.codec.Decode(r, target)
+}
+
+// metaCompressWriter is compressWriter for meta payloads, which are not compressed if Options.UncompressedMeta was set for the file
+func (f *File) metaCompressWriter(w io.Writer) io.WriteCloser {
+	if f.flags&_FLAG_PLAIN_META > 0 {
+		return nopWriteCloser{w}
 	}
-	if f.codec == _CODEC_GOB {
-		return gob.NewDecoder(r).Decode(target)
-	} else if f.codec == _CODEC_MSGPACK {
-		return msgpack.NewDecoder(r).Decode(target)
+	return f.compressWriter(w)
+}
+
+// metaDecompressReader is decompressReader for meta payloads
+func (f *File) metaDecompressReader(r io.Reader) io.Reader {
+	if f.flags&_FLAG_PLAIN_META > 0 {
+		return r
 	}
-	return fmt.Errorf("not reachable")
+	return f.decompressReader(r)
 }
 
+// encodeMeta encodes meta. nil metas are encoded as empty payloads.
+func (f *File) encodeMeta(meta interface{}) ([]byte, error) {
+	if meta == nil {
+		return nil, nil
+	}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	w := f.metaCompressWriter(buf)
+	defer f.putCompressWriter(w)
+	err := f.codec.Encode(w, meta)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), buf.Bytes()...), nil
+}
+
+// decodeMeta decodes meta payload bs into target, a pointer. target is set to the zero value if bs is empty, as encoded from a nil meta.
+func (f *File) decodeMeta(bs []byte, target interface{}) error {
+	if len(bs) == 0 {
+		v := reflect.ValueOf(target).Elem()
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	r := f.metaDecompressReader(bytes.NewReader(bs))
+	defer putDecompressReader(r)
+	return f.codec.Decode(r, target)
+}
+
+// decodeSet decodes a column set payload. decoders is nil unless in gob stream mode.
+// if target points to an interface value of a column set, and the payload is decoded as another registered type, like a set of the same fields declared in another order,
+// the decoded set is converted to the type of the set by field names, so that fields of sets can be accessed by their positions in the schema.
+func (f *File) decodeSet(decoders []*gobStreamDecoder, n int, bs []byte, target interface{}) error {
+	p, ok := target.(*interface{})
+	if !ok {
+		return f.decodeSetAs(decoders, n, bs, target)
+	}
+	t := reflect.TypeOf(*p)
+	if err := f.decodeSetAs(decoders, n, bs, target); err != nil {
+		return err
+	}
+	if decoded := reflect.ValueOf(*p); t != nil && decoded.Type() != t {
+		*p = conformSet(decoded, t).Interface()
+	}
+	return nil
+}
+
+// decodeSetAs decodes a column set payload into target as is
+func (f *File) decodeSetAs(decoders []*gobStreamDecoder, n int, bs []byte, target interface{}) error {
+	if decoders != nil {
+		return f.decodeStream(decoders, n, bs, target)
+	}
+	return f.decodeSetPayload(bs, target)
+}
+
+// Append appends rows, a slice of structs with a field for every column, as a block with meta.
+// meta may be nil, which is stored as an empty payload, and read as the zero value of meta targets.
+// empty rows append a block of meta only. its columns are nil slices in iterations, and IterRows calls no callback for it.
 func (f *File) Append(rows, meta interface{}) error {
-	f.validate()
-	// encode meta
-	metaBin, err := f.encode(meta)
+	_, err := f.AppendAt(rows, meta)
+	return err
+}
+
+// AppendAt is like Append, and returns the offset of the written block, which can be passed to ReadBlockAt
+func (f *File) AppendAt(rows, meta interface{}) (int64, error) {
+	sets, err := f.rowsSets(rows)
 	if err != nil {
-		return makeErr(err, "encode meta")
+		return 0, err
 	}
+	return f.appendSets(sets, meta)
+}
+
+// rowsSets transposes rows into filled column sets
+func (f *File) rowsSets(rows interface{}) ([]interface{}, error) {
 	// column slices
 	rowsValue := reflect.ValueOf(rows)
 	if rowsValue.Type().Kind() != reflect.Slice {
-		return makeErr(nil, "rows is not slice")
+		return nil, makeErr(nil, "rows is not slice")
+	}
+	if rowsValue.Len() > 0 && rowsValue.Type().Elem().Kind() != reflect.Struct {
+		return nil, makeErr(nil, "rows is not slice of structs")
+	}
+	if len(f.colSets) == 1 {
+		return f.singleSet(rowsValue)
 	}
 	columns := make(map[string]reflect.Value)
-	for i, l := 0, rowsValue.Len(); i < l; i++ {
-		row := rowsValue.Index(i)
-		// make colums slices
-		if i == 0 {
-			for _, set := range f.colSets {
-				for _, col := range set {
-					if _, ok := columns[col]; ok {
-						continue
-					}
-					columns[col] = reflect.MakeSlice(reflect.SliceOf(row.FieldByName(col).Type()), 0, 0)
+	l := rowsValue.Len()
+	if l > 0 { // leave columns unset for empty rows, sets get nil slices
+		// make column slices, with row fields resolved once
+		rowType := rowsValue.Type().Elem()
+		var indexes [][]int
+		var slices []reflect.Value
+		for _, set := range f.colSets {
+			for _, col := range set {
+				field, ok := rowType.FieldByName(col)
+				if !ok {
+					return nil, makeErr(nil, fmt.Sprintf("no %s field in row", col))
 				}
+				column := reflect.MakeSlice(reflect.SliceOf(field.Type), l, l)
+				columns[col] = column
+				indexes = append(indexes, field.Index)
+				slices = append(slices, column)
 			}
 		}
-		// append colum values
-		for name, col := range columns {
-			columns[name] = reflect.Append(col, row.FieldByName(name))
+		// fill column values
+		order := f.rowOrder(rowsValue)
+		for i := 0; i < l; i++ {
+			row := rowsValue.Index(i)
+			if order != nil {
+				row = rowsValue.Index(order[i])
+			}
+			for j, index := range indexes {
+				slices[j].Index(i).Set(row.FieldByIndex(index))
+			}
 		}
 	}
-	// column sets
-	var bins [][]byte
+	return f.columnsSets(columns)
+}
+
+// appendColumns encodes and writes a block. columns maps column names to slices of values.
+func (f *File) appendColumns(columns map[string]reflect.Value, meta interface{}) (int64, error) {
+	sets, err := f.columnsSets(columns)
+	if err != nil {
+		return 0, err
+	}
+	return f.appendSets(sets, meta)
+}
+
+// columnsSets returns column sets filled with columns
+func (f *File) columnsSets(columns map[string]reflect.Value) ([]interface{}, error) {
+	var sets []interface{}
 	for n, set := range f.colSets {
 		var v interface{} = f.colSetsFn(n)
 		s := reflect.ValueOf(v)
@@ -213,159 +811,325 @@ func (f *File) Append(rows, meta interface{}) error {
 		for _, col := range set {
 			field := s.FieldByName(col)
 			if !field.IsValid() {
-				return makeErr(nil, fmt.Sprintf("no %s field in colun set %d", col, n))
+				return nil, makeErr(nil, fmt.Sprintf("no %s field in colun set %d", col, n))
 			}
 			column := columns[col]
 			if column.IsValid() { // if len(rows) == 0, this would be a nil slice
+				if !column.Type().AssignableTo(field.Type()) {
+					return nil, makeErr(nil, fmt.Sprintf("column %s is %v, not %v", col, column.Type(), field.Type()))
+				}
 				field.Set(column)
 			}
 		}
-		//t0 := time.Now()
-		bin, err := f.encode(&v)
-		if err != nil {
-			return makeErr(err, "encode column set")
-		}
-		bins = append(bins, bin)
+		sets = append(sets, v)
 	}
-	// write header
-	if len(bins) > 255 {
-		return makeErr(nil, "more than 255 column sets")
+	return sets, nil
+}
+
+// appendSets encodes and writes a block, returning its offset. sets are the filled values returned by colSetsFn.
+func (f *File) appendSets(sets []interface{}, meta interface{}) (offset int64, err error) {
+	return f.appendSetsMeta(sets, func() ([]byte, error) {
+		return f.encodeMeta(meta)
+	})
+}
+
+// appendSetsMeta is appendSets with the meta payload returned by encodeMeta
+func (f *File) appendSetsMeta(sets []interface{}, encodeMeta func() ([]byte, error)) (offset int64, err error) {
+	if err := f.validate(); err != nil {
+		return 0, err
 	}
-	f.Lock()
-	defer f.Unlock()
-	err = binary.Write(f.file, binary.LittleEndian, uint8(len(bins)))
+	if f.gobStream {
+		// stream encoders are stateful, encode and write in one critical section
+		f.Lock()
+		defer f.Unlock()
+		defer f.resetStreams(&err)
+	}
+	block, err := f.encodeBlock(sets, encodeMeta)
 	if err != nil {
-		return makeErr(err, "write length length")
+		return 0, err
 	}
-	err = binary.Write(f.file, binary.LittleEndian, uint32(len(metaBin)))
+	if !f.gobStream {
+		f.Lock()
+		defer f.Unlock()
+	}
+	offsets, err := f.writeBlocks([][]byte{block})
 	if err != nil {
-		return makeErr(err, "write meta length")
+		return 0, err
 	}
-	for _, bin := range bins {
-		err = binary.Write(f.file, binary.LittleEndian, uint32(len(bin)))
+	return offsets[0], nil
+}
+
+// resetStreams restarts streams if *err is not nil, since the decoders will not see the encoded payloads
+func (f *File) resetStreams(err *error) {
+	if *err != nil {
+		for i := range f.streamEncoders {
+			f.streamEncoders[i] = nil
+		}
+	}
+}
+
+// encodeBlock encodes a block of the filled column sets and the meta payload returned by encodeMeta. in gob stream mode, the mutex must be held.
+func (f *File) encodeBlock(sets []interface{}, encodeMeta func() ([]byte, error)) ([]byte, error) {
+	// encode meta and column sets. sets are independent, even the streams, so they are encoded concurrently.
+	var metaBin []byte
+	bins := make([][]byte, len(sets))
+	errs := make([]error, len(sets)+1) // the last is of meta
+	encode := func(n int) {
+		if n == len(sets) {
+			metaBin, errs[n] = encodeMeta()
+		} else if f.gobStream {
+			bins[n], errs[n] = f.encodeStream(n, &sets[n])
+		} else {
+			bins[n], errs[n] = f.encodeSet(n, sets[n])
+		}
+	}
+	if len(sets) == 1 {
+		encode(0)
+		encode(1)
+	} else {
+		wg := new(sync.WaitGroup)
+		sem := make(chan struct{}, runtime.NumCPU())
+		for n := range errs {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(n int) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				encode(n)
+			}(n)
+		}
+		wg.Wait()
+	}
+	if errs[len(sets)] != nil {
+		return nil, makeErr(errs[len(sets)], "encode meta")
+	}
+	for _, err := range errs[:len(sets)] {
 		if err != nil {
-			return makeErr(err, "write column set length")
+			return nil, makeErr(err, "encode column set")
 		}
 	}
-	// write encoded
-	_, err = f.file.Write(metaBin)
-	if err != nil {
-		return makeErr(err, "write meta")
+	// column statistics
+	var stats []byte
+	if f.flags&_FLAG_STATS > 0 {
+		stats = marshalStats(f.columnStats(sets))
+	}
+	return f.frameBlock(metaBin, bins, stats, setsRows(sets))
+}
+
+// frameBlock returns a block of the encoded payloads, with the block header
+func (f *File) frameBlock(metaBin []byte, bins [][]byte, stats []byte, rows int) ([]byte, error) {
+	if len(bins) > math.MaxUint16 {
+		return nil, makeErr(nil, "more than 65535 column sets")
+	} else if len(bins) > math.MaxUint8 && !f.wideSets {
+		return nil, makeErr(nil, "more than 255 column sets in a file created with less sets")
+	}
+	if int64(rows) > math.MaxUint32 {
+		return nil, makeErr(nil, "too many rows in block")
 	}
+	buf := new(bytes.Buffer)
+	// header
+	if f.wideSets {
+		binary.Write(buf, binary.LittleEndian, uint16(len(bins)))
+	} else {
+		binary.Write(buf, binary.LittleEndian, uint8(len(bins)))
+	}
+	binary.Write(buf, binary.LittleEndian, uint32(len(metaBin)))
 	for _, bin := range bins {
-		_, err = f.file.Write(bin)
-		if err != nil {
-			return makeErr(err, "write column set")
+		binary.Write(buf, binary.LittleEndian, uint32(len(bin)))
+	}
+	if f.flags&_FLAG_ROWS > 0 {
+		binary.Write(buf, binary.LittleEndian, uint32(rows))
+	}
+	if f.flags&_FLAG_TIME > 0 {
+		binary.Write(buf, binary.LittleEndian, time.Now().UnixNano())
+	}
+	if f.flags&_FLAG_STATS > 0 {
+		binary.Write(buf, binary.LittleEndian, uint32(len(stats)))
+	}
+	if f.flags&_FLAG_CHECKSUM > 0 {
+		binary.Write(buf, binary.LittleEndian, blockChecksum(stats, metaBin, bins))
+	}
+	// payloads
+	buf.Write(stats)
+	buf.Write(metaBin)
+	for _, bin := range bins {
+		buf.Write(bin)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBlocks writes encoded blocks with one write, and returns their offsets. the mutex must be held.
+func (f *File) writeBlocks(blocks [][]byte) ([]int64, error) {
+	if err := f.checkWritable(); err != nil {
+		return nil, err
+	}
+	unlock, err := f.lockFile()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	offset := f.writeOffset
+	if f.buffer != nil {
+		offset += int64(f.buffer.Buffered())
+	}
+	offsets := make([]int64, len(blocks))
+	for i, block := range blocks {
+		offsets[i] = offset
+		offset += int64(len(block))
+	}
+	data := blocks[0]
+	if len(blocks) > 1 {
+		data = bytes.Join(blocks, nil)
+	}
+	_, err = f.writer().Write(data)
+	if err != nil {
+		return nil, makeErr(err, "write blocks")
+	}
+	return offsets, nil
+}
+
+func (f *File) IterMetas(fn interface{}) error {
+	return f.iterMetas(context.Background(), fn)
+}
+
+// IterMetasContext is like IterMetas, but stops when ctx is done, returning the error of ctx wrapped by *Err
+func (f *File) IterMetasContext(ctx context.Context, fn interface{}) error {
+	return f.iterMetas(ctx, fn)
+}
+
+// ReadAllMetas appends metas of all blocks to the slice pointed by metaSlicePtr, which is like *[]string
+func (f *File) ReadAllMetas(metaSlicePtr interface{}) error {
+	ptr := reflect.ValueOf(metaSlicePtr)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() || ptr.Elem().Kind() != reflect.Slice {
+		return makeErr(nil, fmt.Sprintf("expecting pointer to slice, got %T", metaSlicePtr))
+	}
+	slice := ptr.Elem()
+	elemType := slice.Type().Elem()
+	fn := reflect.MakeFunc(
+		reflect.FuncOf([]reflect.Type{elemType}, []reflect.Type{reflect.TypeOf(true)}, false),
+		func(args []reflect.Value) []reflect.Value {
+			slice.Set(reflect.Append(slice, args[0]))
+			return []reflect.Value{reflect.ValueOf(true)}
+		},
+	)
+	if err := f.iterMetas(context.Background(), fn.Interface()); err != nil {
+		if errors.Is(err, ErrDecode) {
+			return makeErr(err, fmt.Sprintf("meta does not match type %v", elemType))
 		}
+		return err
 	}
 	return nil
 }
 
-func (f *File) IterMetas(fn interface{}) error {
-	f.Sync()
-	file, err := os.Open(f.path)
+func (f *File) iterMetas(ctx context.Context, fn interface{}) error {
+	cursor, err := f.Cursor()
 	if err != nil {
-		return makeErr(err, "open file")
+		return err
 	}
-	defer file.Close()
+	defer cursor.Close()
 
 	fnValue := reflect.ValueOf(fn)
 	fnType := fnValue.Type()
 	metaType := fnType.In(0)
 
-	line := pipeline.NewPipeline()
-	p1 := line.NewPipe(100000)
-	p2 := line.NewPipe(2048)
-
-	go func() {
-		for {
-			meta := reflect.New(metaType)
+	it := f.newIteration(ctx)
 
-			// read number of sets
-			var numSets uint8
-			err = binary.Read(file, binary.LittleEndian, &numSets)
+	return it.run(func() {
+		for !it.stopped() {
+			it.read(cursor.Tell())
+			_, err := cursor.NextHeader()
 			if err == io.EOF { // no more
-				break
-			}
-			if err != nil {
-				line.Error(makeErr(err, "read number of column sets"))
+				it.end(cursor.Tell())
 				return
 			}
-
-			// read meta length
-			var metaLength uint32
-			err = binary.Read(file, binary.LittleEndian, &metaLength)
 			if err != nil {
-				line.Error(makeErr(err, "read meta length"))
+				it.abort(err)
 				return
 			}
 
-			// read sets length
-			var sum, l uint32
-			for i, max := 0, int(numSets); i < max; i++ {
-				err = binary.Read(file, binary.LittleEndian, &l)
-				if err != nil {
-					line.Error(makeErr(err, "read column set length"))
-					return
-				}
-				sum += l
-			}
-
 			// read meta
-			bs := make([]byte, metaLength)
-			_, err = io.ReadFull(file, bs)
+			bs, _, err := cursor.readParts(true, nil)
 			if err != nil {
-				line.Error(makeErr(err, "read meta"))
+				it.abort(err)
 				return
 			}
-			line.Add()
 
-			if !p1.Do(func() {
+			meta := reflect.New(metaType)
+			if !it.block(func() bool {
 				// decode meta
-				err = f.decode(bs, meta.Interface())
+				err := f.decodeMeta(bs, meta.Interface())
 				if err != nil {
-					line.Error(makeErr(err, "decode meta"))
-					return
+					it.abort(makeKindErr(KindDecode, err, "decode meta"))
+					return false
 				}
+				return true
+			}, nil, func() {
 				// callback
-				if !p2.Do(func() {
-					if !fnValue.Call([]reflect.Value{meta.Elem()})[0].Bool() {
-						line.Close()
-						return
-					}
-					line.Done()
-				}) {
-					return
+				if !fnValue.Call([]reflect.Value{meta.Elem()})[0].Bool() {
+					it.abort(nil)
 				}
-			}) {
+			}, false) {
 				return
 			}
+		}
+	})
+}
 
-			// skip sets
-			_, err = file.Seek(int64(sum), os.SEEK_CUR)
-			if err != nil {
-				line.Error(makeErr(err, "skip column sets"))
-				return
-			}
+func (f *File) Iter(cols []string, cb func(columns ...interface{}) bool) error {
+	return f.iter(context.Background(), cols, nil, cb)
+}
 
-		}
-		line.Wait()
-		line.Close()
-	}()
+// IterContext is like Iter, but stops when ctx is done, returning the error of ctx wrapped by *Err
+func (f *File) IterContext(ctx context.Context, cols []string, cb func(columns ...interface{}) bool) error {
+	return f.iter(ctx, cols, nil, cb)
+}
 
-	go p1.ParallelProcess(runtime.NumCPU())
-	p2.Process()
+// IterDeadline is like Iter, but stops when ctx is done. if the deadline of ctx is exceeded, ErrDeadlineReached is returned, and blocks delivered to cb before that are complete and valid.
+func (f *File) IterDeadline(ctx context.Context, cols []string, cb func(columns ...interface{}) bool) error {
+	err := f.iter(ctx, cols, nil, cb)
+	if e, ok := err.(*Err); ok && e.Err == context.DeadlineExceeded {
+		return ErrDeadlineReached
+	}
+	return err
+}
 
-	return line.Err
+// IterIndexed is like Iter, and also passes cb the index of the block in the file, which can be passed to ReadBlock.
+// blocks are passed in file order even if decoded concurrently.
+func (f *File) IterIndexed(cols []string, cb func(blockIndex int, columns ...interface{}) bool) error {
+	return f.IterIndexedContext(context.Background(), cols, cb)
 }
 
-func (f *File) Iter(cols []string, cb func(columns ...interface{}) bool) error {
-	f.Sync()
-	file, err := os.Open(f.path)
+// IterIndexedContext is like IterIndexed, but stops when ctx is done, returning the error of ctx wrapped by *Err
+func (f *File) IterIndexedContext(ctx context.Context, cols []string, cb func(blockIndex int, columns ...interface{}) bool) error {
+	n := 0
+	return f.iter(ctx, cols, nil, func(columns ...interface{}) bool {
+		n++
+		return cb(n-1, columns...)
+	})
+}
+
+// iter implements Iter. if keep is not nil, blocks rejected by keep are skipped without reading column sets.
+func (f *File) iter(ctx context.Context, cols []string, keep func(header *BlockHeader) bool, cb func(columns ...interface{}) bool) error {
+	if !f.allowUnknownColumns {
+		if _, unknown := f.iterOrder(cols); len(unknown) > 0 {
+			return makeErr(nil, fmt.Sprintf("no such column: %s", strings.Join(unknown, ", ")))
+		}
+	}
+	return f.iterBlocks(ctx, cols, nil, keep, false, func(_ *BlockHeader, _ []byte, columns []interface{}) bool {
+		return cb(columns...)
+	})
+}
+
+// iterBlocks implements iter. cb also receives the header, and the encoded meta if withMeta is true.
+// if offsets is not nil, blocks at offsets are read in that order, instead of all blocks in file order.
+func (f *File) iterBlocks(ctx context.Context, cols []string, offsets []int64, keep func(header *BlockHeader) bool, withMeta bool, cb func(header *BlockHeader, meta []byte, columns []interface{}) bool) error {
+	cursor, err := f.Cursor()
 	if err != nil {
-		return makeErr(err, "open file")
+		return err
 	}
-	defer file.Close()
+	defer cursor.Close()
 
 	// determine which set to decode and which column to collect
 	toCollect := make([][]bool, 0)
@@ -393,121 +1157,195 @@ func (f *File) Iter(cols []string, cb func(columns ...interface{}) bool) error {
 		}
 	}
 
-	line := pipeline.NewPipeline()
-	p1 := line.NewPipe(30000)
-	p2 := line.NewPipe(2048)
+	// fast path for schemas of one column set, fields are collected by indexes resolved once
+	var singleFields []int
+	if len(f.colSets) == 1 {
+		for i, b := range toCollect[0] {
+			if b {
+				singleFields = append(singleFields, i)
+			}
+		}
+	}
+
+	// fast path for full scans, every field of every set is collected, so the bitmaps are not consulted
+	allColumns := true
+	numColumns := 0
+	for n, c := range toCollect {
+		if !toDecode[n] {
+			allColumns = false
+		}
+		for _, b := range c {
+			if !b {
+				allColumns = false
+			}
+		}
+		numColumns += len(c)
+	}
+
+	var decoders []*gobStreamDecoder
+	if f.gobStream {
+		decoders = make([]*gobStreamDecoder, len(f.colSets))
+	}
+
+	it := f.newIteration(ctx)
 
 	// read bytes
-	go func() {
-		for {
-			// read number of sets
-			var numSets uint8
-			err := binary.Read(file, binary.LittleEndian, &numSets)
-			if err == io.EOF { // no more
-				break
+	return it.run(func() {
+		for !it.stopped() {
+			it.read(cursor.Tell())
+			if offsets != nil {
+				if len(offsets) == 0 {
+					it.end(cursor.Tell())
+					return
+				}
+				if _, err := cursor.Seek(offsets[0], os.SEEK_SET); err != nil {
+					it.abort(err)
+					return
+				}
+				offsets = offsets[1:]
 			}
-			if err != nil {
-				line.Error(makeErr(err, "read number of column sets"))
+			header, err := cursor.NextHeader()
+			if err == io.EOF && offsets != nil {
+				err = makeErr(io.ErrUnexpectedEOF, "read block header")
+			}
+			if err == io.EOF { // no more
+				it.end(cursor.Tell())
 				return
 			}
-			// read meta length
-			var metaLength uint32
-			err = binary.Read(file, binary.LittleEndian, &metaLength)
 			if err != nil {
-				line.Error(makeErr(err, "read meta length"))
+				it.abort(err)
 				return
 			}
-			// read sets length
-			var lens []uint32
-			var l uint32
-			for i, max := 0, int(numSets); i < max; i++ {
-				err = binary.Read(file, binary.LittleEndian, &l)
-				if err != nil {
-					line.Error(makeErr(err, "read column set length"))
-					return
-				}
-				lens = append(lens, l)
+			skip := keep != nil && !keep(header)
+			if skip && !f.gobStream {
+				continue
 			}
-			// skip meta
-			_, err = file.Seek(int64(metaLength), os.SEEK_CUR)
+			// read bytes
+			metaBytes, bss, err := cursor.readParts(withMeta, toDecode)
 			if err != nil {
-				line.Error(makeErr(err, "skip meta"))
+				it.abort(err)
 				return
 			}
-			// read bytes
-			var bss [][]byte
-			for n, l := range lens {
-				if toDecode[n] { // decode
-					bs := make([]byte, l)
-					_, err = io.ReadFull(file, bs)
+
+			var columns []interface{}
+			if !it.block(nil, func() bool {
+				if len(singleFields) > 0 && len(bss) == 1 {
+					s := f.colSetsFn(0)
+					err := f.decodeSet(decoders, 0, bss[0], &s)
 					if err != nil {
-						line.Error(makeErr(err, "read column set"))
-						return
+						it.abort(makeKindErr(KindDecode, err, "decode column set"))
+						return false
 					}
-					bss = append(bss, bs)
-				} else { // skip
-					_, err = file.Seek(int64(l), os.SEEK_CUR)
-					if err != nil {
-						line.Error(makeErr(err, "skip column set"))
-						return
+					sValue := reflect.ValueOf(s).Elem()
+					columns = make([]interface{}, len(singleFields))
+					for i, n := range singleFields {
+						columns[i] = sValue.Field(n).Interface()
 					}
-					bss = append(bss, nil)
+					return true
 				}
-			}
-
-			line.Add()
-			if !p1.Do(func() {
-				var columns []interface{}
-				for n, bs := range bss {
-					if bs == nil {
-						continue
+				if allColumns {
+					columns = make([]interface{}, 0, numColumns)
+					for n := range f.colSets {
+						var sValue reflect.Value
+						if n >= len(bss) { // set added to the schema after the block was appended
+							sValue = f.emptySet(n)
+						} else {
+							s := f.colSetsFn(n)
+							err := f.decodeSet(decoders, n, bss[n], &s)
+							if err != nil {
+								it.abort(makeKindErr(KindDecode, err, "decode column set"))
+								return false
+							}
+							sValue = reflect.ValueOf(s).Elem()
+						}
+						for i, l := 0, sValue.NumField(); i < l; i++ {
+							columns = append(columns, sValue.Field(i).Interface())
+						}
 					}
-					s := f.colSetsFn(n)
-					err := f.decode(bs, &s)
-					if err != nil {
-						line.Error(makeErr(err, "decode column set"))
-						return
+					return true
+				}
+				for n := range toCollect {
+					var sValue reflect.Value
+					if n >= len(bss) { // set added to the schema after the block was appended
+						if !toDecode[n] {
+							continue
+						}
+						sValue = f.emptySet(n)
+					} else {
+						if bss[n] == nil {
+							continue
+						}
+						s := f.colSetsFn(n)
+						err := f.decodeSet(decoders, n, bss[n], &s)
+						if err != nil {
+							it.abort(makeKindErr(KindDecode, err, "decode column set"))
+							return false
+						}
+						sValue = reflect.ValueOf(s).Elem()
 					}
-					sValue := reflect.ValueOf(s).Elem()
 					for nfield, b := range toCollect[n] {
 						if b {
 							columns = append(columns, sValue.Field(nfield).Interface())
 						}
 					}
 				}
-
-				if !p2.Do(func() {
-					if !cb(columns...) {
-						line.Close()
-						return
-					}
-					line.Done()
-				}) {
+				return true
+			}, func() {
+				if skip { // decoded to keep the gob streams in sync
 					return
 				}
-
-			}) {
+				if !cb(header, metaBytes, columns) {
+					it.abort(nil)
+				}
+			}, f.gobStream) { // stream payloads must be decoded in file order
 				return
 			}
 
 		}
-		line.Wait()
-		line.Close()
-	}()
+	})
+}
 
-	go p1.ParallelProcess(runtime.NumCPU())
-	p2.Process()
+func (f *File) IterAll(metaTarget interface{}, columnsTarget interface{}, cb func() bool) error {
+	return f.iterAll(context.Background(), metaTarget, columnsTarget, nil, cb)
+}
 
-	return line.Err
+// IterAllContext is like IterAll, but stops when ctx is done, returning the error of ctx wrapped by *Err
+func (f *File) IterAllContext(ctx context.Context, metaTarget interface{}, columnsTarget interface{}, cb func() bool) error {
+	return f.iterAll(ctx, metaTarget, columnsTarget, nil, cb)
 }
 
-func (f *File) IterAll(metaTarget interface{}, columnsTarget interface{}, cb func() bool) error {
-	f.Sync()
-	file, err := os.Open(f.path)
+// IterDistinctMeta is like IterAll, but skips blocks whose meta key returned by keyFn was already seen in a previous block.
+// metas are decoded and checked in file order, column sets of skipped blocks are not read. keys must be comparable.
+func (f *File) IterDistinctMeta(keyFn func(meta interface{}) interface{}, metaTarget interface{}, columnsTarget interface{}, cb func() bool) error {
+	seen := make(map[interface{}]struct{})
+	return f.iterAll(context.Background(), metaTarget, columnsTarget, func(meta interface{}) bool {
+		key := keyFn(meta)
+		if _, ok := seen[key]; ok {
+			return false
+		}
+		seen[key] = struct{}{}
+		return true
+	}, cb)
+}
+
+// IterAllFilter is like IterAll, but calls accept after decoding the meta of each block into metaTarget, and skips blocks rejected by accept without decoding their column sets.
+// metaTarget may be assigned by accept calls of following blocks when cb is not running, so cb should not keep references to it.
+func (f *File) IterAllFilter(metaTarget interface{}, columnsTarget interface{}, accept func() bool, cb func() bool) error {
+	metaTargetValue := reflect.ValueOf(metaTarget).Elem()
+	return f.iterAll(context.Background(), metaTarget, columnsTarget, func(meta interface{}) bool {
+		metaTargetValue.Set(reflect.ValueOf(meta))
+		return accept()
+	}, cb)
+}
+
+// iterAll implements IterAll. if keep is not nil, metas are decoded in the reading goroutine and blocks rejected by keep are skipped without reading column sets.
+// keep calls and deliveries of blocks are serialized, so keep may use the targets.
+func (f *File) iterAll(ctx context.Context, metaTarget interface{}, columnsTarget interface{}, keep func(meta interface{}) bool, cb func() bool) error {
+	cursor, err := f.Cursor()
 	if err != nil {
-		return makeErr(err, "open file")
+		return err
 	}
-	defer file.Close()
+	defer cursor.Close()
 
 	columnsToCollect := make(map[string]bool)
 	t := reflect.TypeOf(columnsTarget).Elem()
@@ -515,111 +1353,149 @@ func (f *File) IterAll(metaTarget interface{}, columnsTarget interface{}, cb fun
 		columnsToCollect[t.Field(i).Name] = true
 	}
 
-	toDecode := make([]bool, len(f.colSets))
-	for i, set := range f.colSets {
-		for _, col := range set {
-			if columnsToCollect[col] {
-				toDecode[i] = true
+	toDecode := f.setsToDecode(columnsToCollect)
+
+	// fast path for schemas of one column set, fields are assigned by indexes resolved once
+	var singleSetFields []int
+	var singleTargetFields [][]int // indexes of target fields, which may be promoted from embedded structs
+	if len(f.colSets) == 1 {
+		for i, col := range f.colSets[0] {
+			if field, ok := t.FieldByName(col); ok {
+				singleSetFields = append(singleSetFields, i)
+				singleTargetFields = append(singleTargetFields, field.Index)
 			}
 		}
 	}
 
-	line := pipeline.NewPipeline()
-	p1 := line.NewPipe(30000)
-	p2 := line.NewPipe(2048)
+	var decoders []*gobStreamDecoder
+	if f.gobStream {
+		decoders = make([]*gobStreamDecoder, len(f.colSets))
+	}
 
 	columnsTargetValue := reflect.ValueOf(columnsTarget).Elem()
+	metaType := reflect.TypeOf(metaTarget).Elem()
+	var targetLock sync.Mutex
 
-	go func() {
-		for {
+	it := f.newIteration(ctx)
 
-			// read number of sets
-			var numSets uint8
-			err := binary.Read(file, binary.LittleEndian, &numSets)
+	return it.run(func() {
+		for !it.stopped() {
+			it.read(cursor.Tell())
+
+			_, err := cursor.NextHeader()
 			if err == io.EOF { // no more
-				break
+				it.end(cursor.Tell())
+				return
 			}
 			if err != nil {
-				line.Error(makeErr(err, "read number of column sets"))
+				it.abort(err)
 				return
 			}
 
-			// read meta length
-			var metaLength uint32
-			err = binary.Read(file, binary.LittleEndian, &metaLength)
+			// read meta
+			metaBytes, _, err := cursor.readParts(true, nil)
 			if err != nil {
-				line.Error(makeErr(err, "read meta length"))
+				it.abort(err)
 				return
 			}
 
-			// read sets length
-			var lens []uint32
-			var l uint32
-			for i, max := 0, int(numSets); i < max; i++ {
-				err = binary.Read(file, binary.LittleEndian, &l)
+			// filter by meta
+			var meta reflect.Value
+			skip := false
+			if keep != nil {
+				meta = reflect.New(metaType)
+				err = f.decodeMeta(metaBytes, meta.Interface())
 				if err != nil {
-					line.Error(makeErr(err, "read column set length"))
+					it.abort(makeKindErr(KindDecode, err, "decode meta"))
 					return
 				}
-				lens = append(lens, l)
+				targetLock.Lock()
+				skip = !keep(meta.Elem().Interface())
+				targetLock.Unlock()
+			}
+			if skip && !f.gobStream {
+				continue
 			}
 
-			// read meta
-			metaBytes := make([]byte, metaLength)
-			_, err = io.ReadFull(file, metaBytes)
+			// read bytes
+			_, columnBytesSlice, err := cursor.readParts(false, toDecode)
 			if err != nil {
-				line.Error(makeErr(err, "read meta"))
+				it.abort(err)
 				return
 			}
 
-			// read bytes
-			var columnBytesSlice [][]byte
-			for n, l := range lens {
-				if len(lens) != len(toDecode) {
-					panic(fmt.Sprintf("lens %d toDecode %d numSets %d\n", len(lens), len(toDecode), numSets))
-				}
-				if toDecode[n] { // decode
-					bs := make([]byte, l)
-					_, err = io.ReadFull(file, bs)
-					if err != nil {
-						line.Error(makeErr(err, "read column set"))
-						return
+			if skip {
+				// decode payloads of skipped block to keep the gob streams in sync
+				for n, bs := range columnBytesSlice {
+					if bs == nil {
+						continue
 					}
-					columnBytesSlice = append(columnBytesSlice, bs)
-				} else { // skip
-					_, err = file.Seek(int64(l), os.SEEK_CUR)
+					columnSet := f.colSetsFn(n)
+					err = f.decodeSet(decoders, n, bs, &columnSet)
 					if err != nil {
-						line.Error(makeErr(err, "skip column set"))
+						it.abort(makeKindErr(KindDecode, err, "decode column set"))
 						return
 					}
-					columnBytesSlice = append(columnBytesSlice, nil)
 				}
+				continue
 			}
 
-			line.Add()
-			if !p1.Do(func() {
-				// decode meta
-				meta := reflect.New(reflect.TypeOf(metaTarget).Elem())
-				err = f.decode(metaBytes, meta.Interface())
-				if err != nil {
-					line.Error(makeErr(err, "decode meta"))
-					return
+			// decode meta
+			var decodeMeta func() bool
+			if !meta.IsValid() {
+				meta = reflect.New(metaType)
+				decodeMeta = func() bool {
+					err := f.decodeMeta(metaBytes, meta.Interface())
+					if err != nil {
+						it.abort(makeKindErr(KindDecode, err, "decode meta"))
+						return false
+					}
+					return true
 				}
+			}
 
-				// decode columns
-				toSet := make(map[string]reflect.Value)
-				for n, bs := range columnBytesSlice {
-					if bs == nil {
-						continue
+			// decode columns
+			var single reflect.Value
+			toSet := make(map[string]reflect.Value)
+			decodeColumns := func() bool {
+				if len(f.colSets) == 1 {
+					if len(columnBytesSlice) == 0 { // set added to the schema after the block was appended
+						if toDecode[0] {
+							single = f.emptySet(0)
+						}
+						return true
 					}
-					columnSet := f.colSetsFn(n)
-					err := f.decode(bs, &columnSet)
-					if err != nil {
-						line.Error(makeErr(err, "decode column set"))
-						return
+					if columnBytesSlice[0] != nil {
+						columnSet := f.colSetsFn(0)
+						err := f.decodeSet(decoders, 0, columnBytesSlice[0], &columnSet)
+						if err != nil {
+							it.abort(makeKindErr(KindDecode, err, "decode column set"))
+							return false
+						}
+						single = reflect.ValueOf(columnSet).Elem()
 					}
-					columnSetType := reflect.TypeOf(columnSet).Elem()
-					columnSetValue := reflect.ValueOf(columnSet).Elem()
+					return true
+				}
+				for n := range f.colSets {
+					var columnSetValue reflect.Value
+					if n >= len(columnBytesSlice) { // set added to the schema after the block was appended
+						if !toDecode[n] {
+							continue
+						}
+						columnSetValue = f.emptySet(n)
+					} else {
+						if columnBytesSlice[n] == nil {
+							continue
+						}
+						columnSet := f.colSetsFn(n)
+						err := f.decodeSet(decoders, n, columnBytesSlice[n], &columnSet)
+						if err != nil {
+							it.abort(makeKindErr(KindDecode, err, "decode column set"))
+							return false
+						}
+						columnSetValue = reflect.ValueOf(columnSet).Elem()
+					}
+					columnSetType := columnSetValue.Type()
 					for i, l := 0, columnSetType.NumField(); i < l; i++ {
 						name := columnSetType.Field(i).Name
 						if columnsToCollect[name] {
@@ -627,35 +1503,30 @@ func (f *File) IterAll(metaTarget interface{}, columnsTarget interface{}, cb fun
 						}
 					}
 				}
+				return true
+			}
 
-				if !p2.Do(func() {
-					// assign
-					reflect.ValueOf(metaTarget).Elem().Set(meta.Elem())
-					for name, value := range toSet {
-						columnsTargetValue.FieldByName(name).Set(value)
-					}
-					// callback
-					if !cb() {
-						line.Close()
-						return
+			if !it.block(decodeMeta, decodeColumns, func() {
+				targetLock.Lock()
+				defer targetLock.Unlock()
+				// assign
+				reflect.ValueOf(metaTarget).Elem().Set(meta.Elem())
+				if single.IsValid() {
+					for i, n := range singleSetFields {
+						columnsTargetValue.FieldByIndex(singleTargetFields[i]).Set(single.Field(n))
 					}
-					//pt("%d %d\n", p1.Len(), p2.Len())
-					line.Done()
-				}) {
-					return
 				}
-
-			}) {
+				for name, value := range toSet {
+					columnsTargetValue.FieldByName(name).Set(value)
+				}
+				// callback
+				if !cb() {
+					it.abort(nil)
+				}
+			}, f.gobStream) { // stream payloads must be decoded in file order
 				return
 			}
 
 		}
-		line.Wait()
-		line.Close()
-	}()
-
-	go p1.ParallelProcess(runtime.NumCPU())
-	p2.Process()
-
-	return line.Err
+	})
 }
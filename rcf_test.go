@@ -1,12 +1,18 @@
 package rcf
 
 import (
+	"context"
 	crand "crypto/rand"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -19,6 +25,14 @@ func TestMain(m *testing.M) {
 }
 
 func TestBasics(t *testing.T) {
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	testBasics(t, func(colSetsFn func(int) interface{}) (*File, error) {
+		return New(path, colSetsFn)
+	})
+}
+
+// testBasics runs the basic tests on files returned by open, which opens the same file on each call
+func testBasics(t *testing.T, open func(colSetsFn func(int) interface{}) (*File, error)) {
 	type Foo struct {
 		Foo  int
 		Bar  string
@@ -27,12 +41,11 @@ func TestBasics(t *testing.T) {
 		Quux map[string]string
 	}
 
-	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
 	var f *File
 	var err error
 
-	open := func() {
-		f, err = New(path, func(i int) (ret interface{}) {
+	reopen := func() {
+		f, err = open(func(i int) (ret interface{}) {
 			switch i {
 			case 0:
 				ret = &struct {
@@ -56,7 +69,7 @@ func TestBasics(t *testing.T) {
 			t.Fatalf("new: %v", err)
 		}
 	}
-	open()
+	reopen()
 
 	foos := []Foo{
 		{1, "A", true, []int{1, 2, 3}, map[string]string{"A": "a"}},
@@ -95,6 +108,24 @@ func TestBasics(t *testing.T) {
 		}
 	})
 
+	t.Run("read all metas", func(t *testing.T) {
+		var all []string
+		if err := f.ReadAllMetas(&all); err != nil {
+			t.Fatalf("read all metas: %v", err)
+		}
+		if !reflect.DeepEqual(all, metas) {
+			t.Fatalf("got %v", all)
+		}
+		var ints []int
+		if err := f.ReadAllMetas(&ints); err == nil {
+			t.Fatal("expecting error")
+		}
+		var s string
+		if err := f.ReadAllMetas(&s); err == nil {
+			t.Fatal("expecting error")
+		}
+	})
+
 	t.Run("iter rows", func(t *testing.T) {
 		n := 0
 		err = f.Iter([]string{"Foo", "Baz"}, func(cols ...interface{}) bool {
@@ -115,7 +146,7 @@ func TestBasics(t *testing.T) {
 
 	t.Run("reopen", func(t *testing.T) {
 		f.Close()
-		open() // reopen
+		reopen()
 		n := 0
 		err = f.Iter([]string{"Foo"}, func(cols ...interface{}) bool {
 			foos := cols[0].([]int)
@@ -230,6 +261,77 @@ func TestMeta(t *testing.T) {
 	}
 }
 
+func TestNilMeta(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	for _, ext := range []string{"", ".snappy", ".zstd"} {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d%s", rand.Int63(), ext))
+		f, err := New(path, func(i int) (ret interface{}) {
+			switch i {
+			case 0:
+				ret = &struct {
+					Foo []int
+				}{}
+			}
+			return
+		})
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		defer f.Close()
+		if err := f.Append([]Foo{{1}}, nil); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		if err := f.Append([]Foo{{2}}, 42); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		cursor, err := f.Cursor()
+		if err != nil {
+			t.Fatalf("cursor: %v", err)
+		}
+		header, err := cursor.NextHeader()
+		cursor.Close()
+		if err != nil {
+			t.Fatalf("next header: %v", err)
+		}
+		if header.MetaLength != 0 {
+			t.Fatalf("%s: got meta length %d", ext, header.MetaLength)
+		}
+
+		var metas []int
+		err = f.IterMetas(func(meta int) bool {
+			metas = append(metas, meta)
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter metas: %v", err)
+		}
+		if len(metas) != 2 || metas[0] != 0 || metas[1] != 42 {
+			t.Fatalf("%s: got %v", ext, metas)
+		}
+
+		meta := -1
+		var columns struct {
+			Foo []int
+		}
+		n := 0
+		err = f.IterAll(&meta, &columns, func() bool {
+			if n == 0 && (meta != 0 || columns.Foo[0] != 1) || n == 1 && (meta != 42 || columns.Foo[0] != 2) {
+				t.Fatalf("%s: got %d %v", ext, meta, columns.Foo)
+			}
+			n++
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter all: %v", err)
+		}
+		if n != 2 {
+			t.Fatalf("got %d blocks", n)
+		}
+	}
+}
+
 func TestIterAll(t *testing.T) {
 	type Foo struct {
 		Foo  int
@@ -305,3 +407,751 @@ func TestIterAll(t *testing.T) {
 		t.Fatalf("iter all error %v", err)
 	}
 }
+
+func TestIterAllSingleSet(t *testing.T) {
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	// no column set
+	f, err := New(path, func(i int) interface{} {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if err := f.Append([]struct{}{}, 1); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	// a set added
+	f, err = New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+				Bar []string
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	if err := f.Append([]Foo{{2, "2"}}, 2); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	// promoted fields
+	type Embedded struct {
+		Foo []int
+	}
+	var meta int
+	var columns struct {
+		Embedded
+		Bar []string
+	}
+	columns.Foo = []int{42}
+	columns.Bar = []string{"42"}
+	n := 0
+	err = f.IterAll(&meta, &columns, func() bool {
+		n++
+		if meta != n {
+			t.Fatalf("got meta %d", meta)
+		}
+		switch n {
+		case 1: // no payload of the set
+			if len(columns.Foo) != 0 || len(columns.Bar) != 0 {
+				t.Fatalf("got %v %v", columns.Foo, columns.Bar)
+			}
+		case 2:
+			if !reflect.DeepEqual(columns.Foo, []int{2}) || !reflect.DeepEqual(columns.Bar, []string{"2"}) {
+				t.Fatalf("got %v %v", columns.Foo, columns.Bar)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter all: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("got %d blocks", n)
+	}
+}
+
+func TestIterFullScan(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+		Baz bool
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+				Bar []string
+			}{}
+		case 1:
+			ret = &struct {
+				Baz []bool
+			}{}
+		}
+		return
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := f.Append([]Foo{{i, "bar", true}, {i * 2, "baz", false}}, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	f.Close()
+
+	// with a set added after the blocks
+	f, err = New(path, func(i int) (ret interface{}) {
+		if i == 2 {
+			return &struct {
+				Qux []int
+			}{}
+		}
+		return colSetsFn(i)
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	iter := func(cols []string) (ret [][]interface{}) {
+		err := f.Iter(cols, func(columns ...interface{}) bool {
+			ret = append(ret, columns)
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter: %v", err)
+		}
+		return
+	}
+	all := iter([]string{"Foo", "Bar", "Baz", "Qux"})
+	if len(all) != 5 {
+		t.Fatalf("got %d blocks", len(all))
+	}
+	for i, set := range [][]string{{"Foo", "Bar"}, {"Baz"}, {"Qux"}} {
+		for n, columns := range iter(set) {
+			offset := []int{0, 2, 3}[i]
+			if !reflect.DeepEqual(all[n][offset:offset+len(set)], columns) {
+				t.Fatalf("got %v, expecting %v", all[n][offset:offset+len(set)], columns)
+			}
+		}
+	}
+	if foos := all[3][0].([]int); !reflect.DeepEqual(foos, []int{3, 6}) {
+		t.Fatalf("got %v", foos)
+	}
+	if quxs := all[3][3].([]int); len(quxs) != 0 {
+		t.Fatalf("got %v", quxs)
+	}
+}
+
+func TestIterDistinctMeta(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	for i := 0; i < 10; i++ {
+		err = f.Append([]Foo{{i}}, i%3)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	var meta int
+	var columns struct {
+		Foo []int
+	}
+	seen := make(map[int]int)
+	err = f.IterDistinctMeta(func(meta interface{}) interface{} {
+		return meta.(int)
+	}, &meta, &columns, func() bool {
+		seen[meta] = columns.Foo[0]
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter distinct meta: %v", err)
+	}
+	if len(seen) != 3 {
+		t.Fatalf("expected 3 distinct blocks, got %d", len(seen))
+	}
+	for meta, foo := range seen {
+		if foo != meta {
+			t.Fatalf("not the first block of meta %d: %d", meta, foo)
+		}
+	}
+}
+
+func TestIterDeadline(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	for i := 0; i < 100; i++ {
+		err = f.Append([]Foo{{i}}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	t.Run("partial", func(t *testing.T) {
+		numGoroutine := runtime.NumGoroutine()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		n := 0
+		err = f.IterDeadline(ctx, []string{"Foo"}, func(cols ...interface{}) bool {
+			if cols[0].([]int)[0] != n {
+				t.Fatalf("foo value not match")
+			}
+			n++
+			time.Sleep(time.Millisecond * 5)
+			return true
+		})
+		if err != ErrDeadlineReached {
+			t.Fatalf("expected ErrDeadlineReached, got %v", err)
+		}
+		if n == 0 || n == 100 {
+			t.Fatalf("got %d blocks", n)
+		}
+		cancel()
+		time.Sleep(time.Millisecond * 10)
+		if runtime.NumGoroutine() > numGoroutine {
+			t.Fatalf("goroutine leaked")
+		}
+	})
+
+	t.Run("complete", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		n := 0
+		err = f.IterDeadline(ctx, []string{"Foo"}, func(cols ...interface{}) bool {
+			n++
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter deadline: %v", err)
+		}
+		if n != 100 {
+			t.Fatalf("got %d blocks", n)
+		}
+	})
+}
+
+func TestIterContext(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	for i := 0; i < 1000; i++ {
+		err = f.Append([]Foo{{i}}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	check := func(t *testing.T, iter func(ctx context.Context, cb func()) error) {
+		numGoroutine := runtime.NumGoroutine()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		n := 0
+		t0 := time.Now()
+		err := iter(ctx, func() {
+			n++
+			cancel()
+		})
+		if e, ok := err.(*Err); !ok || e.Err != context.Canceled {
+			t.Fatalf("expected canceled, got %v", err)
+		}
+		if time.Since(t0) > time.Second {
+			t.Fatalf("not returned timely")
+		}
+		if n != 1 {
+			t.Fatalf("got %d blocks after cancel", n)
+		}
+		time.Sleep(time.Millisecond * 10)
+		if runtime.NumGoroutine() > numGoroutine {
+			t.Fatalf("goroutine leaked")
+		}
+	}
+
+	t.Run("iter", func(t *testing.T) {
+		check(t, func(ctx context.Context, cb func()) error {
+			return f.IterContext(ctx, []string{"Foo"}, func(cols ...interface{}) bool {
+				cb()
+				return true
+			})
+		})
+	})
+
+	t.Run("iter all", func(t *testing.T) {
+		check(t, func(ctx context.Context, cb func()) error {
+			var meta int
+			var columns struct {
+				Foo []int
+			}
+			return f.IterAllContext(ctx, &meta, &columns, func() bool {
+				cb()
+				return true
+			})
+		})
+	})
+
+	t.Run("iter metas", func(t *testing.T) {
+		check(t, func(ctx context.Context, cb func()) error {
+			return f.IterMetasContext(ctx, func(meta int) bool {
+				cb()
+				return true
+			})
+		})
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := f.IterMetasContext(ctx, func(meta int) bool {
+			t.Fatalf("should not be called")
+			return true
+		})
+		if e, ok := err.(*Err); !ok || e.Err != context.Canceled {
+			t.Fatalf("expected canceled, got %v", err)
+		}
+	})
+}
+
+func TestWriteBuffer(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := NewWithOptions(path, colSetsFn, Options{
+		WriteBufferSize: 1 << 20,
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	var offsets []int64
+	for i := 0; i < 100; i++ {
+		offset, err := f.AppendAt([]Foo{{i}}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		offsets = append(offsets, offset)
+	}
+
+	count := func() int {
+		reader, err := New(path, colSetsFn)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		defer reader.Close()
+		n, err := reader.Count()
+		if err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		return n
+	}
+	if n := count(); n != 0 {
+		t.Fatalf("got %d blocks before flush", n)
+	}
+	if err := f.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if n := count(); n != 100 {
+		t.Fatalf("got %d blocks after flush", n)
+	}
+
+	for i := 100; i < 200; i++ {
+		offset, err := f.AppendAt([]Foo{{i}}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		offsets = append(offsets, offset)
+	}
+	// iterations flush
+	n := 0
+	err = f.IterMetas(func(meta int) bool {
+		if meta != n {
+			t.Fatalf("got %d", meta)
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter metas: %v", err)
+	}
+	if n != 200 {
+		t.Fatalf("got %d blocks", n)
+	}
+	for i, offset := range offsets {
+		var meta int
+		var columns struct {
+			Foo []int
+		}
+		err := f.ReadBlockAt(offset, &meta, &columns)
+		if err != nil {
+			t.Fatalf("read block at: %v", err)
+		}
+		if meta != i || columns.Foo[0] != i {
+			t.Fatalf("wrong block %d", i)
+		}
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := NewWithOptions(path, colSetsFn, Options{
+		WriteBufferSize: 1 << 20,
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if err := f.Append([]Foo{{1}}, 1); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close again: %v", err)
+	}
+
+	// buffered blocks are flushed
+	f, err = New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	n, err := f.Count()
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("got %d blocks", n)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+
+	path1 := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d.zstd", rand.Int63()))
+	path2 := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d.snappy", rand.Int63()))
+	f, err := NewWithOptions(path1, colSetsFn, Options{
+		WriteBufferSize: 1 << 20,
+		IndexFile:       true,
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	check := func(n int) {
+		count, err := f.Count()
+		if err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		if count != n {
+			t.Fatalf("got %d blocks, expecting %d", count, n)
+		}
+		for i := 0; i < n; i++ {
+			var meta int
+			var columns struct {
+				Foo []int
+			}
+			if err := f.ReadBlock(i, &meta, &columns); err != nil {
+				t.Fatalf("read block: %v", err)
+			}
+			if meta != i || columns.Foo[0] != i {
+				t.Fatalf("got %d %v at %d", meta, columns.Foo, i)
+			}
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if err := f.Append([]Foo{{i}}, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	check(3)
+
+	if err := f.Reopen(path2); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if len(f.blockIndex) != 0 {
+		t.Fatal("block index not cleared")
+	}
+	if f.path != path2 || f.compressMethod != _COMPRESS_SNAPPY {
+		t.Fatalf("got %s %d", f.path, f.compressMethod)
+	}
+	check(0)
+	for i := 0; i < 5; i++ {
+		if err := f.Append([]Foo{{i}}, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	check(5)
+
+	if err := f.Reopen(path1); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if f.compressMethod != _COMPRESS_ZSTD {
+		t.Fatalf("got %d", f.compressMethod)
+	}
+	check(3)
+	if err := f.Reopen(path2); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	check(5)
+
+	// read-only
+	r, err := Open(path1, colSetsFn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer r.Close()
+	if err := r.Reopen(path2 + ".none"); err == nil {
+		t.Fatal("should fail")
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+
+	// missing
+	if _, err := Open(path, colSetsFn); err == nil {
+		t.Fatal("expecting error")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("file created")
+	}
+
+	f, err := New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if err := f.Append([]Foo{{1}}, 1); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	f, err = Open(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	n := 0
+	err = f.Iter([]string{"Foo"}, func(cols ...interface{}) bool {
+		if cols[0].([]int)[0] != 1 {
+			t.Fatalf("got %v", cols[0])
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("got %d blocks", n)
+	}
+	err = f.Append([]Foo{{2}}, 2)
+	if err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Fatalf("expecting read-only error, got %v", err)
+	}
+	if err := f.Truncate(0); err == nil {
+		t.Fatal("expecting error")
+	}
+}
+
+// setCountingCodec counts decoded column sets
+type setCountingCodec struct {
+	GobCodec
+	sets *int64
+}
+
+func (c setCountingCodec) Decode(r io.Reader, v interface{}) error {
+	if _, ok := v.(*interface{}); ok {
+		atomic.AddInt64(c.sets, 1)
+	}
+	return c.GobCodec.Decode(r, v)
+}
+
+func TestIterAllFilter(t *testing.T) {
+	type Meta struct {
+		Start int
+		Skip  bool
+	}
+	var decodedSets int64
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := NewWithOptions(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	}, Options{
+		Codec: setCountingCodec{
+			sets: &decodedSets,
+		},
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	for i := 0; i < 100; i++ {
+		err := f.Append([]struct {
+			Foo int
+			Bar string
+		}{{i, fmt.Sprintf("%d", i)}}, Meta{
+			Start: i,
+			Skip:  i%2 == 0,
+		})
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	atomic.StoreInt64(&decodedSets, 0)
+	var meta Meta
+	var columns struct {
+		Foo []int
+		Bar []string
+	}
+	accepted := 0
+	n := 0
+	err = f.IterAllFilter(&meta, &columns, func() bool {
+		if meta.Skip {
+			return false
+		}
+		accepted++
+		return true
+	}, func() bool {
+		if meta.Skip || meta.Start != columns.Foo[0] || columns.Bar[0] != fmt.Sprintf("%d", meta.Start) {
+			t.Fatalf("got %+v %v %v", meta, columns.Foo, columns.Bar)
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter all filter: %v", err)
+	}
+	if accepted != 50 || n != 50 {
+		t.Fatalf("accepted %d, got %d", accepted, n)
+	}
+	// two sets of each accepted block
+	if decodedSets != 100 {
+		t.Fatalf("decoded %d column sets", decodedSets)
+	}
+}
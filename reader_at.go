@@ -0,0 +1,53 @@
+package rcf
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// NewReaderAt returns a read-only File reading size bytes from r, like a file loaded into a bytes.Reader, or an object in a store.
+// compression and codec are read from the file header, or decided by opts for files without header. opts.Mmap is not supported.
+// appending and truncating return errors.
+func NewReaderAt(r io.ReaderAt, size int64, colSetsFn func(int) interface{}, opts Options) (*File, error) {
+	return newFile("", &readerAtBackend{r, size}, true, colSetsFn, opts)
+}
+
+// openData opens the data of the file for reading. closer is nil if there is nothing to close.
+func (f *File) openData() (r io.ReadSeeker, closer io.Closer, err error) {
+	r, closer, err = f.backend.Reader()
+	if err != nil {
+		return nil, nil, err
+	}
+	return countingReader{r, &f.bytesRead}, closer, nil
+}
+
+// BytesRead returns the number of bytes read from the file or the io.ReaderAt by the File, for verifying that projections reduce reads.
+// reads of mapped files are not counted.
+func (f *File) BytesRead() int64 {
+	return atomic.LoadInt64(&f.bytesRead)
+}
+
+// countingReader adds the number of bytes read to n
+type countingReader struct {
+	io.ReadSeeker
+	n *int64
+}
+
+func (c countingReader) Read(bs []byte) (int, error) {
+	n, err := c.ReadSeeker.Read(bs)
+	atomic.AddInt64(c.n, int64(n))
+	return n, err
+}
+
+// dataSize returns the size of the file
+func (f *File) dataSize() (int64, error) {
+	return f.backend.Size()
+}
+
+// checkWritable returns an error if the file is read-only
+func (f *File) checkWritable() error {
+	if f.readOnly {
+		return makeErr(nil, "file opened read-only")
+	}
+	return nil
+}
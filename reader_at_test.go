@@ -0,0 +1,242 @@
+package rcf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewReaderAt(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d.zstd", rand.Int63()))
+	f, err := New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := f.Append([]Foo{{i, fmt.Sprintf("%d", i)}}, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(path)
+
+	// compression and codec are read from the header
+	r, err := NewReaderAt(bytes.NewReader(content), int64(len(content)), colSetsFn, Options{})
+	if err != nil {
+		t.Fatalf("new reader at: %v", err)
+	}
+	defer r.Close()
+	if r.compressMethod != _COMPRESS_ZSTD {
+		t.Fatalf("got compression %d", r.compressMethod)
+	}
+	n := 0
+	err = r.Iter([]string{"Foo", "Bar"}, func(columns ...interface{}) bool {
+		if columns[0].([]int)[0] != n || columns[1].([]string)[0] != fmt.Sprintf("%d", n) {
+			t.Fatalf("got %v", columns)
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter: %v", err)
+	}
+	if n != 10 {
+		t.Fatalf("got %d blocks", n)
+	}
+	var meta int
+	var columns struct {
+		Bar []string
+	}
+	if err := r.ReadBlock(7, &meta, &columns); err != nil {
+		t.Fatalf("read block: %v", err)
+	}
+	if meta != 7 || columns.Bar[0] != "7" {
+		t.Fatalf("got %d %v", meta, columns.Bar)
+	}
+	rows, err := r.NumRows()
+	if err != nil {
+		t.Fatalf("num rows: %v", err)
+	}
+	if rows != 10 {
+		t.Fatalf("got %d rows", rows)
+	}
+
+	// read-only
+	if err := r.Append([]Foo{{10, "10"}}, 10); err == nil {
+		t.Fatal("should fail")
+	}
+	if err := r.Truncate(0); err == nil {
+		t.Fatal("should fail")
+	}
+
+	// empty
+	r, err = NewReaderAt(bytes.NewReader(nil), 0, colSetsFn, Options{})
+	if err != nil {
+		t.Fatalf("new reader at: %v", err)
+	}
+	count, err := r.Count()
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("got %d", count)
+	}
+
+	// unsupported options
+	for _, opts := range []Options{{Mmap: true}, {IndexFile: true}} {
+		recorder := &rangeRecorder{ReaderAt: bytes.NewReader(content)}
+		if _, err := NewReaderAt(recorder, int64(len(content)), colSetsFn, opts); err == nil {
+			t.Fatal("should fail")
+		}
+		if len(recorder.ranges) > 0 {
+			t.Fatal("read before checking options")
+		}
+	}
+}
+
+type rangeRecorder struct {
+	io.ReaderAt
+	ranges [][2]int64
+}
+
+func (r *rangeRecorder) ReadAt(bs []byte, offset int64) (int, error) {
+	n, err := r.ReaderAt.ReadAt(bs, offset)
+	r.ranges = append(r.ranges, [2]int64{offset, offset + int64(n)})
+	return n, err
+}
+
+func TestPartialReads(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	for i := 0; i < 10; i++ {
+		rows := make([]Foo, 1000)
+		for j := range rows {
+			rows[j] = Foo{j, fmt.Sprintf("%d-%d", i, j)}
+		}
+		if err := f.Append(rows, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	// ranges of Bar payloads
+	var skipped [][2]int64
+	cursor, err := f.Cursor()
+	if err != nil {
+		t.Fatalf("cursor: %v", err)
+	}
+	for {
+		header, err := cursor.NextHeader()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next header: %v", err)
+		}
+		end := header.Offset + header.Size()
+		skipped = append(skipped, [2]int64{end - int64(header.SetLengths[1]), end})
+	}
+	cursor.Close()
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := &rangeRecorder{
+		ReaderAt: bytes.NewReader(content),
+	}
+	r, err := NewReaderAt(recorder, int64(len(content)), colSetsFn, Options{
+		PartialReads: true,
+	})
+	if err != nil {
+		t.Fatalf("new reader at: %v", err)
+	}
+	defer r.Close()
+	recorder.ranges = nil
+	before := r.BytesRead()
+	n := 0
+	err = r.Iter([]string{"Foo"}, func(columns ...interface{}) bool {
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter: %v", err)
+	}
+	if n != 10 {
+		t.Fatalf("got %d blocks", n)
+	}
+	var read int64
+	for _, rng := range recorder.ranges {
+		read += rng[1] - rng[0]
+		for _, skip := range skipped {
+			if rng[0] < skip[1] && rng[1] > skip[0] {
+				t.Fatalf("read %v of skipped column set %v", rng, skip)
+			}
+		}
+	}
+	if r.BytesRead()-before != read {
+		t.Fatalf("counted %d bytes, read %d", r.BytesRead()-before, read)
+	}
+	if read >= int64(len(content))/2 {
+		t.Fatalf("read %d bytes of %d", read, len(content))
+	}
+
+	// whole blocks are verified
+	recorder.ranges = nil
+	err = r.Iter([]string{"Foo", "Bar"}, func(columns ...interface{}) bool {
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter: %v", err)
+	}
+	if len(recorder.ranges) == 0 {
+		t.Fatal("no read")
+	}
+}
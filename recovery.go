@@ -0,0 +1,160 @@
+package rcf
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// CommittedEnd returns the offset just past the last complete block, ignoring a partially written tail. the file is not modified.
+func (f *File) CommittedEnd() (int64, error) {
+	cursor, err := f.Cursor()
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close()
+	size, err := f.dataSize()
+	if err != nil {
+		return 0, err
+	}
+	end := cursor.Tell()
+	for {
+		header, err := cursor.NextHeader()
+		if err == io.EOF || isTornBlock(err) {
+			return end, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if header.Offset+header.Size() > size {
+			return end, nil
+		}
+		end = header.Offset + header.Size()
+	}
+}
+
+// Truncate removes blocks after the first blockCount ones, and any partially written data after them.
+// returns ErrBlockOutOfRange if there are less than blockCount complete blocks.
+func (f *File) Truncate(blockCount int) error {
+	if err := f.checkWritable(); err != nil {
+		return err
+	}
+	if blockCount < 0 {
+		return ErrBlockOutOfRange
+	}
+	f.Lock()
+	defer f.Unlock()
+	if err := f.flush(); err != nil {
+		return err
+	}
+
+	// find the end of the last kept block
+	r, closer, err := f.openData()
+	if err != nil {
+		return err
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+	size, err := f.dataSize()
+	if err != nil {
+		return err
+	}
+	_, err = r.Seek(f.dataStart, os.SEEK_SET)
+	if err != nil {
+		return makeErr(err, "seek")
+	}
+	cursor, err := NewFrameCursor(r)
+	if err != nil {
+		return err
+	}
+	cursor.flags = f.flags
+	cursor.wideSets = f.wideSets
+	end := f.dataStart
+	for i := 0; i < blockCount; i++ {
+		header, err := cursor.NextHeader()
+		if err == io.EOF || isTornBlock(err) {
+			return ErrBlockOutOfRange
+		}
+		if err != nil {
+			return err
+		}
+		end = header.Offset + header.Size()
+		if end > size {
+			return ErrBlockOutOfRange
+		}
+	}
+
+	// the mapping would cover bytes past the new end, it is mapped again by the next read
+	f.mapLock.Lock()
+	defer f.mapLock.Unlock()
+	if f.mapped != nil {
+		err = munmapFile(f.mapped)
+		f.mapped = nil
+		if err != nil {
+			return makeErr(err, "munmap")
+		}
+	}
+	err = f.backend.Truncate(end)
+	if err != nil {
+		return makeErr(err, "truncate")
+	}
+	f.writeOffset = end
+	if len(f.blockIndex) > blockCount {
+		f.blockIndex = f.blockIndex[:blockCount]
+		f.blockIndexEnd = end
+	}
+	// positioned at the end of the last block
+	f.validateErr = nil
+	// restart streams since the truncated payloads may carry type descriptions
+	for i := range f.streamEncoders {
+		f.streamEncoders[i] = nil
+	}
+	return nil
+}
+
+// Repair truncates corrupted blocks at the end of the file, as left by a crash, and returns the number of dropped blocks.
+// a block is corrupted if it is cut short, or its checksum does not match. if a corrupted block is followed by valid blocks, an error is returned and the file is not modified.
+// corrupted block lengths may hide the following blocks, which are dropped then.
+func (f *File) Repair() (droppedBlocks int, err error) {
+	cursor, err := f.Cursor()
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close()
+	valid := 0
+	var corruption error
+	for {
+		_, err := cursor.NextHeader()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			_, err = cursor.ReadPayload()
+		}
+		if err != nil && !isTornBlock(err) && !isChecksumError(err) {
+			return 0, err
+		}
+		if err != nil {
+			if corruption == nil {
+				corruption = err
+			}
+			droppedBlocks++
+			if isTornBlock(err) { // at the end
+				break
+			}
+			continue
+		}
+		if corruption != nil {
+			return 0, makeErr(corruption, fmt.Sprintf("block %d is corrupted, and followed by valid blocks", valid))
+		}
+		valid++
+	}
+	if corruption == nil {
+		return 0, nil
+	}
+	if err := f.Truncate(valid); err != nil {
+		return 0, err
+	}
+	return droppedBlocks, nil
+}
@@ -0,0 +1,588 @@
+package rcf
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommittedEnd(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	end, err := f.CommittedEnd()
+	if err != nil {
+		t.Fatalf("committed end: %v", err)
+	}
+	if end != f.dataStart {
+		t.Fatalf("got %d for empty file", end)
+	}
+
+	for i := 0; i < 3; i++ {
+		err = f.Append([]Foo{{i}}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	f.Sync()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := info.Size()
+
+	end, err = f.CommittedEnd()
+	if err != nil {
+		t.Fatalf("committed end: %v", err)
+	}
+	if end != size {
+		t.Fatalf("got %d, expecting %d", end, size)
+	}
+
+	for _, tail := range [][]byte{
+		{1},                             // partial header
+		{1, 0, 0, 0, 0, 9, 0, 0},        // partial set length
+		{1, 8, 0, 0, 0, 9, 0, 0, 0, 42}, // partial payload
+	} {
+		file, err := os.OpenFile(path, os.O_RDWR, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := file.Truncate(size); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.WriteAt(tail, size); err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+		end, err = f.CommittedEnd()
+		if err != nil {
+			t.Fatalf("committed end: %v", err)
+		}
+		if end != size {
+			t.Fatalf("got %d, expecting %d", end, size)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+
+	for _, opts := range []Options{
+		{},
+		{GobStream: true},
+		{WriteBufferSize: 1 << 20},
+	} {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+		f, err := NewWithOptions(path, func(i int) (ret interface{}) {
+			switch i {
+			case 0:
+				ret = &struct {
+					Foo []int
+				}{}
+			}
+			return
+		}, opts)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+
+		for i := 0; i < 10; i++ {
+			err = f.Append([]Foo{{i}}, i)
+			if err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+		if !opts.GobStream {
+			// build the offset index
+			var meta int
+			var columns struct {
+				Foo []int
+			}
+			if err := f.ReadBlock(9, &meta, &columns); err != nil {
+				t.Fatalf("read block: %v", err)
+			}
+		}
+
+		if err := f.Truncate(11); err != ErrBlockOutOfRange {
+			t.Fatalf("expecting out of range, got %v", err)
+		}
+		if err := f.Truncate(5); err != nil {
+			t.Fatalf("truncate: %v", err)
+		}
+		n, err := f.Count()
+		if err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		if n != 5 {
+			t.Fatalf("got %d blocks", n)
+		}
+
+		// appending after truncation
+		for i := 5; i < 8; i++ {
+			err = f.Append([]Foo{{i * 10}}, i)
+			if err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+		i := 0
+		err = f.Iter([]string{"Foo"}, func(columns ...interface{}) bool {
+			foo := columns[0].([]int)[0]
+			if i < 5 && foo != i || i >= 5 && foo != i*10 {
+				t.Fatalf("got %d at %d", foo, i)
+			}
+			i++
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter: %v", err)
+		}
+		if i != 8 {
+			t.Fatalf("got %d blocks", i)
+		}
+		if !opts.GobStream {
+			var meta int
+			var columns struct {
+				Foo []int
+			}
+			if err := f.ReadBlock(7, &meta, &columns); err != nil {
+				t.Fatalf("read block: %v", err)
+			}
+			if meta != 7 || columns.Foo[0] != 70 {
+				t.Fatalf("bad block %d %v", meta, columns)
+			}
+		}
+
+		// cut a partial tail
+		f.Sync()
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.Write([]byte{1, 8, 0, 0}); err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+		if err := f.VerifyIntegrity(); err == nil {
+			t.Fatalf("expecting error")
+		}
+		if err := f.Truncate(8); err != nil {
+			t.Fatalf("truncate: %v", err)
+		}
+		if err := f.VerifyIntegrity(); err != nil {
+			t.Fatalf("verify: %v", err)
+		}
+		f.Close()
+	}
+}
+
+func TestTornBlock(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	for i := 0; i < 4; i++ {
+		err = f.Append([]Foo{{i}}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	// the last block
+	cursor, err := f.Cursor()
+	if err != nil {
+		t.Fatalf("cursor: %v", err)
+	}
+	var header *BlockHeader
+	for i := 0; i < 4; i++ {
+		header, err = cursor.NextHeader()
+		if err != nil {
+			t.Fatalf("next header: %v", err)
+		}
+	}
+	cursor.Close()
+	if len(header.stats) == 0 {
+		t.Fatalf("no stats")
+	}
+
+	for _, c := range []struct {
+		got, expected int64
+	}{
+		// truncating from the end
+		{header.Size() - 1, header.Size()},             // in column set
+		{header.HeaderSize() + 1, header.Size()},       // in meta
+		{header.HeaderSize() - 1, header.HeaderSize()}, // in statistics
+		{3, 1 + 4*2 + 4 + 4 + 4},                       // in lengths
+	} {
+		if err := os.Truncate(path, header.Offset+c.got); err != nil {
+			t.Fatal(err)
+		}
+		check := func(err error) {
+			t.Helper()
+			if !isTornBlock(err) {
+				t.Fatalf("expecting torn block, got %v", err)
+			}
+			torn := err.(*Err).Err.(*TornBlockError)
+			if torn.Offset != header.Offset || torn.Got != c.got || torn.Expected != c.expected {
+				t.Fatalf("got %+v, expecting %d %d %d", torn, header.Offset, c.got, c.expected)
+			}
+		}
+		n := 0
+		check(f.Iter([]string{"Foo"}, func(columns ...interface{}) bool {
+			n++
+			return true
+		}))
+		var meta int
+		var columns struct {
+			Foo []int
+		}
+		check(f.IterAll(&meta, &columns, func() bool {
+			n++
+			return true
+		}))
+		check(f.IterMetas(func(meta int) bool {
+			n++
+			return true
+		}))
+		check(f.VerifyIntegrity())
+		_, err := f.Count()
+		check(err)
+		if n > 9 {
+			t.Fatalf("got %d blocks", n)
+		}
+	}
+}
+
+func TestRepair(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	newFile := func() (*File, string, []int64) {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+		f, err := New(path, func(i int) (ret interface{}) {
+			switch i {
+			case 0:
+				ret = &struct {
+					Foo []int
+				}{}
+			}
+			return
+		})
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		var offsets []int64
+		for i := 0; i < 5; i++ {
+			offset, err := f.AppendAt([]Foo{{i}}, i)
+			if err != nil {
+				t.Fatalf("append: %v", err)
+			}
+			offsets = append(offsets, offset)
+		}
+		return f, path, offsets
+	}
+	// flips the last byte before end
+	corrupt := func(path string, end int64) {
+		file, err := os.OpenFile(path, os.O_RDWR, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		b := make([]byte, 1)
+		if _, err := file.ReadAt(b, end-1); err != nil {
+			t.Fatal(err)
+		}
+		b[0] ^= 0xff
+		if _, err := file.WriteAt(b, end-1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func(f *File) int {
+		n, err := f.Count()
+		if err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		return n
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		f, _, _ := newFile()
+		defer f.Close()
+		dropped, err := f.Repair()
+		if err != nil || dropped != 0 {
+			t.Fatalf("got %d %v", dropped, err)
+		}
+		if count(f) != 5 {
+			t.Fatal("blocks dropped")
+		}
+	})
+
+	t.Run("trailing corruption", func(t *testing.T) {
+		f, path, offsets := newFile()
+		defer f.Close()
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		corrupt(path, info.Size())
+		corrupt(path, offsets[4])
+		dropped, err := f.Repair()
+		if err != nil {
+			t.Fatalf("repair: %v", err)
+		}
+		if dropped != 2 {
+			t.Fatalf("dropped %d blocks", dropped)
+		}
+		if count(f) != 3 {
+			t.Fatal("bad block count")
+		}
+		if err := f.VerifyIntegrity(); err != nil {
+			t.Fatalf("verify: %v", err)
+		}
+		if err := f.Append([]Foo{{5}}, 5); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		if count(f) != 4 {
+			t.Fatal("bad block count")
+		}
+	})
+
+	t.Run("torn tail", func(t *testing.T) {
+		f, path, offsets := newFile()
+		defer f.Close()
+		if err := os.Truncate(path, offsets[4]+3); err != nil {
+			t.Fatal(err)
+		}
+		dropped, err := f.Repair()
+		if err != nil {
+			t.Fatalf("repair: %v", err)
+		}
+		if dropped != 1 {
+			t.Fatalf("dropped %d blocks", dropped)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != offsets[4] {
+			t.Fatalf("got size %d", info.Size())
+		}
+	})
+
+	t.Run("mid-file corruption", func(t *testing.T) {
+		f, path, offsets := newFile()
+		defer f.Close()
+		corrupt(path, offsets[2])
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Repair(); !isChecksumError(err.(*Err).Err) {
+			t.Fatalf("got %v", err)
+		}
+		after, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if after.Size() != info.Size() {
+			t.Fatal("file modified")
+		}
+	})
+}
+
+func TestAppendTornFile(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := f.Append([]Foo{{i}}, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cut the payload of the last block
+	if err := os.Truncate(path, info.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	err = f.Append([]Foo{{3}}, 3)
+	if !errors.Is(err, ErrTornBlock) {
+		t.Fatalf("expecting torn block error, got %v", err)
+	}
+	// still failing
+	err = f.AppendBatch([]Batch{{Rows: []Foo{{3}}, Meta: 3}})
+	if !errors.Is(err, ErrTornBlock) {
+		t.Fatalf("expecting torn block error, got %v", err)
+	}
+
+	dropped, err := f.Repair()
+	if err != nil {
+		t.Fatalf("repair: %v", err)
+	}
+	if dropped != 1 {
+		t.Fatalf("dropped %d blocks", dropped)
+	}
+	if err := f.Append([]Foo{{3}}, 3); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	n, err := f.Count()
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("got %d blocks", n)
+	}
+	var meta int
+	var columns struct {
+		Foo []int
+	}
+	if err := f.ReadBlock(2, &meta, &columns); err != nil {
+		t.Fatalf("read block: %v", err)
+	}
+	if meta != 3 || columns.Foo[0] != 3 {
+		t.Fatalf("got %d %v", meta, columns.Foo)
+	}
+}
+
+func TestTruncateTornTail(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+	opts := Options{
+		TruncateTornTail: true,
+	}
+
+	for _, cut := range []int64{
+		1,  // payload
+		20, // set lengths
+	} {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+		f, err := NewWithOptions(path, colSetsFn, opts)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		for i := 0; i < 3; i++ {
+			if err := f.Append([]Foo{{i}}, i); err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+		if err := f.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+		// crash while appending the last block
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Truncate(path, info.Size()-cut); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err = NewWithOptions(path, colSetsFn, opts)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		for i := 2; i < 4; i++ {
+			if err := f.Append([]Foo{{i}}, i); err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+		var metas []int
+		err = f.IterMetas(func(meta int) bool {
+			metas = append(metas, meta)
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter metas: %v", err)
+		}
+		var foos []int
+		err = f.Iter([]string{"Foo"}, func(cols ...interface{}) bool {
+			foos = append(foos, cols[0].([]int)...)
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter: %v", err)
+		}
+		if len(metas) != 4 || len(foos) != 4 {
+			t.Fatalf("got %v %v", metas, foos)
+		}
+		for i := 0; i < 4; i++ {
+			if metas[i] != i || foos[i] != i {
+				t.Fatalf("got %v %v", metas, foos)
+			}
+		}
+		if err := f.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+	}
+}
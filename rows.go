@@ -0,0 +1,192 @@
+package rcf
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// IterRows is like Iter, but calls fn once per row, with one argument per column in the order of cols, like func(nid int, title string) bool.
+// elements are passed as is, so pointers like *big.Rat are the ones in the decoded columns. elements of interface columns may also be passed to arguments of their dynamic types.
+// iteration stops if fn returns false.
+func (f *File) IterRows(cols []string, fn interface{}) error {
+	fnValue := reflect.ValueOf(fn)
+	fnType := fnValue.Type()
+	if fnType.Kind() != reflect.Func {
+		return makeErr(nil, "callback is not function")
+	}
+	if fnType.NumIn() != len(cols) {
+		return makeErr(nil, fmt.Sprintf("callback takes %d arguments, %d columns requested", fnType.NumIn(), len(cols)))
+	}
+	if fnType.NumOut() != 1 || fnType.Out(0).Kind() != reflect.Bool {
+		return makeErr(nil, "callback must return bool")
+	}
+	if len(cols) == 0 {
+		return makeErr(nil, "no column requested")
+	}
+	dynamic := make([]bool, len(cols)) // interface elements passed by their dynamic values
+	for i, col := range cols {
+		t, ok := f.columnType(col)
+		if !ok {
+			return makeErr(nil, fmt.Sprintf("no such column: %s", col))
+		}
+		if t.Elem().AssignableTo(fnType.In(i)) {
+			continue
+		}
+		if t.Elem().Kind() == reflect.Interface && fnType.In(i).Implements(t.Elem()) {
+			dynamic[i] = true
+			continue
+		}
+		return makeErr(nil, fmt.Sprintf("column %s is %v, callback argument %d is %v", col, t, i, fnType.In(i)))
+	}
+
+	// map callback arguments to Iter columns
+	order, unknown := f.iterOrder(cols)
+	if len(unknown) > 0 {
+		return makeErr(nil, fmt.Sprintf("no such column: %s", strings.Join(unknown, ", ")))
+	}
+	indexes := make(map[string]int)
+	for i, col := range order {
+		indexes[col] = i
+	}
+	positions := make([]int, len(cols))
+	for i, col := range cols {
+		positions[i] = indexes[col]
+	}
+
+	args := make([]reflect.Value, len(cols))
+	var argErr error
+	err := f.Iter(cols, func(columns ...interface{}) bool {
+		values := make([]reflect.Value, len(columns))
+		for i, column := range columns {
+			values[i] = reflect.ValueOf(column)
+		}
+		for row, l := 0, blockRows(values); row < l; row++ {
+			for i, pos := range positions {
+				args[i] = columnValue(values[pos], row)
+				if !dynamic[i] {
+					continue
+				}
+				args[i] = args[i].Elem()
+				argType := fnType.In(i)
+				if !args[i].IsValid() { // nil
+					args[i] = reflect.Zero(argType)
+				} else if !args[i].Type().AssignableTo(argType) {
+					argErr = makeErr(nil, fmt.Sprintf("value of column %s is %v, callback argument %d is %v", cols[i], args[i].Type(), i, argType))
+					return false
+				}
+			}
+			if !fnValue.Call(args)[0].Bool() {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return argErr
+}
+
+// AppendRow appends a block of one row. row is a struct, or a pointer to struct, like an element of rows of Append.
+func (f *File) AppendRow(row, meta interface{}) error {
+	rowValue := reflect.ValueOf(row)
+	if rowValue.Kind() == reflect.Ptr {
+		rowValue = rowValue.Elem()
+	}
+	if rowValue.Kind() != reflect.Struct {
+		return makeErr(nil, "row is not struct")
+	}
+	columns := make(map[string]reflect.Value)
+	for _, set := range f.colSets {
+		for _, col := range set {
+			field := rowValue.FieldByName(col)
+			if !field.IsValid() {
+				return makeErr(nil, fmt.Sprintf("no %s field in row", col))
+			}
+			column := reflect.MakeSlice(reflect.SliceOf(field.Type()), 1, 1)
+			column.Index(0).Set(field)
+			columns[col] = column
+		}
+	}
+	_, err := f.appendColumns(columns, meta)
+	return err
+}
+
+// IterStructs is like IterRows, but sets the fields of the struct rowPtr points to from each row, and calls cb.
+// exported fields of the struct name the columns to read.
+func (f *File) IterStructs(rowPtr interface{}, cb func() bool) error {
+	ptr := reflect.ValueOf(rowPtr)
+	if ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Struct {
+		return makeErr(nil, "row is not pointer to struct")
+	}
+	row := ptr.Elem()
+	rowType := row.Type()
+	var cols []string
+	var fields []int
+	for i, l := 0, rowType.NumField(); i < l; i++ {
+		field := rowType.Field(i)
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+		t, ok := f.columnType(field.Name)
+		if !ok {
+			return makeErr(nil, fmt.Sprintf("no such column: %s", field.Name))
+		}
+		if !t.Elem().AssignableTo(field.Type) {
+			return makeErr(nil, fmt.Sprintf("column %s is %v, field is %v", field.Name, t, field.Type))
+		}
+		cols = append(cols, field.Name)
+		fields = append(fields, i)
+	}
+	if len(cols) == 0 {
+		return makeErr(nil, "no column requested")
+	}
+
+	// map struct fields to Iter columns
+	order, _ := f.iterOrder(cols)
+	indexes := make(map[string]int)
+	for i, col := range order {
+		indexes[col] = i
+	}
+	positions := make([]int, len(cols))
+	for i, col := range cols {
+		positions[i] = indexes[col]
+	}
+
+	return f.Iter(cols, func(columns ...interface{}) bool {
+		values := make([]reflect.Value, len(columns))
+		for i, column := range columns {
+			values[i] = reflect.ValueOf(column)
+		}
+		for r, l := 0, blockRows(values); r < l; r++ {
+			for i, pos := range positions {
+				row.Field(fields[i]).Set(columnValue(values[pos], r))
+			}
+			if !cb() {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// blockRows returns the number of rows of the columns of a block, which is the length of the longest column,
+// since columns of sets added to the schema after the block was appended are empty.
+func blockRows(columns []reflect.Value) int {
+	rows := 0
+	for _, column := range columns {
+		if l := column.Len(); l > rows {
+			rows = l
+		}
+	}
+	return rows
+}
+
+// columnValue returns the element of column at row, or the zero value for empty columns of sets added after the block was appended
+func columnValue(column reflect.Value, row int) reflect.Value {
+	if row < column.Len() {
+		return column.Index(row)
+	}
+	return reflect.Zero(column.Type().Elem())
+}
@@ -0,0 +1,539 @@
+package rcf
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIterRows(t *testing.T) {
+	type Item struct {
+		Nid      int
+		Category int
+		Sales    int
+		Title    string
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) interface{} {
+		switch i {
+		case 0:
+			return &struct {
+				Nid []int
+			}{}
+		case 1:
+			return &struct {
+				Category []int
+				Sales    []int
+			}{}
+		case 2:
+			return &struct {
+				Title []string
+			}{}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	for i := 0; i < 10; i++ {
+		var items []Item
+		for j := 0; j < 10; j++ {
+			n := i*10 + j
+			items = append(items, Item{n, n % 3, n * 2, fmt.Sprintf("item %d", n)})
+		}
+		err = f.Append(items, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	n := 0
+	err = f.IterRows([]string{"Title", "Nid", "Category"}, func(title string, nid int, cat int) bool {
+		if nid != n || cat != n%3 || title != fmt.Sprintf("item %d", n) {
+			t.Fatalf("row not match: %d %d %s", nid, cat, title)
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter rows: %v", err)
+	}
+	if n != 100 {
+		t.Fatalf("got %d rows", n)
+	}
+
+	t.Run("stop", func(t *testing.T) {
+		n := 0
+		err := f.IterRows([]string{"Nid"}, func(nid int) bool {
+			n++
+			return n < 15
+		})
+		if err != nil {
+			t.Fatalf("iter rows: %v", err)
+		}
+		if n != 15 {
+			t.Fatalf("got %d rows", n)
+		}
+	})
+
+	t.Run("bad callback", func(t *testing.T) {
+		for _, fn := range []interface{}{
+			42,
+			func(nid int) {},
+			func(nid int, title string) bool { return true },
+			func(nid string) bool { return true },
+		} {
+			if err := f.IterRows([]string{"Nid"}, fn); err == nil {
+				t.Fatalf("expected error for %T", fn)
+			}
+		}
+		if err := f.IterRows([]string{"Foo"}, func(foo int) bool { return true }); err == nil {
+			t.Fatal("expected error for unknown column")
+		}
+	})
+}
+
+func TestIterRowsReferenceTypes(t *testing.T) {
+	type Point struct {
+		X, Y int
+	}
+	type Item struct {
+		Price *big.Rat
+		Attr  interface{}
+		Point Point
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) interface{} {
+		switch i {
+		case 0:
+			return &struct {
+				Price []*big.Rat
+			}{}
+		case 1:
+			return &struct {
+				Attr  []interface{}
+				Point []Point
+			}{}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	var items []Item
+	for i := 0; i < 10; i++ {
+		items = append(items, Item{big.NewRat(int64(i), 3), i, Point{i, -i}})
+	}
+	if err := f.Append(items, 0); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	n := 0
+	err = f.IterRows([]string{"Price", "Attr", "Point", "Price"}, func(price *big.Rat, attr int, point Point, price2 *big.Rat) bool {
+		if price.Cmp(big.NewRat(int64(n), 3)) != 0 {
+			t.Fatalf("got price %v", price)
+		}
+		if price != price2 {
+			t.Fatal("pointer not passed as is")
+		}
+		if attr != n || point.X != n || point.Y != -n {
+			t.Fatalf("got %v %v", attr, point)
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter rows: %v", err)
+	}
+	if n != 10 {
+		t.Fatalf("got %d rows", n)
+	}
+
+	// dynamic type not matching
+	err = f.IterRows([]string{"Attr"}, func(attr string) bool {
+		return true
+	})
+	if err == nil {
+		t.Fatal("expecting error")
+	}
+}
+
+func TestAppendRow(t *testing.T) {
+	type Item struct {
+		Nid   int
+		Title string
+	}
+	schema := func(i int) interface{} {
+		switch i {
+		case 0:
+			return &struct {
+				Nid []int
+			}{}
+		case 1:
+			return &struct {
+				Title []string
+			}{}
+		}
+		return nil
+	}
+
+	rowsPath := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	rowsFile, err := New(rowsPath, schema)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer rowsFile.Close()
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, schema)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	for i := 0; i < 10; i++ {
+		item := Item{i, fmt.Sprintf("item %d", i)}
+		if i%2 == 0 {
+			err = f.AppendRow(item, i)
+		} else {
+			err = f.AppendRow(&item, i)
+		}
+		if err != nil {
+			t.Fatalf("append row: %v", err)
+		}
+		if err := rowsFile.Append([]Item{item}, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	n := 0
+	err = f.IterRows([]string{"Nid", "Title"}, func(nid int, title string) bool {
+		if nid != n || title != fmt.Sprintf("item %d", n) {
+			t.Fatalf("got %d %s", nid, title)
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter rows: %v", err)
+	}
+	if n != 10 {
+		t.Fatalf("got %d rows", n)
+	}
+
+	// same as appending one-element slices
+	if err := f.Sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if err := rowsFile.Sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	expected, err := ioutil.ReadFile(rowsPath)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !bytes.Equal(bs, expected) {
+		t.Fatal("not the same as appending slices")
+	}
+
+	if err := f.AppendRow([]Item{{1, "1"}}, 1); err == nil {
+		t.Fatal("expecting error")
+	}
+	if err := f.AppendRow(struct{ Nid int }{1}, 1); err == nil {
+		t.Fatal("expecting error")
+	}
+}
+
+func TestAppendMissingField(t *testing.T) {
+	for _, schema := range []func(int) interface{}{
+		func(i int) interface{} {
+			if i == 0 {
+				return &struct {
+					Nid   []int
+					Title []string
+				}{}
+			}
+			return nil
+		},
+		func(i int) interface{} {
+			switch i {
+			case 0:
+				return &struct {
+					Nid []int
+				}{}
+			case 1:
+				return &struct {
+					Title []string
+				}{}
+			}
+			return nil
+		},
+	} {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+		f, err := NewWithOptions(path, schema, Options{
+			SortColumn: "Title",
+		})
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		defer f.Close()
+		err = f.Append([]struct{ Nid int }{{1}, {2}}, 1)
+		if err == nil || !strings.Contains(err.Error(), "no Title field in row") {
+			t.Fatalf("got %v", err)
+		}
+		if err := f.Append([]int{1}, 1); err == nil {
+			t.Fatal("expecting error")
+		}
+	}
+}
+
+func TestEmptyAppends(t *testing.T) {
+	type Item struct {
+		Nid   int
+		Title string
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) interface{} {
+		switch i {
+		case 0:
+			return &struct {
+				Nid []int
+			}{}
+		case 1:
+			return &struct {
+				Title []string
+			}{}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	for i := 0; i < 6; i++ {
+		if i%2 == 0 {
+			err = f.Append([]struct{}{}, i)
+		} else {
+			err = f.Append([]Item{{i, "a"}, {i, "b"}}, i)
+		}
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	var nids []int
+	err = f.IterRows([]string{"Title", "Nid"}, func(title string, nid int) bool {
+		nids = append(nids, nid)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter rows: %v", err)
+	}
+	if fmt.Sprint(nids) != "[1 1 3 3 5 5]" {
+		t.Fatalf("got %v", nids)
+	}
+
+	var meta int
+	var columns struct {
+		Nid   []int
+		Title []string
+	}
+	n := 0
+	err = f.IterAll(&meta, &columns, func() bool {
+		if meta != n {
+			t.Fatalf("got meta %d", meta)
+		}
+		if n%2 == 0 && (columns.Nid != nil || columns.Title != nil) {
+			t.Fatalf("got %v %v", columns.Nid, columns.Title)
+		}
+		if n%2 == 1 && (len(columns.Nid) != 2 || len(columns.Title) != 2) {
+			t.Fatalf("got %v %v", columns.Nid, columns.Title)
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter all: %v", err)
+	}
+	if n != 6 {
+		t.Fatalf("got %d blocks", n)
+	}
+}
+
+func TestIterStructs(t *testing.T) {
+	type Foo struct {
+		Foo  int
+		Bar  string
+		Baz  bool
+		Qux  []int
+		Quux map[string]string
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+				Baz []bool
+			}{}
+		case 2:
+			ret = &struct {
+				Qux  [][]int
+				Quux []map[string]string
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	var foos []Foo
+	for i := 0; i < 10; i++ {
+		var rows []Foo
+		for j := 0; j < 3; j++ {
+			n := i*3 + j
+			rows = append(rows, Foo{n, fmt.Sprintf("%d", n), n%2 == 0, []int{n}, map[string]string{"n": fmt.Sprintf("%d", n)}})
+		}
+		if err := f.Append(rows, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		foos = append(foos, rows...)
+	}
+
+	var foo Foo
+	var got []Foo
+	err = f.IterStructs(&foo, func() bool {
+		got = append(got, foo)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter structs: %v", err)
+	}
+	if !reflect.DeepEqual(got, foos) {
+		t.Fatalf("got %v", got)
+	}
+
+	// subset, stop
+	var partial struct {
+		Baz bool
+		Foo int
+	}
+	n := 0
+	err = f.IterStructs(&partial, func() bool {
+		if partial.Foo != n || partial.Baz != (n%2 == 0) {
+			t.Fatalf("got %+v at %d", partial, n)
+		}
+		n++
+		return n < 5
+	})
+	if err != nil {
+		t.Fatalf("iter structs: %v", err)
+	}
+	if n != 5 {
+		t.Fatalf("got %d rows", n)
+	}
+
+	if err := f.IterStructs(&struct{ Foo string }{}, func() bool { return true }); err == nil {
+		t.Fatal("expecting error")
+	}
+	if err := f.IterStructs(&struct{ Nope int }{}, func() bool { return true }); err == nil {
+		t.Fatal("expecting error")
+	}
+	if err := f.IterStructs(foo, func() bool { return true }); err == nil {
+		t.Fatal("expecting error")
+	}
+}
+
+func TestIterRowsAddedSet(t *testing.T) {
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if err := f.Append([]struct{ Foo int }{{1}, {2}}, 0); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	// a set added
+	f, err = New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []int
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	type Row struct {
+		Foo int
+		Bar int
+	}
+	if err := f.Append([]Row{{3, 30}}, 1); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	expected := []Row{{1, 0}, {2, 0}, {3, 30}}
+
+	var rows []Row
+	if err := f.IterRows([]string{"Bar", "Foo"}, func(bar, foo int) bool {
+		rows = append(rows, Row{foo, bar})
+		return true
+	}); err != nil {
+		t.Fatalf("iter rows: %v", err)
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("got %v", rows)
+	}
+
+	rows = rows[:0]
+	var row Row
+	if err := f.IterStructs(&row, func() bool {
+		rows = append(rows, row)
+		return true
+	}); err != nil {
+		t.Fatalf("iter structs: %v", err)
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("got %v", rows)
+	}
+}
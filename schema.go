@@ -0,0 +1,161 @@
+package rcf
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// SchemasCompatible reports whether two column set definitions have the same sets, field names and field types. if not, the first difference is described in the returned string.
+func SchemasCompatible(a, b func(int) interface{}) (bool, string) {
+	for n := 0; ; n++ {
+		va := a(n)
+		vb := b(n)
+		if va == nil && vb == nil {
+			return true, ""
+		}
+		if va == nil {
+			return false, fmt.Sprintf("column set %d only in second schema", n)
+		}
+		if vb == nil {
+			return false, fmt.Sprintf("column set %d only in first schema", n)
+		}
+		ta := reflect.TypeOf(va).Elem()
+		tb := reflect.TypeOf(vb).Elem()
+		if ta.NumField() != tb.NumField() {
+			return false, fmt.Sprintf("column set %d: %d fields vs %d fields", n, ta.NumField(), tb.NumField())
+		}
+		for i, l := 0, ta.NumField(); i < l; i++ {
+			fa := ta.Field(i)
+			fb := tb.Field(i)
+			if fa.Name != fb.Name {
+				return false, fmt.Sprintf("column set %d field %d: name %s vs %s", n, i, fa.Name, fb.Name)
+			}
+			if fa.Type != fb.Type {
+				return false, fmt.Sprintf("column set %d field %s: type %v vs %v", n, fa.Name, fa.Type, fb.Type)
+			}
+		}
+	}
+}
+
+// conformSet returns a set of type t, a pointer to struct, with fields of set, a pointer to struct of another type, matched by names.
+// fields not in set, or of types not convertible, are left zero.
+func conformSet(set reflect.Value, t reflect.Type) reflect.Value {
+	ret := reflect.New(t.Elem())
+	src := set.Elem()
+	dst := ret.Elem()
+	for i, l := 0, dst.NumField(); i < l; i++ {
+		field := dst.Type().Field(i)
+		value := src.FieldByName(field.Name)
+		if !value.IsValid() {
+			continue
+		}
+		if value.Kind() == field.Type.Kind() && value.Type().ConvertibleTo(field.Type) {
+			dst.Field(i).Set(value.Convert(field.Type))
+		}
+	}
+	return ret
+}
+
+// emptySet returns the nth column set with all columns set to empty slices.
+// it stands for sets missing from blocks appended before the set was added to the schema.
+func (f *File) emptySet(n int) reflect.Value {
+	s := reflect.ValueOf(f.colSetsFn(n)).Elem()
+	for i, l := 0, s.NumField(); i < l; i++ {
+		s.Field(i).Set(reflect.MakeSlice(s.Field(i).Type(), 0, 0))
+	}
+	return s
+}
+
+// checkSchema decodes the column sets of the first block with the column sets of f, to report mismatched column types at open time.
+// blocks that can not be read, like torn or corrupted ones, are left to be reported by later reads.
+func (f *File) checkSchema() error {
+	cursor, err := f.Cursor()
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	_, err = cursor.NextHeader()
+	if err != nil { // empty or unreadable
+		return nil
+	}
+	toDecode := make([]bool, len(f.colSets))
+	for i := range toDecode {
+		toDecode[i] = true
+	}
+	_, setBytes, err := cursor.readParts(false, toDecode)
+	if err != nil {
+		return nil
+	}
+	decode := func(n int, target interface{}) error {
+		var decoders []*gobStreamDecoder
+		if f.gobStream {
+			decoders = make([]*gobStreamDecoder, len(f.colSets))
+		}
+		return f.decodeSetAs(decoders, n, setBytes[n], target)
+	}
+	for n, bs := range setBytes {
+		if n >= len(f.colSets) || bs == nil {
+			continue
+		}
+		s := f.colSetsFn(n)
+		t := reflect.TypeOf(s).Elem()
+		err := decode(n, &s)
+		if err == nil {
+			decoded := reflect.TypeOf(s).Elem()
+			if decoded == t {
+				continue
+			}
+			// decoded as another registered type
+			for i, l := 0, t.NumField(); i < l; i++ {
+				field := t.Field(i)
+				fileField, ok := decoded.FieldByName(field.Name)
+				if !ok {
+					return makeKindErr(KindSchemaMismatch, &SchemaMismatchError{
+						Set:    n,
+						Column: field.Name,
+						Type:   field.Type,
+						Err:    makeErr(nil, "no such column in file"),
+					}, "schema mismatch")
+				}
+				if fileField.Type != field.Type {
+					return makeKindErr(KindSchemaMismatch, &SchemaMismatchError{
+						Set:    n,
+						Column: field.Name,
+						Type:   field.Type,
+						Err:    makeErr(nil, fmt.Sprintf("column is %v in file", fileField.Type)),
+					}, "schema mismatch")
+				}
+			}
+			continue
+		}
+		// find the column to blame by decoding columns one by one. if all columns fail, like when the set type is not known to the codec, the set is blamed.
+		var column *reflect.StructField
+		var columnErr error
+		failed := 0
+		for i, l := 0, t.NumField(); i < l; i++ {
+			field := t.Field(i)
+			probe := reflect.New(reflect.StructOf([]reflect.StructField{field}))
+			if e := decode(n, probe.Interface()); e != nil {
+				if column == nil {
+					column = &field
+					columnErr = e
+				}
+				failed++
+			}
+		}
+		if column != nil && failed < t.NumField() {
+			return makeKindErr(KindSchemaMismatch, &SchemaMismatchError{
+				Set:    n,
+				Column: column.Name,
+				Type:   column.Type,
+				Err:    columnErr,
+			}, "schema mismatch")
+		}
+		return makeKindErr(KindSchemaMismatch, &SchemaMismatchError{
+			Set:  n,
+			Type: t,
+			Err:  err,
+		}, "schema mismatch")
+	}
+	return nil
+}
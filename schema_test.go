@@ -0,0 +1,569 @@
+package rcf
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSchemasCompatible(t *testing.T) {
+	base := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+				Baz []bool
+			}{}
+		}
+		return
+	}
+	same := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+				Baz []bool
+			}{}
+		}
+		return
+	}
+	if ok, reason := SchemasCompatible(base, same); !ok {
+		t.Fatalf("should be compatible: %s", reason)
+	}
+
+	for i, fn := range []func(int) interface{}{
+		// less sets
+		func(i int) (ret interface{}) {
+			switch i {
+			case 0:
+				ret = &struct {
+					Foo []int
+				}{}
+			}
+			return
+		},
+		// renamed field
+		func(i int) (ret interface{}) {
+			switch i {
+			case 0:
+				ret = &struct {
+					Foo []int
+				}{}
+			case 1:
+				ret = &struct {
+					Bar []string
+					Qux []bool
+				}{}
+			}
+			return
+		},
+		// different type
+		func(i int) (ret interface{}) {
+			switch i {
+			case 0:
+				ret = &struct {
+					Foo []int64
+				}{}
+			case 1:
+				ret = &struct {
+					Bar []string
+					Baz []bool
+				}{}
+			}
+			return
+		},
+		// less fields
+		func(i int) (ret interface{}) {
+			switch i {
+			case 0:
+				ret = &struct {
+					Foo []int
+				}{}
+			case 1:
+				ret = &struct {
+					Bar []string
+				}{}
+			}
+			return
+		},
+	} {
+		ok, reason := SchemasCompatible(base, fn)
+		if ok {
+			t.Fatalf("%d: should not be compatible", i)
+		}
+		if reason == "" {
+			t.Fatalf("%d: no reason", i)
+		}
+		ok, _ = SchemasCompatible(fn, base)
+		if ok {
+			t.Fatalf("%d: should not be compatible", i)
+		}
+	}
+}
+
+func TestSchemaEvolution(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	type Foo2 struct {
+		Foo int
+		Bar string
+		Baz bool
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := f.Append([]Foo{{i, "old"}}, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	f, err = New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		case 2:
+			ret = &struct {
+				Baz []bool
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	for i := 3; i < 6; i++ {
+		if err := f.Append([]Foo2{{i, "new", true}}, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	check := func(n int, foos []int, bars []string, bazs []bool) {
+		if len(foos) != 1 || foos[0] != n {
+			t.Fatalf("block %d: got foos %v", n, foos)
+		}
+		if n < 3 {
+			if bars[0] != "old" || bazs == nil || len(bazs) != 0 {
+				t.Fatalf("block %d: got %v %v", n, bars, bazs)
+			}
+		} else {
+			if bars[0] != "new" || len(bazs) != 1 || !bazs[0] {
+				t.Fatalf("block %d: got %v %v", n, bars, bazs)
+			}
+		}
+	}
+
+	var meta int
+	var columns struct {
+		Foo []int
+		Bar []string
+		Baz []bool
+	}
+	n := 0
+	err = f.IterAll(&meta, &columns, func() bool {
+		if meta != n {
+			t.Fatalf("got meta %d, expected %d", meta, n)
+		}
+		check(n, columns.Foo, columns.Bar, columns.Baz)
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter all: %v", err)
+	}
+	if n != 6 {
+		t.Fatalf("got %d blocks", n)
+	}
+
+	n = 0
+	err = f.Iter([]string{"Foo", "Bar", "Baz"}, func(columns ...interface{}) bool {
+		if len(columns) != 3 {
+			t.Fatalf("got %d columns", len(columns))
+		}
+		check(n, columns[0].([]int), columns[1].([]string), columns[2].([]bool))
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter: %v", err)
+	}
+	if n != 6 {
+		t.Fatalf("got %d blocks", n)
+	}
+
+	columns.Baz = nil
+	if err := f.ReadBlock(1, &meta, &columns); err != nil {
+		t.Fatalf("read block: %v", err)
+	}
+	check(1, columns.Foo, columns.Bar, columns.Baz)
+}
+
+func TestSchemaMismatch(t *testing.T) {
+	for _, ext := range []string{"", ".msgpack", ".gob-stream"} {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d%s", rand.Int63(), ext))
+		opts := Options{
+			GobStream: ext == ".gob-stream",
+		}
+		f, err := NewWithOptions(path, func(i int) (ret interface{}) {
+			switch i {
+			case 0:
+				ret = &struct {
+					Foo []int
+					Bar []string
+				}{}
+			}
+			return
+		}, opts)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		if err := f.Append([]struct {
+			Foo int
+			Bar string
+		}{{1, "1"}}, 1); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+
+		// compatible
+		f, err = NewWithOptions(path, func(i int) (ret interface{}) {
+			switch i {
+			case 0:
+				ret = &struct {
+					Foo []int
+					Bar []string
+				}{}
+			}
+			return
+		}, opts)
+		if err != nil {
+			t.Fatalf("%s: new: %v", ext, err)
+		}
+		f.Close()
+
+		// incompatible
+		_, err = NewWithOptions(path, func(i int) (ret interface{}) {
+			switch i {
+			case 0:
+				ret = &struct {
+					Foo []int
+					Bar []float64
+				}{}
+			}
+			return
+		}, opts)
+		if !errors.Is(err, ErrSchemaMismatch) {
+			t.Fatalf("%s: expecting schema mismatch, got %v", ext, err)
+		}
+		e, ok := err.(*Err).Err.(*SchemaMismatchError)
+		if !ok {
+			t.Fatalf("%s: got %v", ext, err)
+		}
+		if e.Set != 0 || e.Column != "Bar" || e.Type != reflect.TypeOf([]float64{}) {
+			t.Fatalf("%s: got %v", ext, e)
+		}
+	}
+}
+
+func TestGobNameConflict(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	newColSetsFn := func() func(int) interface{} {
+		return func(i int) (ret interface{}) {
+			switch i {
+			case 0:
+				ret = &struct {
+					Foo []int
+				}{}
+			}
+			return
+		}
+	}
+
+	// identically-shaped sets of different files
+	for i := 0; i < 2; i++ {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+		f, err := New(path, newColSetsFn())
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		if err := f.Append([]Foo{{i}}, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		var foos []int
+		if err := f.ReadColumn("Foo", &foos); err != nil {
+			t.Fatalf("read column: %v", err)
+		}
+		if len(foos) != 1 || foos[0] != i {
+			t.Fatalf("got %v", foos)
+		}
+		f.Close()
+	}
+
+	// different types of the same name
+	colSetsA := func() func(int) interface{} {
+		type Bar int
+		return func(i int) (ret interface{}) {
+			switch i {
+			case 0:
+				ret = &struct {
+					Bar []Bar
+				}{}
+			}
+			return
+		}
+	}()
+	colSetsB := func() func(int) interface{} {
+		type Bar int
+		return func(i int) (ret interface{}) {
+			switch i {
+			case 0:
+				ret = &struct {
+					Bar []Bar
+				}{}
+			}
+			return
+		}
+	}()
+	f, err := New(filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63())), colSetsA)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	f.Close()
+	_, err = New(filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63())), colSetsB)
+	if err == nil {
+		t.Fatal("expecting error")
+	}
+}
+
+// named column sets of TestNamedColumnSets
+type testFooSet struct {
+	Foo []int
+}
+
+type testBarSet struct {
+	Bar []string
+	Baz []bool
+}
+
+func TestNamedColumnSets(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+		Baz bool
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = new(testFooSet)
+		case 1:
+			ret = new(testBarSet)
+		}
+		return
+	}
+	for _, opts := range []Options{
+		{},
+		{Compression: CompressSnappy, DictionaryColumns: []string{"Bar"}, DeltaColumns: []string{"Foo"}},
+		{GobStream: true},
+		{Codec: MsgpackCodec{}},
+	} {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+		f, err := NewWithOptions(path, colSetsFn, opts)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		for i := 0; i < 10; i++ {
+			if err := f.Append([]Foo{{i, "bar", true}, {i * 2, "baz", false}}, i); err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+		if err := f.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+
+		f, err = NewWithOptions(path, colSetsFn, opts)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		n := 0
+		err = f.Iter([]string{"Foo", "Bar"}, func(cols ...interface{}) bool {
+			foos := cols[0].([]int)
+			bars := cols[1].([]string)
+			if !reflect.DeepEqual(foos, []int{n, n * 2}) || !reflect.DeepEqual(bars, []string{"bar", "baz"}) {
+				t.Fatalf("got %v %v", foos, bars)
+			}
+			n++
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter: %v", err)
+		}
+		if n != 10 {
+			t.Fatalf("got %d blocks", n)
+		}
+
+		n = 0
+		var meta int
+		var columns struct {
+			Foo []int
+			Baz []bool
+		}
+		err = f.IterAll(&meta, &columns, func() bool {
+			if meta != n || !reflect.DeepEqual(columns.Foo, []int{n, n * 2}) || !reflect.DeepEqual(columns.Baz, []bool{true, false}) {
+				t.Fatalf("got %d %+v", meta, columns)
+			}
+			n++
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter all: %v", err)
+		}
+		if n != 10 {
+			t.Fatalf("got %d blocks", n)
+		}
+		f.Close()
+	}
+}
+
+func TestReorderedFields(t *testing.T) {
+	type Row struct {
+		Foo int
+		Bar string
+		Baz bool
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+				Bar []string
+				Baz []bool
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := f.Append([]Row{{i, fmt.Sprintf("%d", i), i%2 == 0}}, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	f, err = New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Baz []bool
+				Bar []string
+				Foo []int
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	var foos []int
+	if err := f.Iter([]string{"Foo"}, func(columns ...interface{}) bool {
+		foos = append(foos, columns[0].([]int)...)
+		return true
+	}); err != nil {
+		t.Fatalf("iter: %v", err)
+	}
+	if !reflect.DeepEqual(foos, []int{0, 1, 2}) {
+		t.Fatalf("got %v", foos)
+	}
+
+	// in the new schema order
+	var rows []Row
+	if err := f.Iter([]string{"Foo", "Bar", "Baz"}, func(columns ...interface{}) bool {
+		bazs := columns[0].([]bool)
+		bars := columns[1].([]string)
+		for i, foo := range columns[2].([]int) {
+			rows = append(rows, Row{foo, bars[i], bazs[i]})
+		}
+		return true
+	}); err != nil {
+		t.Fatalf("iter: %v", err)
+	}
+	if !reflect.DeepEqual(rows, []Row{{0, "0", true}, {1, "1", false}, {2, "2", true}}) {
+		t.Fatalf("got %v", rows)
+	}
+
+	var meta int
+	var columns struct {
+		Foo []int
+		Bar []string
+	}
+	n := 0
+	if err := f.IterAll(&meta, &columns, func() bool {
+		if columns.Foo[0] != meta || columns.Bar[0] != fmt.Sprintf("%d", meta) {
+			t.Fatalf("got %v %v", columns.Foo, columns.Bar)
+		}
+		n++
+		return true
+	}); err != nil {
+		t.Fatalf("iter all: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("got %d blocks", n)
+	}
+
+	var bars []string
+	if err := f.ReadColumn("Bar", &bars); err != nil {
+		t.Fatalf("read column: %v", err)
+	}
+	if !reflect.DeepEqual(bars, []string{"0", "1", "2"}) {
+		t.Fatalf("got %v", bars)
+	}
+}
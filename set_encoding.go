@@ -0,0 +1,183 @@
+package rcf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+)
+
+// encodings of column set payloads, stored in the first byte of payloads of files with set encodings
+const (
+	_SET_PLAIN       = iota
+	_SET_TRANSFORMED // the set with transformed columns, then the setTransforms
+)
+
+// setTransforms describes the columns of a set payload transformed by dictionary or delta encoding
+type setTransforms struct {
+	Dicts  []dictColumn // dictionary encoded columns, which are empty in the set
+	Deltas []string     // names of delta encoded columns
+}
+
+// transformFields resolves cols to the field indexes of each column set. ok reports whether the column type is supported by the encoding.
+func (f *File) transformFields(cols []string, encoding string, ok func(reflect.Type) bool) ([][]int, error) {
+	if len(cols) == 0 {
+		return nil, nil
+	}
+	if f.gobStream {
+		return nil, makeErr(nil, fmt.Sprintf("%s encoding can not be used with gob stream mode", encoding))
+	}
+	fields := make([][]int, len(f.colSets))
+	for _, col := range cols {
+		found := false
+		for n := range f.colSets {
+			t := reflect.TypeOf(f.colSetsFn(n)).Elem()
+			field, has := t.FieldByName(col)
+			if !has {
+				continue
+			}
+			if field.Type.Kind() != reflect.Slice || !ok(field.Type.Elem()) {
+				return nil, makeErr(nil, fmt.Sprintf("%s column %s is of unsupported type %v", encoding, col, field.Type))
+			}
+			fields[n] = append(fields[n], field.Index[0])
+			found = true
+		}
+		if !found {
+			return nil, makeErr(nil, fmt.Sprintf("no such %s column: %s", encoding, col))
+		}
+	}
+	return fields, nil
+}
+
+// encodeSet encodes column set n, a value returned by colSetsFn, prefixed by its encoding if the file has set encodings
+func (f *File) encodeSet(n int, set interface{}) ([]byte, error) {
+	if f.flags&_FLAG_SET_ENCODING == 0 {
+		return f.encode(&set)
+	}
+	var transforms setTransforms
+	v := reflect.ValueOf(set).Elem()
+	var copied reflect.Value
+	transform := func(i int, fn func(column reflect.Value) reflect.Value) {
+		column := v.Field(i)
+		if column.Len() == 0 { // keep nil slices of empty blocks
+			return
+		}
+		if !copied.IsValid() {
+			copied = reflect.New(v.Type())
+			copied.Elem().Set(v)
+		}
+		copied.Elem().Field(i).Set(fn(column))
+	}
+	if n < len(f.dictFields) {
+		for _, i := range f.dictFields[n] {
+			transform(i, func(column reflect.Value) reflect.Value {
+				transforms.Dicts = append(transforms.Dicts, makeDictColumn(v.Type().Field(i).Name, column))
+				return reflect.Zero(column.Type())
+			})
+		}
+	}
+	if n < len(f.deltaFields) {
+		for _, i := range f.deltaFields[n] {
+			transform(i, func(column reflect.Value) reflect.Value {
+				transforms.Deltas = append(transforms.Deltas, v.Type().Field(i).Name)
+				return deltaEncode(column)
+			})
+		}
+	}
+	if !copied.IsValid() {
+		bs, err := f.encode(&set)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{_SET_PLAIN}, bs...), nil
+	}
+	set = copied.Interface()
+	setBin, err := f.encode(&set)
+	if err != nil {
+		return nil, err
+	}
+	transformsBin, err := f.encode(&transforms)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteByte(_SET_TRANSFORMED)
+	binary.Write(buf, binary.LittleEndian, uint32(len(setBin)))
+	buf.Write(setBin)
+	buf.Write(transformsBin)
+	return buf.Bytes(), nil
+}
+
+// splitSetPayload returns the encoding and the compressed parts of a column set payload
+func (f *File) splitSetPayload(bs []byte) (encoding uint8, setBin, transformsBin []byte, err error) {
+	if f.flags&_FLAG_SET_ENCODING == 0 {
+		return _SET_PLAIN, bs, nil, nil
+	}
+	if len(bs) == 0 {
+		return 0, nil, nil, makeKindErr(KindCorrupt, nil, "empty column set payload")
+	}
+	encoding, bs = bs[0], bs[1:]
+	switch encoding {
+	case _SET_PLAIN:
+		return encoding, bs, nil, nil
+	case _SET_TRANSFORMED:
+		if len(bs) < 4 {
+			return 0, nil, nil, makeKindErr(KindCorrupt, nil, "bad transformed column set payload")
+		}
+		l := binary.LittleEndian.Uint32(bs)
+		bs = bs[4:]
+		if uint64(l) > uint64(len(bs)) {
+			return 0, nil, nil, makeKindErr(KindCorrupt, nil, "bad transformed column set payload")
+		}
+		return encoding, bs[:l], bs[l:], nil
+	}
+	return 0, nil, nil, makeKindErr(KindCorrupt, nil, fmt.Sprintf("unknown column set encoding %d", encoding))
+}
+
+// decodeSetPayload decodes a column set payload not in gob stream mode
+func (f *File) decodeSetPayload(bs []byte, target interface{}) error {
+	encoding, setBin, transformsBin, err := f.splitSetPayload(bs)
+	if err != nil {
+		return err
+	}
+	err = f.decode(setBin, target)
+	if err != nil {
+		return err
+	}
+	if encoding != _SET_TRANSFORMED {
+		return nil
+	}
+	var transforms setTransforms
+	err = f.decode(transformsBin, &transforms)
+	if err != nil {
+		return makeKindErr(KindDecode, err, "decode column transforms")
+	}
+	v := reflect.ValueOf(target)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	field := func(name string) (reflect.Value, error) {
+		field := v.FieldByName(name)
+		if !field.IsValid() {
+			return field, makeErr(nil, fmt.Sprintf("no %s field in column set", name))
+		}
+		return field, nil
+	}
+	for _, dict := range transforms.Dicts {
+		column, err := field(dict.Name)
+		if err != nil {
+			return err
+		}
+		if err := decodeDictColumn(dict, column); err != nil {
+			return err
+		}
+	}
+	for _, name := range transforms.Deltas {
+		column, err := field(name)
+		if err != nil {
+			return err
+		}
+		deltaDecode(column)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package rcf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// IterSetsParallel decodes the column sets keyed in handlers and passes them to their handlers, as the pointers returned by colSetsFn.
+// for each block, the handlers of different sets are called concurrently, and the next block is not dispatched until all handlers returned.
+// each handler is called sequentially in file order, so it only needs to synchronize with other handlers on shared states.
+// iteration stops if any handler returns false.
+func (f *File) IterSetsParallel(handlers map[int]func(set interface{}) bool) error {
+	toDecode := make([]bool, len(f.colSets))
+	for n := range handlers {
+		if n < 0 || n >= len(f.colSets) {
+			return makeErr(nil, fmt.Sprintf("no such column set: %d", n))
+		}
+		toDecode[n] = true
+	}
+
+	cursor, err := f.Cursor()
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+
+	var decoders []*gobStreamDecoder
+	if f.gobStream {
+		decoders = make([]*gobStreamDecoder, len(f.colSets))
+	}
+
+	it := f.newIteration(context.Background())
+
+	return it.run(func() {
+		for !it.stopped() {
+			it.read(cursor.Tell())
+			_, err := cursor.NextHeader()
+			if err == io.EOF { // no more
+				it.end(cursor.Tell())
+				return
+			}
+			if err != nil {
+				it.abort(err)
+				return
+			}
+			_, bss, err := cursor.readParts(false, toDecode)
+			if err != nil {
+				it.abort(err)
+				return
+			}
+
+			sets := make(map[int]interface{})
+			if !it.block(nil, func() bool {
+				for n, bs := range bss {
+					if bs == nil {
+						continue
+					}
+					s := f.colSetsFn(n)
+					err := f.decodeSet(decoders, n, bs, &s)
+					if err != nil {
+						it.abort(makeKindErr(KindDecode, err, "decode column set"))
+						return false
+					}
+					sets[n] = s
+				}
+				return true
+			}, func() {
+				wg := new(sync.WaitGroup)
+				wg.Add(len(sets))
+				stop := false
+				var l sync.Mutex
+				for n, s := range sets {
+					go func(handler func(interface{}) bool, s interface{}) {
+						defer wg.Done()
+						if !handler(s) {
+							l.Lock()
+							stop = true
+							l.Unlock()
+						}
+					}(handlers[n], s)
+				}
+				wg.Wait()
+				if stop {
+					it.abort(nil)
+				}
+			}, f.gobStream) {
+				return
+			}
+		}
+	})
+}
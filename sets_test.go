@@ -0,0 +1,102 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIterSetsParallel(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+		Baz bool
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		case 2:
+			ret = &struct {
+				Baz []bool
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	for i := 0; i < 50; i++ {
+		err = f.Append([]Foo{{i, fmt.Sprintf("%d", i), true}}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	var foos []int
+	var bars []string
+	err = f.IterSetsParallel(map[int]func(interface{}) bool{
+		0: func(set interface{}) bool {
+			foos = append(foos, set.(*struct {
+				Foo []int
+			}).Foo...)
+			return true
+		},
+		1: func(set interface{}) bool {
+			bars = append(bars, set.(*struct {
+				Bar []string
+			}).Bar...)
+			return true
+		},
+	})
+	if err != nil {
+		t.Fatalf("iter sets parallel: %v", err)
+	}
+	if len(foos) != 50 || len(bars) != 50 {
+		t.Fatalf("got %d foos, %d bars", len(foos), len(bars))
+	}
+	for i := range foos {
+		if foos[i] != i || bars[i] != fmt.Sprintf("%d", i) {
+			t.Fatalf("value not match at %d", i)
+		}
+	}
+
+	t.Run("stop", func(t *testing.T) {
+		n := 0
+		err := f.IterSetsParallel(map[int]func(interface{}) bool{
+			2: func(set interface{}) bool {
+				n++
+				return n < 10
+			},
+		})
+		if err != nil {
+			t.Fatalf("iter sets parallel: %v", err)
+		}
+		if n != 10 {
+			t.Fatalf("got %d blocks", n)
+		}
+	})
+
+	t.Run("bad set", func(t *testing.T) {
+		err := f.IterSetsParallel(map[int]func(interface{}) bool{
+			3: func(set interface{}) bool {
+				return true
+			},
+		})
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
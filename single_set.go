@@ -0,0 +1,38 @@
+package rcf
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// singleSet is the fast path of transposing rows for schemas of one column set.
+// columns are filled into the set directly, with row fields resolved once per call.
+func (f *File) singleSet(rows reflect.Value) ([]interface{}, error) {
+	v := f.colSetsFn(0)
+	s := reflect.ValueOf(v).Elem()
+	rowType := rows.Type().Elem()
+	order := f.rowOrder(rows)
+	l := rows.Len()
+	if l > 0 { // leave nil slices for empty rows, like the general path
+		for i, col := range f.colSets[0] {
+			rowField, ok := rowType.FieldByName(col)
+			if !ok {
+				return nil, makeErr(nil, fmt.Sprintf("no %s field in row", col))
+			}
+			field := s.Field(i)
+			if !rowField.Type.AssignableTo(field.Type().Elem()) {
+				return nil, makeErr(nil, fmt.Sprintf("column %s is %v, not %v", col, rowField.Type, field.Type().Elem()))
+			}
+			column := reflect.MakeSlice(field.Type(), l, l)
+			for j := 0; j < l; j++ {
+				row := rows.Index(j)
+				if order != nil {
+					row = rows.Index(order[j])
+				}
+				column.Index(j).Set(row.FieldByIndex(rowField.Index))
+			}
+			field.Set(column)
+		}
+	}
+	return []interface{}{v}, nil
+}
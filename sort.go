@@ -0,0 +1,80 @@
+package rcf
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+func (f *File) checkSortColumn() error {
+	for n, set := range f.colSets {
+		for _, col := range set {
+			if col != f.sortColumn {
+				continue
+			}
+			field, _ := reflect.TypeOf(f.colSetsFn(n)).Elem().FieldByName(col)
+			if field.Type.Kind() != reflect.Slice || !isOrdered(field.Type.Elem().Kind()) {
+				return makeErr(nil, fmt.Sprintf("sort column %s is not of ordered type", col))
+			}
+			return nil
+		}
+	}
+	return makeErr(nil, fmt.Sprintf("no such sort column: %s", f.sortColumn))
+}
+
+// rowOrder returns the indexes of rows sorted by the sort column, or nil if no sort column is set
+func (f *File) rowOrder(rows reflect.Value) []int {
+	if f.sortColumn == "" {
+		return nil
+	}
+	keys := make([]reflect.Value, rows.Len())
+	for i := range keys {
+		keys[i] = rows.Index(i).FieldByName(f.sortColumn)
+	}
+	return stableOrder(keys)
+}
+
+// sortedOrder returns the indexes of values of column in stably sorted order
+func sortedOrder(column reflect.Value) []int {
+	keys := make([]reflect.Value, column.Len())
+	for i := range keys {
+		keys[i] = column.Index(i)
+	}
+	return stableOrder(keys)
+}
+
+func stableOrder(keys []reflect.Value) []int {
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return less(keys[order[i]], keys[order[j]])
+	})
+	return order
+}
+
+func isOrdered(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.String:
+		return true
+	}
+	return false
+}
+
+func less(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	case reflect.String:
+		return a.String() < b.String()
+	}
+	return false
+}
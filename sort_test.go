@@ -0,0 +1,80 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSortColumn(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := NewWithOptions(path, colSetsFn, Options{
+		SortColumn: "Foo",
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	err = f.Append([]Foo{
+		{3, "c"},
+		{1, "a1"},
+		{2, "b"},
+		{1, "a2"},
+	}, 0)
+	if err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	err = f.Append([]Foo{
+		{0, "z"},
+	}, 1)
+	if err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	var foos []int
+	var bars []string
+	err = f.Iter([]string{"Foo", "Bar"}, func(cols ...interface{}) bool {
+		foos = append(foos, cols[0].([]int)...)
+		bars = append(bars, cols[1].([]string)...)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter: %v", err)
+	}
+	if fmt.Sprint(foos) != "[1 1 2 3 0]" {
+		t.Fatalf("foos not sorted within block: %v", foos)
+	}
+	if fmt.Sprint(bars) != "[a1 a2 b c z]" {
+		t.Fatalf("bars not reordered with foos: %v", bars)
+	}
+
+	t.Run("bad column", func(t *testing.T) {
+		_, err := NewWithOptions(path, colSetsFn, Options{
+			SortColumn: "Baz",
+		})
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
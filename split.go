@@ -0,0 +1,160 @@
+package rcf
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+type blockSpan struct {
+	offset int64
+	size   int64
+}
+
+// blockSpans walks the block headers of the file and returns the position of each block
+func (f *File) blockSpans() ([]blockSpan, error) {
+	cursor, err := f.Cursor()
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close()
+	var spans []blockSpan
+	for {
+		header, err := cursor.NextHeader()
+		if err == io.EOF { // no more
+			return spans, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		spans = append(spans, blockSpan{
+			offset: header.Offset,
+			size:   header.Size(),
+		})
+	}
+}
+
+// appendRaw copies encoded blocks to the end of the file
+func (f *File) appendRaw(r io.Reader) error {
+	if err := f.checkWritable(); err != nil {
+		return err
+	}
+	if err := f.validate(); err != nil {
+		return err
+	}
+	f.Lock()
+	defer f.Unlock()
+	unlock, err := f.lockFile()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	_, err = io.Copy(f.writer(), r)
+	if err != nil {
+		return makeErr(err, "write blocks")
+	}
+	return nil
+}
+
+// Split distributes the blocks of src across n files named by dstPattern, a fmt format with one integer verb like "part-%d.rcf".
+// blocks are copied without decoding, in file order, and balanced by byte size. blocks are appended if a destination file already exists.
+// destination files must use the same compression and codec as src, and files written in gob stream mode can not be split.
+func Split(src string, n int, dstPattern string, colSetsFn func(int) interface{}) ([]string, error) {
+	if n <= 0 {
+		return nil, makeErr(nil, "number of shards must be positive")
+	}
+	srcFile, err := Open(src, colSetsFn)
+	if err != nil {
+		return nil, err
+	}
+	defer srcFile.Close()
+	if srcFile.gobStream {
+		return nil, makeErr(nil, "splitting is not supported in gob stream mode")
+	}
+	spans, err := srcFile.blockSpans()
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, span := range spans {
+		total += span.size
+	}
+
+	file, err := os.Open(src)
+	if err != nil {
+		return nil, makeErr(err, "open file")
+	}
+	defer file.Close()
+
+	var paths []string
+	var cum int64
+	next := 0
+	for i := 0; i < n; i++ {
+		path := fmt.Sprintf(dstPattern, i)
+		err = createWithHeader(path, srcFile)
+		if err != nil {
+			return nil, err
+		}
+		dst, err := NewWithOptions(path, colSetsFn, Options{
+			Compression:      srcFile.compression(),
+			Codec:            srcFile.codec,
+			UncompressedMeta: srcFile.flags&_FLAG_PLAIN_META > 0,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if dst.compressMethod != srcFile.compressMethod || dst.codec.ID() != srcFile.codec.ID() || dst.flags != srcFile.flags || dst.wideSets != srcFile.wideSets {
+			dst.Close()
+			return nil, makeErr(nil, fmt.Sprintf("format of %s differs from source", path))
+		}
+		// a block goes to the shard containing its middle byte
+		start := next
+		var size int64
+		for next < len(spans) && (cum+spans[next].size/2)*int64(n)/total == int64(i) {
+			cum += spans[next].size
+			size += spans[next].size
+			next++
+		}
+		if size > 0 {
+			err = dst.appendRaw(io.NewSectionReader(file, spans[start].offset, size))
+			if err != nil {
+				dst.Close()
+				return nil, err
+			}
+		}
+		err = dst.Close()
+		if err != nil {
+			return nil, makeErr(err, "close file")
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// createWithHeader writes a header of the format of src to path, if there is no file or an empty file at path, so that blocks of src can be copied as is
+func createWithHeader(path string, src *File) error {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return makeErr(err, "open file")
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return makeErr(err, "stat file")
+	}
+	if info.Size() > 0 {
+		return nil
+	}
+	_, err = file.Write(fileHeader{
+		Version:        formatVersion(src.wideSets),
+		CompressMethod: src.compressMethod,
+		Flags:          src.flags,
+		Codec:          src.codec.ID(),
+		CompressLevel:  uint8(src.compressLevel),
+	}.marshal())
+	if err != nil {
+		return makeErr(err, "write header")
+	}
+	return nil
+}
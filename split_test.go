@@ -0,0 +1,137 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	err := os.Mkdir(dir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src")
+	f, err := New(src, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		err = f.Append([]Foo{{i}}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	f.Close()
+
+	paths, err := Split(src, 4, filepath.Join(dir, "part-%d"), colSetsFn)
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	if len(paths) != 4 {
+		t.Fatalf("got %d paths", len(paths))
+	}
+
+	next := 0
+	for _, path := range paths {
+		part, err := New(path, colSetsFn)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		n := 0
+		err = part.IterMetas(func(meta int) bool {
+			n++
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter metas: %v", err)
+		}
+		if n < 20 || n > 30 {
+			t.Fatalf("unbalanced shard: %d blocks", n)
+		}
+		var meta int
+		var columns struct {
+			Foo []int
+		}
+		err = part.IterAll(&meta, &columns, func() bool {
+			if columns.Foo[0] != meta {
+				t.Fatalf("wrong value %d %d", meta, columns.Foo[0])
+			}
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter all: %v", err)
+		}
+		next += n
+		part.Close()
+	}
+	if next != 100 {
+		t.Fatalf("got %d blocks", next)
+	}
+}
+
+func TestSplitSource(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// no source file
+	src := filepath.Join(dir, "src")
+	if _, err := Split(src, 2, filepath.Join(dir, "part-%d"), colSetsFn); err == nil {
+		t.Fatal("should fail")
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Fatalf("source created: %v", err)
+	}
+
+	// gob stream source
+	f, err := NewWithOptions(src, colSetsFn, Options{
+		GobStream: true,
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := f.Append([]Foo{{i}}, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err := Split(src, 2, filepath.Join(dir, "part-%d"), colSetsFn); err == nil {
+		t.Fatal("should fail")
+	}
+}
@@ -0,0 +1,18 @@
+package rcf
+
+import (
+	"context"
+	"time"
+)
+
+// IterTimeRange is like Iter, but only delivers blocks written in [from, to), as stamped by Options.StampTime. other blocks are skipped without reading column sets.
+// returns an error if the file was not created with Options.StampTime.
+func (f *File) IterTimeRange(from, to time.Time, cols []string, cb func(columns ...interface{}) bool) error {
+	if f.flags&_FLAG_TIME == 0 {
+		return makeErr(nil, "blocks have no timestamps")
+	}
+	fromNano, toNano := from.UnixNano(), to.UnixNano()
+	return f.iter(context.Background(), cols, func(header *BlockHeader) bool {
+		return header.Time >= fromNano && header.Time < toNano
+	}, cb)
+}
@@ -0,0 +1,94 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIterTimeRange(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := NewWithOptions(path, colSetsFn, Options{
+		StampTime: true,
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	var times []time.Time
+	for i := 0; i < 5; i++ {
+		times = append(times, time.Now())
+		time.Sleep(time.Millisecond * 10)
+		if err := f.Append([]Foo{{i}}, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	times = append(times, time.Now())
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	// reopened without the option, the header decides
+	f, err = New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	if err := f.Append([]Foo{{5}}, 5); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	var foos []int
+	err = f.IterTimeRange(times[1], times[4], []string{"Foo"}, func(cols ...interface{}) bool {
+		foos = append(foos, cols[0].([]int)...)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter time range: %v", err)
+	}
+	if fmt.Sprint(foos) != "[1 2 3]" {
+		t.Fatalf("got %v", foos)
+	}
+	foos = nil
+	err = f.IterTimeRange(times[4], time.Now(), []string{"Foo"}, func(cols ...interface{}) bool {
+		foos = append(foos, cols[0].([]int)...)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter time range: %v", err)
+	}
+	if fmt.Sprint(foos) != "[4 5]" {
+		t.Fatalf("got %v", foos)
+	}
+	n, err := f.Count()
+	if err != nil || n != 6 {
+		t.Fatalf("got %d blocks, %v", n, err)
+	}
+
+	// not stamped
+	path = filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f2, err := New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f2.Close()
+	err = f2.IterTimeRange(times[0], time.Now(), []string{"Foo"}, func(cols ...interface{}) bool {
+		return true
+	})
+	if err == nil {
+		t.Fatal("expecting error")
+	}
+}
@@ -0,0 +1,397 @@
+package rcf
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+)
+
+// kinds of column statistics
+const (
+	_STAT_INT = iota + 1
+	_STAT_UINT
+	_STAT_FLOAT
+	_STAT_RAT
+)
+
+// columnStat is the min and max value of a column in a block
+type columnStat struct {
+	name     string
+	kind     uint8
+	min, max interface{} // int64, uint64, float64 or *big.Rat, by kind
+}
+
+var ratPtrType = reflect.TypeOf((*big.Rat)(nil))
+
+// statKind returns the statistics kind of column values of type t, 0 if not supported
+func statKind(t reflect.Type) uint8 {
+	if t == ratPtrType {
+		return _STAT_RAT
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return _STAT_INT
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return _STAT_UINT
+	case reflect.Float32, reflect.Float64:
+		return _STAT_FLOAT
+	}
+	return 0
+}
+
+// columnStats returns statistics of the columns of sets. empty columns and columns of unsupported types have no statistics.
+func (f *File) columnStats(sets []interface{}) (stats []columnStat) {
+	for _, set := range sets {
+		v := reflect.ValueOf(set)
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		t := v.Type()
+		for i, l := 0, t.NumField(); i < l; i++ {
+			column := v.Field(i)
+			kind := statKind(column.Type().Elem())
+			if kind == 0 || column.Len() == 0 {
+				continue
+			}
+			if stat, ok := sliceStat(kind, column); ok {
+				stat.name = t.Field(i).Name
+				stats = append(stats, stat)
+			}
+		}
+	}
+	return
+}
+
+// sliceStat returns the min and max value of column. NaNs and nil *big.Rats are ignored.
+func sliceStat(kind uint8, column reflect.Value) (stat columnStat, ok bool) {
+	stat.kind = kind
+	// fast paths
+	switch s := column.Interface().(type) {
+	case []int:
+		min, max := s[0], s[0]
+		for _, v := range s[1:] {
+			if v < min {
+				min = v
+			} else if v > max {
+				max = v
+			}
+		}
+		stat.min, stat.max = int64(min), int64(max)
+		return stat, true
+	case []int64:
+		min, max := s[0], s[0]
+		for _, v := range s[1:] {
+			if v < min {
+				min = v
+			} else if v > max {
+				max = v
+			}
+		}
+		stat.min, stat.max = min, max
+		return stat, true
+	}
+	for i, l := 0, column.Len(); i < l; i++ {
+		var v interface{}
+		elem := column.Index(i)
+		switch kind {
+		case _STAT_INT:
+			v = elem.Int()
+		case _STAT_UINT:
+			v = elem.Uint()
+		case _STAT_FLOAT:
+			if math.IsNaN(elem.Float()) {
+				continue
+			}
+			v = elem.Float()
+		case _STAT_RAT:
+			if elem.IsNil() {
+				continue
+			}
+			v = elem.Interface()
+		}
+		if !ok {
+			stat.min, stat.max = v, v
+			ok = true
+		} else if compareStat(kind, v, stat.min) < 0 {
+			stat.min = v
+		} else if compareStat(kind, v, stat.max) > 0 {
+			stat.max = v
+		}
+	}
+	return
+}
+
+func compareStat(kind uint8, a, b interface{}) int {
+	switch kind {
+	case _STAT_INT:
+		x, y := a.(int64), b.(int64)
+		if x < y {
+			return -1
+		} else if x > y {
+			return 1
+		}
+	case _STAT_UINT:
+		x, y := a.(uint64), b.(uint64)
+		if x < y {
+			return -1
+		} else if x > y {
+			return 1
+		}
+	case _STAT_FLOAT:
+		x, y := a.(float64), b.(float64)
+		if x < y {
+			return -1
+		} else if x > y {
+			return 1
+		}
+	case _STAT_RAT:
+		return a.(*big.Rat).Cmp(b.(*big.Rat))
+	}
+	return 0
+}
+
+// statValue converts v to the statistics representation of kind, for comparing with values of type t
+// out is -1 if v is less than all values of t, 1 if greater than all, and the returned value is nil then
+func statValue(kind uint8, t reflect.Type, v interface{}) (ret interface{}, out int, err error) {
+	value := reflect.ValueOf(v)
+	if kind == _STAT_RAT {
+		if r, ok := v.(*big.Rat); ok && r != nil {
+			return r, 0, nil
+		}
+		return nil, 0, makeErr(nil, fmt.Sprintf("%v is not *big.Rat", v))
+	}
+	if !value.IsValid() || statKind(value.Type()) == 0 || statKind(value.Type()) == _STAT_RAT || !value.Type().ConvertibleTo(t) {
+		return nil, 0, makeErr(nil, fmt.Sprintf("%v is not convertible to %v", v, t))
+	}
+	out, err = statOverflow(t, value)
+	if err != nil || out != 0 {
+		return nil, out, err
+	}
+	value = value.Convert(t)
+	switch kind {
+	case _STAT_INT:
+		return value.Int(), 0, nil
+	case _STAT_UINT:
+		return value.Uint(), 0, nil
+	}
+	return value.Float(), 0, nil
+}
+
+// statOverflow tells whether value is out of the range of t, -1 for below and 1 for above
+func statOverflow(t reflect.Type, value reflect.Value) (int, error) {
+	zero := reflect.Zero(t)
+	sign := func(negative bool) int {
+		if negative {
+			return -1
+		}
+		return 1
+	}
+	switch statKind(value.Type()) {
+	case _STAT_INT:
+		x := value.Int()
+		switch statKind(t) {
+		case _STAT_INT:
+			if zero.OverflowInt(x) {
+				return sign(x < 0), nil
+			}
+		case _STAT_UINT:
+			if x < 0 {
+				return -1, nil
+			}
+			if zero.OverflowUint(uint64(x)) {
+				return 1, nil
+			}
+		}
+	case _STAT_UINT:
+		x := value.Uint()
+		switch statKind(t) {
+		case _STAT_INT:
+			if x > math.MaxInt64 || zero.OverflowInt(int64(x)) {
+				return 1, nil
+			}
+		case _STAT_UINT:
+			if zero.OverflowUint(x) {
+				return 1, nil
+			}
+		}
+	case _STAT_FLOAT:
+		x := value.Float()
+		if math.IsNaN(x) {
+			return 0, makeErr(nil, fmt.Sprintf("%v is not comparable", x))
+		}
+		switch statKind(t) {
+		case _STAT_INT:
+			// fractions are truncated by conversion, which only widens the range
+			if x < math.MinInt64 {
+				return -1, nil
+			}
+			if x >= math.MaxInt64 || zero.OverflowInt(int64(x)) {
+				return sign(x < 0), nil
+			}
+		case _STAT_UINT:
+			if x < 0 {
+				return -1, nil
+			}
+			if x >= math.MaxUint64 || zero.OverflowUint(uint64(x)) {
+				return 1, nil
+			}
+		case _STAT_FLOAT:
+			if zero.OverflowFloat(x) {
+				return sign(x < 0), nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+func marshalStats(stats []columnStat) []byte {
+	if len(stats) == 0 {
+		return nil
+	}
+	buf := new(bytes.Buffer)
+	var tmp [binary.MaxVarintLen64]byte
+	putUvarint := func(v uint64) {
+		buf.Write(tmp[:binary.PutUvarint(tmp[:], v)])
+	}
+	putUvarint(uint64(len(stats)))
+	for _, stat := range stats {
+		putUvarint(uint64(len(stat.name)))
+		buf.WriteString(stat.name)
+		buf.WriteByte(stat.kind)
+		for _, v := range []interface{}{stat.min, stat.max} {
+			switch stat.kind {
+			case _STAT_INT:
+				buf.Write(tmp[:binary.PutVarint(tmp[:], v.(int64))])
+			case _STAT_UINT:
+				putUvarint(v.(uint64))
+			case _STAT_FLOAT:
+				binary.Write(buf, binary.LittleEndian, math.Float64bits(v.(float64)))
+			case _STAT_RAT:
+				bs, _ := v.(*big.Rat).GobEncode() // never fails for non-nil values
+				putUvarint(uint64(len(bs)))
+				buf.Write(bs)
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+func unmarshalStats(bs []byte) (stats []columnStat, err error) {
+	if len(bs) == 0 {
+		return nil, nil
+	}
+	r := bytes.NewReader(bs)
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, makeErr(err, "read number of statistics")
+	}
+	for i := uint64(0); i < n; i++ {
+		var stat columnStat
+		l, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, makeErr(err, "read column name length")
+		}
+		name := make([]byte, l)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return nil, makeErr(err, "read column name")
+		}
+		stat.name = string(name)
+		stat.kind, err = r.ReadByte()
+		if err != nil {
+			return nil, makeErr(err, "read statistics kind")
+		}
+		var values [2]interface{}
+		for j := range values {
+			switch stat.kind {
+			case _STAT_INT:
+				values[j], err = binary.ReadVarint(r)
+			case _STAT_UINT:
+				values[j], err = binary.ReadUvarint(r)
+			case _STAT_FLOAT:
+				var bits uint64
+				err = binary.Read(r, binary.LittleEndian, &bits)
+				values[j] = math.Float64frombits(bits)
+			case _STAT_RAT:
+				var l uint64
+				l, err = binary.ReadUvarint(r)
+				if err != nil {
+					break
+				}
+				bs := make([]byte, l)
+				if _, err = io.ReadFull(r, bs); err != nil {
+					break
+				}
+				rat := new(big.Rat)
+				err = rat.GobDecode(bs)
+				values[j] = rat
+			default:
+				return nil, makeErr(nil, fmt.Sprintf("unknown statistics kind %d", stat.kind))
+			}
+			if err != nil {
+				return nil, makeErr(err, "read statistics value")
+			}
+		}
+		stat.min, stat.max = values[0], values[1]
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
+
+// IterWhere is like Iter of column col, but skips blocks whose values of col are all out of the range [min, max], by the statistics in block headers.
+// min or max may be nil for an unbounded side. blocks in range are delivered whole, values out of range are not filtered out.
+// blocks without statistics, like blocks of files written by older versions, are not skipped.
+// col must be of integer, float or *big.Rat type. bounds out of the range of the column type are not wrapped: they leave the side unbounded, or match no values.
+func (f *File) IterWhere(col string, min, max interface{}, cb func(columns ...interface{}) bool) error {
+	t, ok := f.columnType(col)
+	if !ok {
+		return makeErr(nil, fmt.Sprintf("no such column: %s", col))
+	}
+	kind := statKind(t.Elem())
+	if kind == 0 {
+		return makeErr(nil, fmt.Sprintf("column %s is %v, no statistics", col, t))
+	}
+	// bounds out of the range of the column type are unbounded sides, or ranges with no values
+	var empty bool
+	if min != nil {
+		bound, out, err := statValue(kind, t.Elem(), min)
+		if err != nil {
+			return err
+		}
+		empty = empty || out > 0
+		min = bound
+	}
+	if max != nil {
+		bound, out, err := statValue(kind, t.Elem(), max)
+		if err != nil {
+			return err
+		}
+		empty = empty || out < 0
+		max = bound
+	}
+	return f.iter(context.Background(), []string{col}, func(header *BlockHeader) bool {
+		stats, err := unmarshalStats(header.stats)
+		if err != nil { // not skipping, the checksum of the block will tell
+			return true
+		}
+		for _, stat := range stats {
+			if stat.name != col || stat.kind != kind {
+				continue
+			}
+			if empty {
+				return false
+			}
+			if max != nil && compareStat(kind, stat.min, max) > 0 {
+				return false
+			}
+			if min != nil && compareStat(kind, stat.max, min) < 0 {
+				return false
+			}
+		}
+		return true
+	}, cb)
+}
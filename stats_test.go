@@ -0,0 +1,223 @@
+package rcf
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+var countedDecodes int64
+
+// countedInt counts its gob decodings
+type countedInt int64
+
+func (c countedInt) GobEncode() ([]byte, error) {
+	return []byte(strconv.FormatInt(int64(c), 10)), nil
+}
+
+func (c *countedInt) GobDecode(bs []byte) error {
+	atomic.AddInt64(&countedDecodes, 1)
+	v, err := strconv.ParseInt(string(bs), 10, 64)
+	*c = countedInt(v)
+	return err
+}
+
+func TestIterWhere(t *testing.T) {
+	type Foo struct {
+		Foo countedInt
+		Bar float64
+		Baz string
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []countedInt
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []float64
+				Baz []string
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	// block i has values [i*10, i*10+9]
+	for i := 0; i < 100; i++ {
+		var rows []Foo
+		for _, j := range rand.Perm(10) {
+			rows = append(rows, Foo{countedInt(i*10 + j), float64(i*10 + j), "foo"})
+		}
+		err = f.Append(rows, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	atomic.StoreInt64(&countedDecodes, 0)
+	var blocks []int
+	err = f.IterWhere("Foo", countedInt(425), 431, func(columns ...interface{}) bool {
+		foos := columns[0].([]countedInt)
+		blocks = append(blocks, int(foos[0])/10)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter where: %v", err)
+	}
+	if !reflect.DeepEqual(blocks, []int{42, 43}) {
+		t.Fatalf("got blocks %v", blocks)
+	}
+	if n := atomic.LoadInt64(&countedDecodes); n != 20 {
+		t.Fatalf("decoded %d values", n)
+	}
+
+	// unbounded
+	blocks = blocks[:0]
+	err = f.IterWhere("Bar", 975.5, nil, func(columns ...interface{}) bool {
+		bars := columns[0].([]float64)
+		blocks = append(blocks, int(bars[0])/10)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter where: %v", err)
+	}
+	if !reflect.DeepEqual(blocks, []int{97, 98, 99}) {
+		t.Fatalf("got blocks %v", blocks)
+	}
+
+	for _, args := range [][3]interface{}{
+		{"Baz", nil, nil},      // no statistics
+		{"Qux", nil, nil},      // no such column
+		{"Foo", "foo", nil},    // bad bound
+		{"Bar", nil, new(int)}, // bad bound
+	} {
+		err = f.IterWhere(args[0].(string), args[1], args[2], func(columns ...interface{}) bool {
+			return true
+		})
+		if err == nil {
+			t.Fatalf("should fail: %v", args)
+		}
+	}
+}
+
+func TestStatsMarshal(t *testing.T) {
+	stats := []columnStat{
+		{"a", _STAT_INT, int64(-42), int64(math.MaxInt64)},
+		{"b", _STAT_UINT, uint64(0), uint64(math.MaxUint64)},
+		{"c", _STAT_FLOAT, math.Inf(-1), 4.2},
+		{"d", _STAT_RAT, big.NewRat(-1, 3), big.NewRat(22, 7)},
+	}
+	decoded, err := unmarshalStats(marshalStats(stats))
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(decoded) != len(stats) {
+		t.Fatalf("got %d stats", len(decoded))
+	}
+	for i, stat := range stats {
+		d := decoded[i]
+		if d.name != stat.name || d.kind != stat.kind ||
+			compareStat(stat.kind, d.min, stat.min) != 0 ||
+			compareStat(stat.kind, d.max, stat.max) != 0 {
+			t.Fatalf("got %v, expecting %v", d, stat)
+		}
+	}
+
+	// NaN and nil values are ignored
+	stat, ok := sliceStat(_STAT_FLOAT, reflect.ValueOf([]float64{math.NaN(), 3, 1, math.NaN(), 2}))
+	if !ok || stat.min != 1.0 || stat.max != 3.0 {
+		t.Fatalf("bad stat %v", stat)
+	}
+	_, ok = sliceStat(_STAT_FLOAT, reflect.ValueOf([]float64{math.NaN()}))
+	if ok {
+		t.Fatalf("should have no stat")
+	}
+	stat, ok = sliceStat(_STAT_RAT, reflect.ValueOf([]*big.Rat{nil, big.NewRat(1, 2), big.NewRat(1, 3)}))
+	if !ok || stat.min.(*big.Rat).Cmp(big.NewRat(1, 3)) != 0 || stat.max.(*big.Rat).Cmp(big.NewRat(1, 2)) != 0 {
+		t.Fatalf("bad stat %v", stat)
+	}
+}
+
+func TestIterWhereOutOfRange(t *testing.T) {
+	type Foo struct {
+		Foo int8
+		Bar uint
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int8
+				Bar []uint
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	// block i has values [i*10, i*10+9]
+	for i := 0; i < 10; i++ {
+		var rows []Foo
+		for j := 0; j < 10; j++ {
+			rows = append(rows, Foo{int8(i*10 + j), uint(i*10 + j)})
+		}
+		if err := f.Append(rows, i); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	for _, c := range []struct {
+		col      string
+		min, max interface{}
+		blocks   int
+	}{
+		{"Foo", 300, nil, 0},      // 300 wraps to 44 if converted
+		{"Foo", nil, 300, 10},     // unbounded
+		{"Foo", -300, 5, 1},       // unbounded
+		{"Foo", nil, -300, 0},     // wraps to -44 if converted
+		{"Foo", 95, 1e10, 1},      // unbounded
+		{"Bar", -1, 15, 2},        // unbounded
+		{"Bar", nil, -1, 0},       // wraps to max uint if converted
+		{"Bar", nil, -0.5, 0},     // no values
+		{"Bar", 91, uint64(1), 0}, // empty range
+		{"Bar", 1e30, nil, 0},     // above all
+	} {
+		n := 0
+		err = f.IterWhere(c.col, c.min, c.max, func(columns ...interface{}) bool {
+			n++
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter where: %v", err)
+		}
+		if n != c.blocks {
+			t.Fatalf("%v: got %d blocks", c, n)
+		}
+	}
+
+	err = f.IterWhere("Foo", math.NaN(), nil, func(columns ...interface{}) bool {
+		return true
+	})
+	if err == nil {
+		t.Fatalf("should fail")
+	}
+}
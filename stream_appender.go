@@ -0,0 +1,84 @@
+package rcf
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// StreamAppender groups rows pushed one by one into blocks, as returned by File.NewStreamAppender
+type StreamAppender struct {
+	sync.Mutex
+	file         *File
+	rowsPerBlock int
+	metaFn       func() interface{}
+	rows         reflect.Value // buffered rows, invalid before the first Push
+	closed       bool
+}
+
+// NewStreamAppender returns a StreamAppender appending a block when rowsPerBlock rows are pushed, with the meta returned by metaFn, or nil metas if metaFn is nil.
+// Close must be called to append the remaining rows. the File is not closed by it.
+func (f *File) NewStreamAppender(rowsPerBlock int, metaFn func() interface{}) *StreamAppender {
+	return &StreamAppender{
+		file:         f,
+		rowsPerBlock: rowsPerBlock,
+		metaFn:       metaFn,
+	}
+}
+
+// Push buffers a row, and appends the buffered rows if there are rowsPerBlock rows. row is a struct, or a pointer to struct, of the same type as other pushed rows.
+func (s *StreamAppender) Push(row interface{}) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.closed {
+		return makeErr(nil, "stream appender closed")
+	}
+	if s.rowsPerBlock <= 0 {
+		return makeErr(nil, "rows per block must be positive")
+	}
+	rowValue := reflect.ValueOf(row)
+	if rowValue.Kind() == reflect.Ptr {
+		rowValue = rowValue.Elem()
+	}
+	if rowValue.Kind() != reflect.Struct {
+		return makeErr(nil, "row is not struct")
+	}
+	if !s.rows.IsValid() {
+		s.rows = reflect.MakeSlice(reflect.SliceOf(rowValue.Type()), 0, s.rowsPerBlock)
+	} else if t := s.rows.Type().Elem(); rowValue.Type() != t {
+		return makeErr(nil, fmt.Sprintf("row is %v, not %v", rowValue.Type(), t))
+	}
+	s.rows = reflect.Append(s.rows, rowValue)
+	if s.rows.Len() >= s.rowsPerBlock {
+		return s.flush()
+	}
+	return nil
+}
+
+// flush appends the buffered rows, if any. the mutex must be held.
+func (s *StreamAppender) flush() error {
+	if !s.rows.IsValid() || s.rows.Len() == 0 {
+		return nil
+	}
+	var meta interface{}
+	if s.metaFn != nil {
+		meta = s.metaFn()
+	}
+	if err := s.file.Append(s.rows.Interface(), meta); err != nil {
+		return err
+	}
+	// rows are encoded by Append, the array is reused
+	s.rows = s.rows.Slice(0, 0)
+	return nil
+}
+
+// Close appends the remaining rows. later Pushes return errors, and later calls of Close return nil.
+func (s *StreamAppender) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.flush()
+}
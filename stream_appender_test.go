@@ -0,0 +1,91 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStreamAppender(t *testing.T) {
+	type Foo struct {
+		Foo int
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	blocks := 0
+	appender := f.NewStreamAppender(100, func() interface{} {
+		blocks++
+		return blocks
+	})
+	for i := 0; i < 1050; i++ {
+		row := Foo{i}
+		if i%2 == 0 {
+			err = appender.Push(row)
+		} else {
+			err = appender.Push(&row)
+		}
+		if err != nil {
+			t.Fatalf("push: %v", err)
+		}
+	}
+	if err := appender.Push(struct{ Bar int }{}); err == nil {
+		t.Fatal("expecting error")
+	}
+	if err := appender.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := appender.Push(Foo{}); err == nil {
+		t.Fatal("expecting error")
+	}
+
+	n, err := f.Count()
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if n != 11 {
+		t.Fatalf("got %d blocks", n)
+	}
+	i := 0
+	b := 0
+	var meta int
+	var columns struct {
+		Foo []int
+	}
+	err = f.IterAll(&meta, &columns, func() bool {
+		b++
+		if meta != b {
+			t.Fatalf("got meta %d at block %d", meta, b)
+		}
+		if b < 11 && len(columns.Foo) != 100 || b == 11 && len(columns.Foo) != 50 {
+			t.Fatalf("got %d rows at block %d", len(columns.Foo), b)
+		}
+		for _, foo := range columns.Foo {
+			if foo != i {
+				t.Fatalf("got %d, expecting %d", foo, i)
+			}
+			i++
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter all: %v", err)
+	}
+	if i != 1050 {
+		t.Fatalf("got %d rows", i)
+	}
+}
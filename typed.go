@@ -0,0 +1,21 @@
+package rcf
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// IterColumn iterates values of column col, calling cb once per block with the values typed as []T.
+// T must be the element type of the column. iteration stops if cb returns false.
+func IterColumn[T any](f *File, col string, cb func([]T) bool) error {
+	t, ok := f.columnType(col)
+	if !ok {
+		return makeErr(nil, fmt.Sprintf("no such column: %s", col))
+	}
+	if expected := reflect.TypeOf([]T(nil)); t != expected {
+		return makeErr(nil, fmt.Sprintf("column %s is %v, not %v", col, t, expected))
+	}
+	return f.Iter([]string{col}, func(columns ...interface{}) bool {
+		return cb(columns[0].([]T))
+	})
+}
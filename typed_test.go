@@ -0,0 +1,110 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIterColumn(t *testing.T) {
+	type Point struct {
+		X, Y int
+	}
+	type Foo struct {
+		Foo   int
+		Bar   string
+		Point Point
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+	f, err := New(path, func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+				Bar []string
+			}{}
+		case 1:
+			ret = &struct {
+				Point []Point
+			}{}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+
+	for i := 0; i < 10; i++ {
+		err = f.Append([]Foo{
+			{i, fmt.Sprintf("%d", i), Point{i, -i}},
+			{i * 2, fmt.Sprintf("%d", i*2), Point{i * 2, -i * 2}},
+		}, i)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	n := 0
+	err = IterColumn(f, "Foo", func(foos []int) bool {
+		if len(foos) != 2 || foos[0] != n || foos[1] != n*2 {
+			t.Fatalf("bad foos %v", foos)
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter column: %v", err)
+	}
+	if n != 10 {
+		t.Fatalf("got %d blocks", n)
+	}
+
+	n = 0
+	err = IterColumn(f, "Bar", func(bars []string) bool {
+		if bars[1] != fmt.Sprintf("%d", n*2) {
+			t.Fatalf("bad bars %v", bars)
+		}
+		n++
+		return n < 5
+	})
+	if err != nil {
+		t.Fatalf("iter column: %v", err)
+	}
+	if n != 5 {
+		t.Fatalf("got %d blocks", n)
+	}
+
+	n = 0
+	err = IterColumn(f, "Point", func(points []Point) bool {
+		if points[1] != (Point{n * 2, -n * 2}) {
+			t.Fatalf("bad points %v", points)
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("iter column: %v", err)
+	}
+	if n != 10 {
+		t.Fatalf("got %d blocks", n)
+	}
+
+	err = IterColumn(f, "Foo", func(foos []int64) bool {
+		t.Fatalf("should not be called")
+		return true
+	})
+	if err == nil {
+		t.Fatalf("expecting type mismatch")
+	}
+	err = IterColumn(f, "Qux", func(quxs []int) bool {
+		t.Fatalf("should not be called")
+		return true
+	})
+	if err == nil {
+		t.Fatalf("expecting unknown column")
+	}
+}
@@ -1,7 +1,18 @@
 package rcf
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+)
 
 var (
 	pt = fmt.Printf
 )
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (n nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,108 @@
+package rcf
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// Vacuum rewrites the file without blocks for which shouldDelete returns true, and returns the number of removed blocks.
+// the meta of each block is decoded into metaTarget, a pointer, before calling shouldDelete. kept blocks are copied as bytes, without decoding column sets.
+// blocks are written to a temporary file in the same directory, which then replaces the file by renaming. the file is not rewritten if no block is removed.
+// it must not be called concurrently with appends. files written in gob stream mode can not be vacuumed.
+func (f *File) Vacuum(metaTarget interface{}, shouldDelete func() bool) (removed int, err error) {
+	if err := f.checkWritable(); err != nil {
+		return 0, err
+	}
+	if f.gobStream {
+		return 0, makeErr(nil, "vacuuming is not supported in gob stream mode")
+	}
+	if _, ok := f.backend.(*memoryFile); ok {
+		return 0, makeErr(nil, "vacuuming is not supported for memory files")
+	}
+	if v := reflect.ValueOf(metaTarget); v.Kind() != reflect.Ptr || v.IsNil() {
+		return 0, makeErr(nil, "meta target is not a pointer")
+	}
+
+	kept, removed, err := f.keptSpans(metaTarget, shouldDelete)
+	if err != nil {
+		return 0, err
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".vacuum-")
+	if err != nil {
+		return 0, makeErr(err, "create temporary file")
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer func() {
+		if err != nil {
+			os.Remove(tmpPath)
+		}
+	}()
+	if err := createWithHeader(tmpPath, f); err != nil {
+		return 0, err
+	}
+	dst, err := NewWithOptions(tmpPath, f.colSetsFn, Options{
+		Codec: f.codec,
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, span := range kept {
+		if err = f.copyRaw(dst, span.offset, span.offset+span.size); err != nil {
+			dst.Close()
+			return 0, err
+		}
+	}
+	if err = dst.Close(); err != nil {
+		return 0, makeErr(err, "close temporary file")
+	}
+
+	if err = f.replace(tmpPath); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// keptSpans returns the spans of successive blocks for which shouldDelete returns false, and the number of other blocks
+func (f *File) keptSpans(metaTarget interface{}, shouldDelete func() bool) (kept []blockSpan, removed int, err error) {
+	cursor, err := f.Cursor()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close()
+	for {
+		header, err := cursor.NextHeader()
+		if err == io.EOF { // no more
+			return kept, removed, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		metaBytes, _, err := cursor.readParts(true, nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := f.decodeMeta(metaBytes, metaTarget); err != nil {
+			return nil, 0, makeKindErr(KindDecode, err, "decode meta")
+		}
+		if shouldDelete() {
+			removed++
+			continue
+		}
+		if l := len(kept); l > 0 && kept[l-1].offset+kept[l-1].size == header.Offset {
+			kept[l-1].size += header.Size()
+		} else {
+			kept = append(kept, blockSpan{
+				offset: header.Offset,
+				size:   header.Size(),
+			})
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVacuum(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	type Meta struct {
+		User string
+		N    int
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d.zstd", rand.Int63()))
+	f, err := New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	for i := 0; i < 100; i++ {
+		if err := f.Append([]Foo{{i, fmt.Sprintf("%d", i)}}, Meta{
+			User: fmt.Sprintf("user-%d", i%3),
+			N:    i,
+		}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	var meta Meta
+	removed, err := f.Vacuum(&meta, func() bool {
+		return meta.User == "nobody"
+	})
+	if err != nil {
+		t.Fatalf("vacuum: %v", err)
+	}
+	if removed != 0 {
+		t.Fatalf("got %d", removed)
+	}
+
+	removed, err = f.Vacuum(&meta, func() bool {
+		return meta.User == "user-1"
+	})
+	if err != nil {
+		t.Fatalf("vacuum: %v", err)
+	}
+	if removed != 33 {
+		t.Fatalf("got %d", removed)
+	}
+	check := func(f *File, n int) {
+		var metas []Meta
+		if err := f.ReadAllMetas(&metas); err != nil {
+			t.Fatalf("read metas: %v", err)
+		}
+		if len(metas) != n {
+			t.Fatalf("got %d blocks", len(metas))
+		}
+		var m Meta
+		var columns struct {
+			Foo []int
+			Bar []string
+		}
+		i := 0
+		if err := f.IterAll(&m, &columns, func() bool {
+			if m.User == "user-1" || m != metas[i] ||
+				len(columns.Foo) != 1 || columns.Foo[0] != m.N || columns.Bar[0] != fmt.Sprintf("%d", m.N) {
+				t.Fatalf("got %v %v %v", m, columns.Foo, columns.Bar)
+			}
+			i++
+			return true
+		}); err != nil {
+			t.Fatalf("iter: %v", err)
+		}
+	}
+	check(f, 67)
+
+	// appendable after vacuuming
+	if err := f.Append([]Foo{{100, "100"}}, Meta{User: "user-0", N: 100}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	check(f, 68)
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	f, err = New(path, colSetsFn)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer f.Close()
+	check(f, 68)
+}
@@ -0,0 +1,44 @@
+package rcf
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdDecoder is only used by DecodeAll, which is safe for concurrent use
+var zstdDecoder, _ = zstd.NewReader(nil)
+
+// newZstdWriter returns an encoder of the zstd level, or of the default level if level is 0
+func newZstdWriter(w io.Writer, level int) *zstd.Encoder {
+	options := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+	if level > 0 {
+		options = append(options, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	}
+	// options are valid, no error
+	enc, _ := zstd.NewWriter(w, options...)
+	return enc
+}
+
+// zstdReader decompresses a whole payload on first read. payloads are small, and no decoder goroutine is left running after the read.
+type zstdReader struct {
+	r    io.Reader
+	data *bytes.Reader
+}
+
+func (z *zstdReader) Read(p []byte) (int, error) {
+	if z.data == nil {
+		bs, err := ioutil.ReadAll(z.r)
+		if err != nil {
+			return 0, err
+		}
+		bs, err = zstdDecoder.DecodeAll(bs, nil)
+		if err != nil {
+			return 0, makeErr(err, "zstd decode")
+		}
+		z.data = bytes.NewReader(bs)
+	}
+	return z.data.Read(p)
+}
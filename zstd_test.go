@@ -0,0 +1,165 @@
+package rcf
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestZstd(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+
+	for _, suffix := range []string{".zstd", ".zstd.msgpack"} {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d%s", rand.Int63(), suffix))
+		f, err := New(path, func(i int) (ret interface{}) {
+			switch i {
+			case 0:
+				ret = &struct {
+					Foo []int
+				}{}
+			case 1:
+				ret = &struct {
+					Bar []string
+				}{}
+			}
+			return
+		})
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		if f.compressMethod != _COMPRESS_ZSTD {
+			t.Fatalf("not zstd")
+		}
+
+		for i := 0; i < 100; i++ {
+			err = f.Append([]Foo{
+				{i, fmt.Sprintf("%d", i)},
+				{i * 2, fmt.Sprintf("%d", i*2)},
+			}, i)
+			if err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+
+		n := 0
+		var meta int
+		var columns struct {
+			Foo []int
+			Bar []string
+		}
+		err = f.IterAll(&meta, &columns, func() bool {
+			if meta != n || len(columns.Foo) != 2 || columns.Foo[1] != n*2 || columns.Bar[1] != fmt.Sprintf("%d", n*2) {
+				t.Fatalf("wrong iter value %d %v %v", n, meta, columns)
+			}
+			n++
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter all: %v", err)
+		}
+		if n != 100 {
+			t.Fatalf("got %d blocks", n)
+		}
+		f.Close()
+	}
+}
+
+func TestZstdLevel(t *testing.T) {
+	type Foo struct {
+		Foo int
+		Bar string
+	}
+	colSetsFn := func(i int) (ret interface{}) {
+		switch i {
+		case 0:
+			ret = &struct {
+				Foo []int
+			}{}
+		case 1:
+			ret = &struct {
+				Bar []string
+			}{}
+		}
+		return
+	}
+	rows := make([]Foo, 5000)
+	for i := range rows {
+		rows[i] = Foo{i * i % 1013, fmt.Sprintf("foo %d bar %d", i%997, i*7%89)}
+	}
+
+	sizes := make(map[int]int64)
+	for _, level := range []int{0, 1, 19} {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63()))
+		f, err := NewWithOptions(path, colSetsFn, Options{
+			Compression:      CompressZstd,
+			CompressionLevel: level,
+		})
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		for i := 0; i < 5; i++ {
+			if err := f.Append(rows, i); err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+		if err := f.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes[level] = info.Size()
+
+		// the level is read from the header
+		f, err = New(path, colSetsFn)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		if f.compressLevel != level {
+			t.Fatalf("got level %d, expecting %d", f.compressLevel, level)
+		}
+		n := 0
+		var meta int
+		var columns struct {
+			Foo []int
+			Bar []string
+		}
+		err = f.IterAll(&meta, &columns, func() bool {
+			if meta != n || len(columns.Foo) != len(rows) {
+				t.Fatalf("wrong iter value %d %v", n, meta)
+			}
+			for i, row := range rows {
+				if columns.Foo[i] != row.Foo || columns.Bar[i] != row.Bar {
+					t.Fatalf("got %d %s at %d, expecting %v", columns.Foo[i], columns.Bar[i], i, row)
+				}
+			}
+			n++
+			return true
+		})
+		if err != nil {
+			t.Fatalf("iter all: %v", err)
+		}
+		if n != 5 {
+			t.Fatalf("got %d blocks", n)
+		}
+		f.Close()
+	}
+	if sizes[19] >= sizes[1] {
+		t.Fatalf("level 19 got %d bytes, level 1 got %d", sizes[19], sizes[1])
+	}
+
+	_, err := NewWithOptions(filepath.Join(os.TempDir(), fmt.Sprintf("rcf-test-%d", rand.Int63())), colSetsFn, Options{
+		Compression:      CompressZstd,
+		CompressionLevel: 23,
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid zstd compression level") {
+		t.Fatalf("expecting level error, got %v", err)
+	}
+}